@@ -2,6 +2,10 @@ package main
 
 import (
 	"context"
+	"expvar"
+	"flag"
+	"io"
+	"log"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,19 +16,27 @@ import (
 	"github.com/jtsang4/go-stater/config"
 	"github.com/jtsang4/go-stater/internal/api"
 	"github.com/jtsang4/go-stater/internal/middleware"
-	"github.com/jtsang4/go-stater/internal/model"
 	"github.com/jtsang4/go-stater/internal/repository"
 	"github.com/jtsang4/go-stater/internal/router"
 	"github.com/jtsang4/go-stater/internal/service"
+	"github.com/jtsang4/go-stater/migrations"
 	"github.com/jtsang4/go-stater/pkg/cache"
 	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/encryption"
 	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/jtsang4/go-stater/pkg/outbox"
+	"github.com/jtsang4/go-stater/pkg/store"
 	"go.uber.org/zap"
 )
 
 func main() {
-	// Load configuration
-	cfg := config.LoadConfig()
+	// Load configuration from files, profile overlay, .env, APP_ environment variables and flags
+	configOpts := config.BindFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := config.Load(*configOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Initialize logger
 	logger.InitLogger(cfg.Logger)
@@ -33,36 +45,116 @@ func main() {
 	// Set Gin mode
 	gin.SetMode(cfg.Server.Mode)
 
+	// Initialize field-level encryption before any encrypted column is read or written
+	if _, err := encryption.InitKeyring(cfg); err != nil {
+		logger.Logger.Fatal("Failed to load encryption keys", zap.Error(err))
+	}
+
 	// Initialize database
-	db := database.InitDB(cfg.Database)
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		logger.Logger.Fatal("Failed to connect to database", zap.Error(err))
+	}
+	dbStats := database.NewStatsExporter(db)
+	if cfg.Database.StatsInterval > 0 {
+		dbStats.Start(cfg.Database.StatsInterval)
+		expvar.Publish("database", dbStats)
+	}
 
-	// Initialize Redis
-	redisCache := cache.NewRedisCache(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+	// Initialize Redis client
+	redisClient, redisBreaker, err := cache.NewRedisClient(cfg.Redis)
+	if err != nil {
+		logger.Logger.Fatal("Failed to initialize redis client", zap.Error(err))
+	}
+	if cfg.Cache.Driver != cache.DriverMemory {
+		pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := cache.Ping(pingCtx, redisClient, redisBreaker); err != nil {
+			// 缓存不可用时服务仍可运行，熔断器会在 Redis 恢复后重新启用缓存
+			logger.Logger.Warn("Redis is unavailable, cache will be bypassed", zap.Error(err))
+		}
+		cancel()
+	}
+
+	// Initialize cache
+	appCache, err := cache.NewCache(cfg.Cache, redisClient)
+	if err != nil {
+		logger.Logger.Fatal("Failed to initialize cache", zap.Error(err))
+	}
+	expvar.Publish("cache", cache.DefaultMetrics)
+	locker := cache.NewLocker(cfg.Cache, redisClient)
 
-	// Auto migrate models
-	if err := db.AutoMigrate(&model.User{}); err != nil {
-		logger.Logger.Fatal("Failed to auto migrate database", zap.Error(err))
+	// Run or verify database migrations
+	if err := migrations.Run(context.Background(), db, cfg.Database); err != nil {
+		logger.Logger.Fatal("Database migrations failed", zap.Error(err))
 	}
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
+	preferenceRepo := repository.NewPreferenceRepository(db)
+
+	txManager := database.NewTxManager(db)
+
+	// Initialize outbox and start relaying events in the background
+	outboxEvents := store.NewRepository[outbox.Event](db)
+	publisher, err := outbox.NewPublisher(cfg.Outbox, redisClient)
+	if err != nil {
+		logger.Logger.Fatal("Failed to initialize outbox publisher", zap.Error(err))
+	}
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		outbox.NewRelay(outboxEvents, publisher, cfg.Outbox).RunAsLeader(relayCtx, locker)
+	}()
 
 	// Initialize services
-	userService := service.NewUserService(userRepo, redisCache)
+	userService := service.NewUserService(userRepo, txManager, outbox.NewOutboxFrom(outboxEvents), appCache, locker, cfg.User)
+	preferenceService, err := service.NewPreferenceService(preferenceRepo, txManager, appCache, cfg.Preferences)
+	if err != nil {
+		logger.Logger.Fatal("Invalid preferences schema", zap.Error(err))
+	}
+
+	// Initialize user search, the index follows user changes through a hook
+	searchIndex, err := service.NewUserSearchIndex(cfg.Search, db)
+	if err != nil {
+		logger.Logger.Fatal("Failed to initialize search index", zap.Error(err))
+	}
+	userSearchService := service.NewUserSearchService(userRepo, searchIndex)
+	if err := userSearchService.Rebuild(context.Background()); err != nil {
+		logger.Logger.Fatal("Failed to build search index", zap.Error(err))
+	}
+	userService.AddHook(userSearchService)
+	rebuildCtx, stopRebuild := context.WithCancel(context.Background())
+	go userSearchService.RunRebuild(rebuildCtx, cfg.Search.RebuildInterval)
 
 	// Initialize handlers
 	userHandler := api.NewUserHandler(userService, cfg)
-	healthHandler := api.NewHealthHandler(db)
+	// 缓存和 outbox 都不使用 Redis 时健康检查不报告 Redis 的状态
+	healthBreaker := redisBreaker
+	if cfg.Cache.Driver == cache.DriverMemory && cfg.Outbox.Publisher != outbox.PublisherRedis {
+		healthBreaker = nil
+	}
+	healthHandler := api.NewHealthHandler(db, healthBreaker)
+	preferenceHandler := api.NewPreferenceHandler(preferenceService)
+	cacheHandler := api.NewCacheHandler(appCache, cache.DefaultMetrics)
+	userSearchHandler := api.NewUserSearchHandler(userSearchService)
 
 	// Create Gin engine
 	r := gin.Default()
 
 	// Setup middleware
+	r.Use(middleware.RequestIDMiddleware())
 	r.Use(middleware.ErrorHandler())
 	r.Use(middleware.CORSMiddleware())
+	if cfg.Server.RequestTimeout > 0 {
+		r.Use(middleware.TimeoutMiddleware(cfg.Server.RequestTimeout))
+	}
+	if len(cfg.Database.Replicas.Nodes) > 0 {
+		r.Use(middleware.ReadYourWritesMiddleware(cfg.Database.Replicas.StickyWindow))
+	}
 
 	// Setup routes
-	router.SetupRouter(r, userHandler, cfg, healthHandler)
+	router.SetupRouter(r, userHandler, cfg, healthHandler, preferenceHandler, cacheHandler, userSearchHandler)
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -94,9 +186,23 @@ func main() {
 		logger.Logger.Fatal("Server forced to shutdown:", zap.Error(err))
 	}
 
-	// Close database connection
-	if sqlDB, err := db.DB(); err == nil {
-		sqlDB.Close()
+	// Stop the outbox relay, undelivered events are picked up after restart
+	stopRelay()
+	<-relayDone
+	stopRebuild()
+
+	// Close cache and Redis connections
+	if closer, ok := appCache.(io.Closer); ok {
+		closer.Close()
+	}
+	if err := redisClient.Close(); err != nil {
+		logger.Logger.Warn("Failed to close redis client", zap.Error(err))
+	}
+
+	// Close database connections
+	dbStats.Close()
+	if err := database.Close(db); err != nil {
+		logger.Logger.Warn("Failed to close database", zap.Error(err))
 	}
 
 	logger.Logger.Info("Server exiting")
@@ -0,0 +1,52 @@
+// cache 命令用于在部署时清理 Redis 中某个命名空间下的缓存：
+//
+//	go run ./cmd/cache -prefix "go-stater:prod:v1:"
+//
+// 未指定 -prefix 时使用配置中的 cache.key_prefix
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/pkg/cache"
+)
+
+func main() {
+	prefix := flag.String("prefix", "", "key prefix to flush (defaults to cache.key_prefix)")
+	timeout := flag.Duration("timeout", 5*time.Minute, "overall timeout")
+	configOpts := config.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := config.Load(*configOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *prefix == "" {
+		*prefix = cfg.Cache.KeyPrefix
+	}
+	// 空前缀会删除整个 Redis 库中的所有 key，这里直接拒绝
+	if *prefix == "" {
+		log.Fatal("refusing to flush with an empty prefix")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client, _, err := cache.NewRedisClient(cfg.Redis)
+	if err != nil {
+		log.Fatalf("create redis client: %v", err)
+	}
+	defer client.Close()
+	c := cache.NewRedisCacheWithClient(client)
+
+	deleted, err := c.DeleteByPrefix(ctx, *prefix)
+	if err != nil {
+		log.Fatalf("flush %q: deleted %d keys before error: %v", *prefix, deleted, err)
+	}
+	fmt.Printf("flushed %d keys with prefix %q\n", deleted, *prefix)
+}
@@ -0,0 +1,89 @@
+// encrypt 命令管理字段级加密的密钥和已有数据：
+//
+//	go run ./cmd/encrypt -genkey    # 生成一个新密钥，写入 encryption.keys 或密钥文件
+//	go run ./cmd/encrypt            # 用主密钥重新加密未加密或用旧密钥加密的数据，并计算盲索引
+//	go run ./cmd/encrypt -all       # 重新加密所有数据，更换 blind_index_key 之后使用
+//	go run ./cmd/encrypt -dry-run   # 只统计需要重新加密的行数
+//
+// 轮换密钥的步骤：生成新密钥加入 encryption.keys 并设为 primary_key，部署后运行本命令，
+// 完成后才能从配置中移除旧密钥。命令可以在服务运行时执行，中断后重新运行即可
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/migrations"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/encryption"
+)
+
+var usage = `usage: encrypt [flags]
+
+flags:
+  -genkey            print a new random key and exit
+  -all               rewrite every row, not only rows that are unencrypted or use an old key
+  -dry-run           only count the rows that need to be rewritten
+  -batch int         rows per batch (default 500)
+  -timeout duration  overall timeout (default 1h)
+` + config.FlagsUsage
+
+func main() {
+	genkey := flag.Bool("genkey", false, "print a new random key")
+	all := flag.Bool("all", false, "rewrite every row")
+	dryRun := flag.Bool("dry-run", false, "only count the rows that need to be rewritten")
+	batch := flag.Int("batch", 500, "rows per batch")
+	timeout := flag.Duration("timeout", time.Hour, "overall timeout")
+	configOpts := config.BindFlags(flag.CommandLine)
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if *genkey {
+		key, err := encryption.GenerateKey()
+		if err != nil {
+			log.Fatalf("generate key: %v", err)
+		}
+		fmt.Println(key)
+		return
+	}
+
+	cfg, err := config.Load(*configOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	keyring, err := encryption.InitKeyring(cfg)
+	if err != nil {
+		log.Fatalf("load encryption keys: %v", err)
+	}
+
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		log.Fatalf("connect to database: %v", err)
+	}
+	defer database.Close(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := migrations.Run(ctx, db, cfg.Database); err != nil {
+		log.Fatalf("migrations: %v", err)
+	}
+
+	stats, err := encryption.Rotate[model.User](ctx, db, encryption.RotateOptions{
+		BatchSize: *batch,
+		All:       *all,
+		Columns:   []string{"email_hash"},
+		DryRun:    *dryRun,
+	})
+	if err != nil {
+		log.Fatalf("users: %v", err)
+	}
+	fmt.Printf("users: scanned %d, pending %d, updated %d (primary key %s)\n",
+		stats.Scanned, stats.Pending, stats.Updated, keyring.PrimaryKeyID())
+}
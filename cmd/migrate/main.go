@@ -0,0 +1,141 @@
+// migrate 命令用于管理数据库迁移：
+//
+//	go run ./cmd/migrate up              # 执行所有未执行的迁移
+//	go run ./cmd/migrate down -steps 1   # 回滚最近的迁移
+//	go run ./cmd/migrate status          # 查看迁移状态
+//	go run ./cmd/migrate create add_user_avatar
+//
+// create 会在每个驱动的目录（migrations/mysql、postgres、sqlite）下生成一对空的 up/down 文件
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/migrations"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/encryption"
+	"github.com/jtsang4/go-stater/pkg/migrate"
+)
+
+var usage = `usage: migrate <command> [flags]
+
+commands:
+  up                 apply all pending migrations
+  down [-steps N]    revert the last N migrations (default 1)
+  status             show applied and pending migrations
+  create <name>      create empty up/down SQL files for every driver
+
+flags:
+` + config.FlagsUsage
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	steps := fs.Int("steps", 1, "number of migrations to revert (down)")
+	dir := fs.String("dir", "migrations", "migrations directory (create)")
+	timeout := fs.Duration("timeout", 10*time.Minute, "overall timeout")
+	configOpts := config.BindFlags(fs)
+	fs.Parse(args)
+
+	if command == "create" {
+		create(*dir, strings.Join(fs.Args(), "_"))
+		return
+	}
+
+	cfg, err := config.Load(*configOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 部分迁移会读写加密列
+	if _, err := encryption.InitKeyring(cfg); err != nil {
+		log.Fatalf("load encryption keys: %v", err)
+	}
+
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		log.Fatalf("connect to database: %v", err)
+	}
+	defer database.Close(db)
+	m, err := migrations.NewMigrator(db, cfg.Database)
+	if err != nil {
+		log.Fatalf("load migrations: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch command {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied  %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		if *steps <= 0 {
+			log.Fatal("-steps must be positive")
+		}
+		reverted, err := m.Down(ctx, *steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printStatus(statuses)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func create(dir, name string) {
+	now := time.Now()
+	for _, driver := range []string{database.DriverMySQL, database.DriverPostgres, database.DriverSQLite} {
+		up, down, err := migrate.Create(filepath.Join(dir, driver), name, now)
+		if err != nil {
+			log.Fatalf("create migration: %v", err)
+		}
+		fmt.Printf("created %s\ncreated %s\n", up, down)
+	}
+}
+
+func printStatus(statuses []migrate.Status) {
+	pending := 0
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Missing:
+			state = "applied (unknown to this build)"
+		case s.Applied:
+			state = "applied " + s.AppliedAt.Local().Format(time.DateTime)
+		default:
+			pending++
+		}
+		fmt.Printf("%d_%s\t%s\n", s.Version, s.Name, state)
+	}
+	fmt.Printf("%d pending\n", pending)
+}
@@ -0,0 +1,94 @@
+// seed 命令将种子数据写入数据库，重复运行不会产生重复数据：
+//
+//	go run ./cmd/seed dev          # 管理员 admin/admin123 和两个普通用户
+//	go run ./cmd/seed demo e2e     # 运行多个种子集
+//	go run ./cmd/seed -list        # 列出可用的种子集
+//
+// 运行前会按 database.migrate 配置执行或检查迁移。server.mode 为 release 时，
+// 创建公开密码账号的种子集（seeds.InsecureSets）需要加 -force 才会运行
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/migrations"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/encryption"
+	"github.com/jtsang4/go-stater/seeds"
+)
+
+var usage = `usage: seed [flags] <set>...
+
+flags:
+  -list              list available seed sets
+  -force             allow dev, demo and e2e when server.mode is release
+  -timeout duration  overall timeout (default 5m)
+` + config.FlagsUsage
+
+func main() {
+	list := flag.Bool("list", false, "list available seed sets")
+	force := flag.Bool("force", false, "allow sets with well-known passwords in release mode")
+	timeout := flag.Duration("timeout", 5*time.Minute, "overall timeout")
+	configOpts := config.BindFlags(flag.CommandLine)
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if !*list && flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Server.Mode == gin.ReleaseMode && !*force {
+		for _, name := range flag.Args() {
+			if slices.Contains(seeds.InsecureSets, name) {
+				log.Fatalf("refusing to seed %q with well-known passwords while server.mode is release, use -force to override", name)
+			}
+		}
+	}
+	if _, err := encryption.InitKeyring(cfg); err != nil {
+		log.Fatalf("load encryption keys: %v", err)
+	}
+
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		log.Fatalf("connect to database: %v", err)
+	}
+	defer database.Close(db)
+
+	seeder, err := seeds.NewSeeder(db)
+	if err != nil {
+		log.Fatalf("load seeds: %v", err)
+	}
+	if *list {
+		for _, name := range seeder.Sets() {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := migrations.Run(ctx, db, cfg.Database); err != nil {
+		log.Fatalf("migrations: %v", err)
+	}
+
+	refs, err := seeder.Run(ctx, flag.Args()...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("seeded %s, aliases: %s\n", strings.Join(flag.Args(), ", "), strings.Join(refs.Aliases(), ", "))
+}
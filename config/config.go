@@ -1,36 +1,86 @@
 package config
 
-import (
-	"log"
-	"time"
-
-	"github.com/spf13/viper"
-)
+import "time"
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Logger   LoggerConfig   `mapstructure:"logger"`
-	Redis    RedisConfig    `mapstructure:"redis"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	JWT         JWTConfig         `mapstructure:"jwt"`
+	Logger      LoggerConfig      `mapstructure:"logger"`
+	Redis       RedisConfig       `mapstructure:"redis"`
+	Cache       CacheConfig       `mapstructure:"cache"`
+	Preferences PreferencesConfig `mapstructure:"preferences"`
+	User        UserConfig        `mapstructure:"user"`
+	Outbox      OutboxConfig      `mapstructure:"outbox"`
+	Search      SearchConfig      `mapstructure:"search"`
+	Encryption  EncryptionConfig  `mapstructure:"encryption"`
 }
 
 type ServerConfig struct {
-	Port string `mapstructure:"port"`
-	Mode string `mapstructure:"mode"`
+	Port           string        `mapstructure:"port"`
+	Mode           string        `mapstructure:"mode"`
+	RequestTimeout time.Duration `mapstructure:"request_timeout"` // 单个请求的处理时间上限，0 表示不限制
 }
 
 type DatabaseConfig struct {
-	Driver          string        `mapstructure:"driver"`
+	Driver          string        `mapstructure:"driver"` // mysql, postgres or sqlite
 	Host            string        `mapstructure:"host"`
 	Port            int           `mapstructure:"port"`
 	Username        string        `mapstructure:"username"`
 	Password        string        `mapstructure:"password"`
 	DBName          string        `mapstructure:"dbname"`
-	Charset         string        `mapstructure:"charset"`
+	Charset         string        `mapstructure:"charset"` // mysql
 	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
 	MaxOpenConns    int           `mapstructure:"max_open_conns"`
-	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`  // 必须带单位，例如 1h
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"` // 空闲连接的最长保留时间，0 表示不限制
+	ConnectTimeout  time.Duration `mapstructure:"connect_timeout"`    // 启动时连接失败的重试时间上限，0 表示不重试
+	ConnectBackoff  time.Duration `mapstructure:"connect_backoff"`    // 第一次重试的等待时间，之后每次翻倍，0 表示 500ms
+	StatsInterval   time.Duration `mapstructure:"stats_interval"`     // 采集连接池统计的间隔，0 表示不采集
+	Migrate         string        `mapstructure:"migrate"`            // auto, check or off
+	Log             DBLogConfig   `mapstructure:"log"`
+
+	Postgres PostgresConfig `mapstructure:"postgres"`
+	SQLite   SQLiteConfig   `mapstructure:"sqlite"`
+	Replicas ReplicasConfig `mapstructure:"replicas"`
+}
+
+// ReplicasConfig 配置只读从库，读请求按 Policy 分发到健康的从库，写请求和事务使用主库
+type ReplicasConfig struct {
+	Nodes               []ReplicaNodeConfig `mapstructure:"nodes"`
+	Policy              string              `mapstructure:"policy"`                // random, round_robin or least_conn
+	StickyWindow        time.Duration       `mapstructure:"sticky_window"`         // 写入后这段时间内同一请求或会话的读请求使用主库
+	HealthCheckInterval time.Duration       `mapstructure:"health_check_interval"` // 0 表示不检查
+}
+
+// ReplicaNodeConfig 中未设置的字段沿用主库的配置
+type ReplicaNodeConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Path     string `mapstructure:"path"` // sqlite
+}
+
+// DBLogConfig 配置 SQL 日志，日志通过 logger.Logger 输出
+type DBLogConfig struct {
+	Level             string        `mapstructure:"level"`                // silent, error, warn (慢查询) or info (所有查询)，默认 warn
+	SlowThreshold     time.Duration `mapstructure:"slow_threshold"`       // 0 表示 200ms，负数表示不记录慢查询
+	LogParams         bool          `mapstructure:"log_params"`           // 记录参数值，默认以占位符代替，避免泄露密码等敏感数据
+	LogRecordNotFound bool          `mapstructure:"log_record_not_found"` // 将 gorm.ErrRecordNotFound 作为错误记录
+}
+
+type PostgresConfig struct {
+	SSLMode    string `mapstructure:"sslmode"`     // disable, require, verify-ca or verify-full
+	SearchPath string `mapstructure:"search_path"` // 例如 app,public
+	TimeZone   string `mapstructure:"timezone"`
+}
+
+type SQLiteConfig struct {
+	Path        string        `mapstructure:"path"`         // 数据库文件路径，":memory:" 表示内存数据库
+	WAL         bool          `mapstructure:"wal"`          // 启用 WAL 模式，读写可以并发
+	BusyTimeout time.Duration `mapstructure:"busy_timeout"` // 数据库被锁定时的等待时间
+	ForeignKeys bool          `mapstructure:"foreign_keys"`
 }
 
 type JWTConfig struct {
@@ -48,24 +98,127 @@ type LoggerConfig struct {
 }
 
 type RedisConfig struct {
-	Addr     string `mapstructure:"addr"`
-	Password string `mapstructure:"password"`
-	DB       int    `mapstructure:"db"`
+	Mode             string   `mapstructure:"mode"`  // single, sentinel or cluster
+	Addr             string   `mapstructure:"addr"`  // single 模式的地址
+	Addrs            []string `mapstructure:"addrs"` // sentinel 或 cluster 模式的节点地址
+	MasterName       string   `mapstructure:"master_name"`
+	Username         string   `mapstructure:"username"`
+	Password         string   `mapstructure:"password"`
+	SentinelPassword string   `mapstructure:"sentinel_password"`
+	DB               int      `mapstructure:"db"` // cluster 模式不支持
+
+	DialTimeout  time.Duration `mapstructure:"dial_timeout"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	PoolSize     int           `mapstructure:"pool_size"` // 0 表示每个 CPU 10 个连接
+	MinIdleConns int           `mapstructure:"min_idle_conns"`
+	PoolTimeout  time.Duration `mapstructure:"pool_timeout"`
+
+	TLS            RedisTLSConfig       `mapstructure:"tls"`
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+}
+
+type RedisTLSConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	ServerName         string `mapstructure:"server_name"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `mapstructure:"failure_threshold"` // 连续失败多少次后熔断，0 表示 5
+	OpenTimeout      time.Duration `mapstructure:"open_timeout"`      // 熔断后多久尝试恢复，0 表示 10s
 }
 
-func LoadConfig() *Config {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath("./config")
+type CacheConfig struct {
+	Driver    string            `mapstructure:"driver"`     // memory, redis or tiered
+	KeyPrefix string            `mapstructure:"key_prefix"` // 所有 key 的全局前缀，例如 app:env:version:
+	L1TTL     time.Duration     `mapstructure:"l1_ttl"`     // tiered 模式下进程内缓存的最长保留时间
+	Memory    MemoryCacheConfig `mapstructure:"memory"`
 
-	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("Error reading config file: %s", err)
-	}
+	InvalidationChannel string `mapstructure:"invalidation_channel"` // tiered 模式下广播失效消息的 Redis 频道
 
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		log.Fatalf("Unable to decode into config struct: %s", err)
-	}
+	Codec                string `mapstructure:"codec"`                 // json, msgpack or gob
+	Compression          string `mapstructure:"compression"`           // none, zstd or snappy
+	CompressionThreshold int    `mapstructure:"compression_threshold"` // 超过该字节数才压缩，0 表示 1024
+}
+
+type MemoryCacheConfig struct {
+	MaxEntries int    `mapstructure:"max_entries"` // 0 表示不限制
+	Eviction   string `mapstructure:"eviction"`    // lru or lfu
+}
+
+type UserConfig struct {
+	CacheTTL                time.Duration `mapstructure:"cache_ttl"`
+	CacheStaleTTL           time.Duration `mapstructure:"cache_stale_ttl"`           // 过期后仍可返回旧值并后台刷新的时间
+	CacheNegativeTTL        time.Duration `mapstructure:"cache_negative_ttl"`        // 缓存 "用户不存在" 的时间
+	CacheJitter             float64       `mapstructure:"cache_jitter"`              // TTL 随机抖动比例
+	CacheLoadTimeout        time.Duration `mapstructure:"cache_load_timeout"`        // 缓存未命中时从数据库加载的时间上限，0 表示 30s
+	UsernameChangeDays      int           `mapstructure:"username_change_days"`      // 两次修改用户名的最小间隔，单位：天
+	UsernameReservationDays int           `mapstructure:"username_reservation_days"` // 旧用户名的保留期，单位：天
+}
+
+// OutboxConfig 配置领域事件的投递
+type OutboxConfig struct {
+	Publisher    string        `mapstructure:"publisher"`     // log, redis or webhook
+	PollInterval time.Duration `mapstructure:"poll_interval"` // 0 表示 1s
+	BatchSize    int           `mapstructure:"batch_size"`    // 每次轮询最多投递的事件数，0 表示 100
+	MaxAttempts  int           `mapstructure:"max_attempts"`  // 超过后放弃投递该事件，0 表示一直重试
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"` // 第一次重试的等待时间，之后每次翻倍，0 表示 1s
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`   // 0 表示 5m
+	Retention    time.Duration `mapstructure:"retention"`     // 已投递事件的保留时间，0 表示不清理
+	LeaderTTL    time.Duration `mapstructure:"leader_ttl"`    // 多实例时只有一个实例投递，0 表示 15s
+
+	Redis   OutboxRedisConfig   `mapstructure:"redis"`
+	Webhook OutboxWebhookConfig `mapstructure:"webhook"`
+}
+
+type OutboxRedisConfig struct {
+	Stream string `mapstructure:"stream"`  // 为空时使用 outbox:events
+	MaxLen int64  `mapstructure:"max_len"` // 近似保留的最大长度，0 表示不裁剪
+}
+
+type OutboxWebhookConfig struct {
+	URL     string        `mapstructure:"url"`
+	Secret  string        `mapstructure:"secret"`  // 非空时对请求体签名（X-Signature-256）
+	Timeout time.Duration `mapstructure:"timeout"` // 0 表示 10s
+}
+
+// SearchConfig 配置用户搜索
+type SearchConfig struct {
+	Backend         string        `mapstructure:"backend"`          // db (数据库全文索引) or memory (进程内索引，适合单实例的小规模部署)
+	RebuildInterval time.Duration `mapstructure:"rebuild_interval"` // memory：定期从数据库重建索引，同步其他实例的修改，0 表示只在启动时构建
+}
+
+// EncryptionConfig 配置字段级加密（例如用户邮箱）。密钥为 base64 编码的 32 字节，可以用 go run ./cmd/encrypt -genkey 生成
+type EncryptionConfig struct {
+	PrimaryKey        string                `mapstructure:"primary_key"`          // 加密新数据使用的密钥 ID，其他密钥只用于解密
+	Keys              []EncryptionKeyConfig `mapstructure:"keys"`                 // 轮换后旧密钥需要保留，直到 cmd/encrypt 重新加密了全部数据
+	BlindIndexKey     string                `mapstructure:"blind_index_key"`      // 计算盲索引的 HMAC 密钥，更换后需要运行 cmd/encrypt -all
+	BlindIndexKeyFile string                `mapstructure:"blind_index_key_file"` // blind_index_key 为空时从文件读取
+}
+
+type EncryptionKeyConfig struct {
+	ID   string `mapstructure:"id"`
+	Key  string `mapstructure:"key"`
+	File string `mapstructure:"file"` // key 为空时从文件读取，例如挂载的 Kubernetes Secret
+}
+
+type PreferencesConfig struct {
+	CacheTTL time.Duration     `mapstructure:"cache_ttl"`
+	Fields   []PreferenceField `mapstructure:"fields"`
+}
 
-	return &config
+// PreferenceField 描述一个用户偏好设置项
+type PreferenceField struct {
+	Name      string            `mapstructure:"name"`
+	Type      string            `mapstructure:"type"` // string, bool, int, number, timezone, object
+	Default   interface{}       `mapstructure:"default"`
+	Enum      []string          `mapstructure:"enum"`       // 仅 string 类型
+	MaxLength int               `mapstructure:"max_length"` // 仅 string 类型
+	Min       *float64          `mapstructure:"min"`        // 仅 int/number 类型
+	Max       *float64          `mapstructure:"max"`        // 仅 int/number 类型
+	Fields    []PreferenceField `mapstructure:"fields"`     // 仅 object 类型
 }
@@ -0,0 +1,264 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix 是覆盖配置的环境变量前缀，键中的 . 换成 _，例如 database.password 对应 APP_DATABASE_PASSWORD
+const EnvPrefix = "APP_"
+
+// DefaultFile 是未指定配置文件时查找的路径，依次相对于工作目录和可执行文件所在目录
+const DefaultFile = "config/config.yaml"
+
+// Options 指定配置的来源。Load 按以下顺序合并配置，后面的覆盖前面的：
+//
+//  1. 配置文件：Files（--config，可重复），未指定时为 APP_CONFIG（逗号分隔），都为空时查找 DefaultFile
+//  2. 环境配置文件：Profile（--profile 或 APP_PROFILE）不为空时，合并每个配置文件同目录的 <name>.<profile>.yaml，
+//     例如 config.production.yaml。map 逐个键合并，列表整体替换
+//  3. .env 文件：EnvFile（--env-file 或 APP_ENV_FILE），未指定时使用工作目录下的 .env（不存在时忽略）。
+//     只作为配置的来源，不会修改进程的环境变量
+//  4. 环境变量：APP_ 开头，例如 APP_DATABASE_PASSWORD、APP_REDIS_ADDRS=a:6379,b:6379。
+//     结构体列表使用 JSON，例如 APP_ENCRYPTION_KEYS='[{"id":"k1","key":"..."}]'
+//  5. 命令行参数：Set（--set key=value，可重复）
+type Options struct {
+	Files   []string
+	Profile string
+	EnvFile string
+	Set     []string
+	Environ []string // 环境变量，nil 表示 os.Environ()
+}
+
+// FlagsUsage 是 BindFlags 注册的参数的说明，用于命令的 usage
+const FlagsUsage = `  -config file        config file, repeat to merge several (default $APP_CONFIG or ` + DefaultFile + `)
+  -profile name       also merge <config>.<profile>.yaml, e.g. production (default $APP_PROFILE)
+  -env-file file      load APP_ variables from this file (default $APP_ENV_FILE or .env if present)
+  -set key=value      override a config key, repeatable
+`
+
+// BindFlags 在 fs 上注册 --config、--profile、--env-file 和 --set，fs 解析后返回值即为对应的 Options
+func BindFlags(fs *flag.FlagSet) *Options {
+	opts := &Options{}
+	fs.Var((*listFlag)(&opts.Files), "config", "config file, repeat to merge several (default $APP_CONFIG or "+DefaultFile+")")
+	fs.StringVar(&opts.Profile, "profile", "", "merge <config>.<profile>.yaml, e.g. production (default $APP_PROFILE)")
+	fs.StringVar(&opts.EnvFile, "env-file", "", "load APP_ variables from this file (default $APP_ENV_FILE or .env if present)")
+	fs.Var((*listFlag)(&opts.Set), "set", "override a key, e.g. -set database.port=3307, repeatable")
+	return opts
+}
+
+// Load 按 Options 中描述的顺序读取配置
+func Load(opts Options) (*Config, error) {
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	env := make(map[string]string)
+
+	// .env 的位置只能由参数或真实的环境变量指定
+	real := parseEnviron(environ)
+	envFile, required := opts.EnvFile, opts.EnvFile != ""
+	if envFile == "" {
+		envFile, required = real[EnvPrefix+"ENV_FILE"], real[EnvPrefix+"ENV_FILE"] != ""
+	}
+	if envFile == "" {
+		envFile = ".env"
+	}
+	dotenv, err := readDotEnv(envFile)
+	if err != nil && (required || !errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	for k, v := range dotenv {
+		env[k] = v
+	}
+	for k, v := range real {
+		env[k] = v
+	}
+
+	files := opts.Files
+	if len(files) == 0 && env[EnvPrefix+"CONFIG"] != "" {
+		files = splitList(env[EnvPrefix+"CONFIG"])
+	}
+	if len(files) == 0 {
+		file, err := findDefaultFile()
+		if err != nil {
+			return nil, err
+		}
+		files = []string{file}
+	}
+	profile := opts.Profile
+	if profile == "" {
+		profile = env[EnvPrefix+"PROFILE"]
+	}
+
+	v := viper.New()
+	for _, file := range files {
+		if err := mergeFile(v, file); err != nil {
+			return nil, err
+		}
+	}
+	if profile != "" {
+		found := false
+		for _, file := range files {
+			overlay := profileFile(file, profile)
+			if _, err := os.Stat(overlay); err != nil {
+				continue
+			}
+			if err := mergeFile(v, overlay); err != nil {
+				return nil, err
+			}
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("config: profile %q: no %s found", profile, filepath.Base(profileFile(files[0], profile)))
+		}
+	}
+
+	keys := Keys()
+	for _, key := range keys {
+		if value, ok := env[EnvName(key)]; ok {
+			v.Set(key, value)
+		}
+	}
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key] = true
+	}
+	for _, kv := range opts.Set {
+		key, value, ok := strings.Cut(kv, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || !known[key] {
+			return nil, fmt.Errorf("config: invalid -set %q, want a known key=value", kv)
+		}
+		v.Set(key, value)
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		jsonHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))); err != nil {
+		return nil, fmt.Errorf("config: decode: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Keys 返回 Config 的所有键，例如 database.password。嵌套结构体展开，列表和 map 作为一个键
+func Keys() []string {
+	var keys []string
+	collectKeys(reflect.TypeOf(Config{}), "", &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+// EnvName 返回覆盖 key 的环境变量名
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+func collectKeys(t reflect.Type, prefix string, keys *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		key := prefix + tag
+		if field.Type.Kind() == reflect.Struct {
+			collectKeys(field.Type, key+".", keys)
+			continue
+		}
+		*keys = append(*keys, key)
+	}
+}
+
+func mergeFile(v *viper.Viper, file string) error {
+	v.SetConfigFile(file)
+	if err := v.MergeInConfig(); err != nil {
+		return fmt.Errorf("config: read %s: %w", file, err)
+	}
+	return nil
+}
+
+// profileFile 返回 file 对应的环境配置文件，例如 config/config.yaml 对应 config/config.production.yaml
+func profileFile(file, profile string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + profile + ext
+}
+
+func findDefaultFile() (string, error) {
+	candidates := []string{DefaultFile}
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(exe), DefaultFile))
+	}
+	for _, file := range candidates {
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("config: no config file found (tried %s), use --config or %sCONFIG", strings.Join(candidates, ", "), EnvPrefix)
+}
+
+// jsonHook 将 JSON 字符串解码为列表、map 或结构体，用于从环境变量或 --set 设置结构体列表
+func jsonHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	s, ok := data.(string)
+	if !ok || from.Kind() != reflect.String {
+		return data, nil
+	}
+	switch to.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Struct:
+	default:
+		return data, nil
+	}
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") && !strings.HasPrefix(s, "{") {
+		return data, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return nil, fmt.Errorf("invalid JSON %q: %w", s, err)
+	}
+	return value, nil
+}
+
+func parseEnviron(environ []string) map[string]string {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, EnvPrefix) {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// listFlag 是可以重复的命令行参数
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
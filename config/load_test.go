@@ -0,0 +1,168 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+const baseYAML = `
+server:
+  port: 8080
+  mode: debug
+database:
+  port: 3306
+  password: from-file
+  sqlite:
+    busy_timeout: 1s
+logger:
+  level: debug
+redis:
+  addrs: [a:6379]
+`
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "config.yaml", baseYAML)
+	writeFile(t, dir, "config.production.yaml", `
+server:
+  mode: release
+database:
+  port: 3307
+logger:
+  level: info
+`)
+	envFile := writeFile(t, dir, ".env", `
+# 开发环境
+export APP_DATABASE_PASSWORD="from-dotenv"
+APP_SERVER_PORT=9000
+APP_LOGGER_LEVEL=warn
+`)
+
+	cfg, err := Load(Options{
+		Files:   []string{base},
+		Profile: "production",
+		EnvFile: envFile,
+		Set:     []string{"logger.level=error"},
+		Environ: []string{
+			"APP_SERVER_PORT=9090",
+			"APP_REDIS_ADDRS=x:6379,y:6379",
+			"APP_DATABASE_SQLITE_BUSY_TIMEOUT=3s",
+			`APP_ENCRYPTION_KEYS=[{"id":"k1","file":"/run/secrets/k1"}]`,
+			"DATABASE_PASSWORD=ignored-without-prefix",
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "release", cfg.Server.Mode, "profile overrides the base file")
+	assert.Equal(t, 3307, cfg.Database.Port, "profile overrides the base file")
+	assert.Equal(t, "from-dotenv", cfg.Database.Password, ".env overrides files")
+	assert.Equal(t, "9090", cfg.Server.Port, "environment overrides .env")
+	assert.Equal(t, "error", cfg.Logger.Level, "-set overrides everything")
+	assert.Equal(t, []string{"x:6379", "y:6379"}, cfg.Redis.Addrs)
+	assert.Equal(t, 3*time.Second, cfg.Database.SQLite.BusyTimeout)
+	assert.Equal(t, []EncryptionKeyConfig{{ID: "k1", File: "/run/secrets/k1"}}, cfg.Encryption.Keys)
+}
+
+func TestLoadFilesFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "base.yaml", baseYAML)
+	local := writeFile(t, dir, "local.yaml", "server:\n  port: 7000\n")
+	writeFile(t, dir, "local.staging.yaml", "server:\n  mode: test\n")
+
+	_, err := Load(Options{Environ: []string{
+		"APP_CONFIG=" + base + "," + local,
+		"APP_PROFILE=staging",
+		"APP_ENV_FILE=" + filepath.Join(dir, "missing.env"),
+	}})
+	assert.Error(t, err, "an explicit .env file must exist")
+
+	cfg, err := Load(Options{Environ: []string{"APP_CONFIG=" + base + "," + local, "APP_PROFILE=staging"}})
+	require.NoError(t, err)
+	assert.Equal(t, "7000", cfg.Server.Port, "later files override earlier ones")
+	assert.Equal(t, "test", cfg.Server.Mode, "profile overlay of any file is merged")
+	assert.Equal(t, "from-file", cfg.Database.Password)
+
+	// 参数优先于环境变量
+	cfg, err = Load(Options{Files: []string{base}, Environ: []string{"APP_CONFIG=" + local}})
+	require.NoError(t, err)
+	assert.Equal(t, "8080", cfg.Server.Port)
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "config.yaml", baseYAML)
+
+	_, err := Load(Options{Files: []string{base}, Profile: "production", Environ: []string{}})
+	assert.ErrorContains(t, err, "config.production.yaml")
+
+	_, err = Load(Options{Files: []string{base}, Set: []string{"database.pasword=x"}, Environ: []string{}})
+	assert.ErrorContains(t, err, "database.pasword")
+
+	_, err = Load(Options{Files: []string{filepath.Join(dir, "missing.yaml")}, Environ: []string{}})
+	assert.Error(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	_, err = Load(Options{Environ: []string{}})
+	assert.ErrorContains(t, err, "no config file found")
+}
+
+func TestBindFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts := BindFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--config", "a.yaml", "-config=b.yaml", "--profile", "production", "--set", "server.port=1", "--set", "server.mode=release"}))
+	assert.Equal(t, []string{"a.yaml", "b.yaml"}, opts.Files)
+	assert.Equal(t, "production", opts.Profile)
+	assert.Equal(t, []string{"server.port=1", "server.mode=release"}, opts.Set)
+}
+
+func TestKeys(t *testing.T) {
+	keys := Keys()
+	assert.Contains(t, keys, "database.password")
+	assert.Contains(t, keys, "database.replicas.nodes")
+	assert.Contains(t, keys, "encryption.keys")
+	assert.Equal(t, "APP_DATABASE_PASSWORD", EnvName("database.password"))
+
+	seen := make(map[string]string)
+	for _, key := range keys {
+		name := EnvName(key)
+		assert.NotContains(t, seen, name, "%s and %s map to the same variable", seen[name], key)
+		seen[name] = key
+	}
+}
+
+func TestReadDotEnv(t *testing.T) {
+	path := writeFile(t, t.TempDir(), ".env", `
+A=plain # comment
+export B='single # not a comment'
+C="double \"quoted\"\nline"
+D=
+# E=commented out
+`)
+	env, err := readDotEnv(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"A": "plain",
+		"B": "single # not a comment",
+		"C": "double \"quoted\"\nline",
+		"D": "",
+	}, env)
+
+	_, err = readDotEnv(writeFile(t, t.TempDir(), ".env", "NOT A PAIR\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jtsang4/go-stater/pkg/cache"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/jtsang4/go-stater/pkg/response"
+	"go.uber.org/zap"
+)
+
+// CacheHandler 提供缓存调试接口，仅管理员可用。key 不包含全局前缀
+type CacheHandler struct {
+	cache   cache.RedisCacheInterface
+	metrics *cache.Metrics
+}
+
+func NewCacheHandler(c cache.RedisCacheInterface, metrics *cache.Metrics) *CacheHandler {
+	return &CacheHandler{cache: c, metrics: metrics}
+}
+
+// Stats 返回各命名空间的命中、未命中、错误、淘汰和回源加载统计
+func (h *CacheHandler) Stats(c *gin.Context) {
+	response.Success(c, h.metrics.Snapshot())
+}
+
+// InspectKey 返回 key 的大小、剩余 TTL 和编码格式
+func (h *CacheHandler) InspectKey(c *gin.Context) {
+	info, ok := h.inspect(c)
+	if !ok {
+		return
+	}
+	response.Success(c, info)
+}
+
+// GetKey 返回 key 解码后的值
+func (h *CacheHandler) GetKey(c *gin.Context) {
+	info, ok := h.inspect(c)
+	if !ok {
+		return
+	}
+
+	value, err := info.Value()
+	if err != nil {
+		response.Error(c, http.StatusUnprocessableEntity, "failed to decode value: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"key": info.Key, "value": value})
+}
+
+// EvictKey 删除 key，tiered 模式下同时通知其他实例
+func (h *CacheHandler) EvictKey(c *gin.Context) {
+	key := c.Param("key")
+	if err := h.cache.Delete(c.Request.Context(), key); err != nil {
+		logger.FromContext(c.Request.Context()).Error("failed to evict cache key", zap.String("key", key), zap.Error(err))
+		response.InternalError(c, "failed to evict key")
+		return
+	}
+
+	logger.FromContext(c.Request.Context()).Info("cache key evicted", zap.String("key", key), zap.Uint("by", c.GetUint("user_id")))
+	response.Success(c, gin.H{"key": key})
+}
+
+func (h *CacheHandler) inspect(c *gin.Context) (*cache.KeyInfo, bool) {
+	key := c.Param("key")
+	info, err := cache.Inspect(c.Request.Context(), h.cache, key)
+	switch {
+	case err == nil:
+		return info, true
+	case errors.Is(err, cache.ErrMiss):
+		response.NotFound(c, "key not found")
+	case errors.Is(err, cache.ErrInspectUnsupported):
+		response.Error(c, http.StatusNotImplemented, err.Error())
+	default:
+		logger.FromContext(c.Request.Context()).Error("failed to inspect cache key", zap.String("key", key), zap.Error(err))
+		response.InternalError(c, "failed to inspect key")
+	}
+	return nil, false
+}
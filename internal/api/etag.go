@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jtsang4/go-stater/internal/model"
+)
+
+// userETag 以版本号作为用户的 ETag，用户每次修改后版本号都会增加
+func userETag(user *model.User) string {
+	return `"` + strconv.FormatUint(uint64(user.Version), 10) + `"`
+}
+
+// notModified 设置 ETag，If-None-Match 与之匹配时返回 304 并返回 true。
+// If-None-Match 使用弱比较，W/ 前缀不影响匹配
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion 从 If-Match 中解析客户端持有的版本号，"*" 返回 0 表示不检查版本。
+// 缺少 If-Match 时返回 428，无法与当前版本匹配的值（弱 ETag、格式错误）返回 412，
+// 解析失败时已写入响应，ok 为 false
+func ifMatchVersion(c *gin.Context) (version uint, ok bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+	if strings.Contains(header, ",") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match must contain a single entity tag"})
+		return 0, false
+	}
+
+	// If-Match 使用强比较，弱 ETag 永远不匹配
+	v, err := strconv.ParseUint(strings.Trim(header, `"`), 10, 32)
+	if err != nil || v == 0 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "user has been modified, reload and retry"})
+		return 0, false
+	}
+	return uint(v), true
+}
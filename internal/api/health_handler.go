@@ -2,16 +2,19 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/jtsang4/go-stater/pkg/cache"
 	"github.com/jtsang4/go-stater/pkg/response"
 	"gorm.io/gorm"
 )
 
 type HealthHandler struct {
-	db *gorm.DB
+	db      *gorm.DB
+	breaker *cache.CircuitBreaker
 }
 
-func NewHealthHandler(db *gorm.DB) *HealthHandler {
-	return &HealthHandler{db: db}
+// NewHealthHandler 创建健康检查，breaker 为 nil 表示没有使用 Redis，redis 报告为 disabled
+func NewHealthHandler(db *gorm.DB, breaker *cache.CircuitBreaker) *HealthHandler {
+	return &HealthHandler{db: db, breaker: breaker}
 }
 
 // Health 检查服务健康状态
@@ -27,5 +30,16 @@ func (h *HealthHandler) Health(c *gin.Context) {
 		health["status"] = "degraded"
 	}
 
+	// Redis 熔断时缓存被绕过，服务仍可用
+	switch {
+	case h.breaker == nil:
+		health["redis"] = "disabled"
+	case h.breaker.State() == cache.BreakerOpen:
+		health["redis"] = "down"
+		health["status"] = "degraded"
+	default:
+		health["redis"] = "up"
+	}
+
 	response.Success(c, health)
 }
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jtsang4/go-stater/internal/service"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/jtsang4/go-stater/pkg/response"
+	"go.uber.org/zap"
+)
+
+type PreferenceHandler struct {
+	preferenceService *service.PreferenceService
+}
+
+func NewPreferenceHandler(preferenceService *service.PreferenceService) *PreferenceHandler {
+	return &PreferenceHandler{preferenceService: preferenceService}
+}
+
+// GetMyPreferences 返回当前登录用户的偏好设置
+func (h *PreferenceHandler) GetMyPreferences(c *gin.Context) {
+	prefs, err := h.preferenceService.GetPreferences(c.Request.Context(), c.GetUint("user_id"))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("failed to get preferences", zap.Error(err))
+		response.InternalError(c, "failed to get preferences")
+		return
+	}
+
+	response.Success(c, prefs)
+}
+
+// PatchMyPreferences 以 JSON Merge Patch (RFC 7386) 语义更新当前登录用户的偏好设置
+func (h *PreferenceHandler) PatchMyPreferences(c *gin.Context) {
+	var patch map[string]interface{}
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil || patch == nil {
+		response.BadRequest(c, "request body must be a JSON object")
+		return
+	}
+
+	prefs, err := h.preferenceService.PatchPreferences(c.Request.Context(), c.GetUint("user_id"), patch)
+	if errors.Is(err, service.ErrInvalidPreferences) {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("failed to update preferences", zap.Error(err))
+		response.InternalError(c, "failed to update preferences")
+		return
+	}
+
+	response.Success(c, prefs)
+}
@@ -1,13 +1,19 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jtsang4/go-stater/config"
 	"github.com/jtsang4/go-stater/internal/service"
+	"github.com/jtsang4/go-stater/pkg/logger"
 	"github.com/jtsang4/go-stater/pkg/response"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type UserHandler struct {
@@ -26,9 +32,19 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.CreateUser(&req)
+	user, err := h.userService.CreateUser(c.Request.Context(), &req)
 	if err != nil {
-		response.BadRequest(c, err.Error())
+		switch {
+		case errors.Is(err, service.ErrUsernameTaken), errors.Is(err, service.ErrUsernameReserved),
+			errors.Is(err, service.ErrEmailTaken), errors.Is(err, service.ErrUserExists):
+			response.Error(c, http.StatusConflict, err.Error())
+		case errors.Is(err, context.DeadlineExceeded):
+			// 等待用户名锁超时，通常是同一用户名的并发注册
+			response.Error(c, http.StatusServiceUnavailable, "service busy, retry later")
+		default:
+			logger.FromContext(c.Request.Context()).Error("failed to register user", zap.Error(err))
+			response.InternalError(c, "failed to register user")
+		}
 		return
 	}
 
@@ -43,12 +59,15 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.GetUserByID(uint(id))
+	user, err := h.userService.GetUserByID(c.Request.Context(), uint(id))
 	if err != nil {
 		response.NotFound(c, "user not found")
 		return
 	}
 
+	if notModified(c, userETag(user)) {
+		return
+	}
 	response.Success(c, user)
 }
 
@@ -59,7 +78,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.userService.Login(&req, h.cfg.JWT)
+	token, err := h.userService.Login(c.Request.Context(), &req, h.cfg.JWT)
 	if err != nil {
 		response.Unauthorized(c, err.Error())
 		return
@@ -76,18 +95,24 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var req service.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user, err := h.userService.UpdateUser(uint(id), &req)
+	user, err := h.userService.UpdateUser(c.Request.Context(), uint(id), &req, version)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(writeErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	c.Header("ETag", userETag(user))
 	c.JSON(http.StatusOK, user)
 }
 
@@ -99,10 +124,87 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.userService.DeleteUser(uint(id)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.DeleteUser(c.Request.Context(), uint(id), version); err != nil {
+		c.JSON(writeErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "user deleted successfully"})
 }
+
+// writeErrorStatus 返回用户更新、删除失败时的状态码
+func writeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, service.ErrVersionConflict):
+		return http.StatusConflict
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// GetUserByUsername 按用户名查找用户，旧用户名会重定向到用户当前的用户名
+func (h *UserHandler) GetUserByUsername(c *gin.Context) {
+	username := c.Param("username")
+
+	user, renamed, err := h.userService.GetUserByUsername(c.Request.Context(), username)
+	if err != nil {
+		response.NotFound(c, "user not found")
+		return
+	}
+
+	// 旧用户名过了保留期可能被其他用户注册，使用不会被缓存的临时重定向
+	if renamed {
+		c.Redirect(http.StatusTemporaryRedirect, "/api/v1/users/by-username/"+url.PathEscape(user.Username))
+		return
+	}
+
+	if notModified(c, userETag(user)) {
+		return
+	}
+	response.Success(c, user)
+}
+
+// ChangeUsername 修改当前登录用户的用户名，与更新资料一样要求 If-Match
+func (h *UserHandler) ChangeUsername(c *gin.Context) {
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	var req service.ChangeUsernameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	user, err := h.userService.ChangeUsername(c.Request.Context(), c.GetUint("user_id"), &req, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrVersionMismatch):
+			response.Error(c, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, service.ErrUsernameTaken), errors.Is(err, service.ErrUsernameReserved),
+			errors.Is(err, service.ErrVersionConflict):
+			response.Error(c, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrUsernameUnchanged), errors.Is(err, service.ErrUsernameChangeTooSoon):
+			response.BadRequest(c, err.Error())
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.NotFound(c, "user not found")
+		default:
+			logger.FromContext(c.Request.Context()).Error("failed to change username", zap.Error(err))
+			response.InternalError(c, "failed to change username")
+		}
+		return
+	}
+
+	c.Header("ETag", userETag(user))
+	response.Success(c, user)
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jtsang4/go-stater/internal/service"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/jtsang4/go-stater/pkg/response"
+	"github.com/jtsang4/go-stater/pkg/search"
+	"go.uber.org/zap"
+)
+
+type UserSearchHandler struct {
+	searchService *service.UserSearchService
+}
+
+func NewUserSearchHandler(searchService *service.UserSearchService) *UserSearchHandler {
+	return &UserSearchHandler{searchService: searchService}
+}
+
+// Search 按用户名或邮箱搜索用户：GET /users/search?q=alice&limit=20&offset=0。
+// q 中的每个词都必须匹配，允许部分匹配和少量拼写错误，结果按相关度排序并带有高亮
+func (h *UserSearchHandler) Search(c *gin.Context) {
+	var req service.SearchUsersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := h.searchService.Search(c.Request.Context(), &req)
+	if errors.Is(err, search.ErrWindowTooLarge) {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("failed to search users", zap.Error(err))
+		response.InternalError(c, "failed to search users")
+		return
+	}
+
+	response.Success(c, result)
+}
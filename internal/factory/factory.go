@@ -0,0 +1,175 @@
+// Package factory 为测试和种子数据构造模型，未指定的字段使用不重复的默认值：
+//
+//	user, err := f.User().WithEmail("alice@example.com").Admin().Create(ctx)
+package factory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// DefaultPassword 是未指定密码时用户的明文密码
+const DefaultPassword = "password123"
+
+// ErrNoStore 表示 Factory 没有存储，只能 Build 不能 Create
+var ErrNoStore = errors.New("factory has no store, use Build or factory.New")
+
+// Factory 创建模型并保存到存储中。包级函数 User 和 Preference 使用没有存储的默认 Factory
+type Factory struct {
+	users       store.RepositoryInterface[model.User]
+	preferences store.RepositoryInterface[model.UserPreference]
+	seq         atomic.Uint64
+}
+
+var defaultFactory = &Factory{}
+
+// New 创建保存到 db 的 Factory，ctx 中有事务时使用该事务
+func New(db *gorm.DB) *Factory {
+	return NewFrom(store.NewRepository[model.User](db), store.NewRepository[model.UserPreference](db))
+}
+
+// NewFrom 使用指定的存储创建 Factory，例如测试中使用 store.MemoryRepository
+func NewFrom(users store.RepositoryInterface[model.User], preferences store.RepositoryInterface[model.UserPreference]) *Factory {
+	return &Factory{users: users, preferences: preferences}
+}
+
+// User 使用默认 Factory 构造用户
+func User() *UserBuilder {
+	return defaultFactory.User()
+}
+
+// Preference 使用默认 Factory 构造偏好设置
+func Preference(userID uint) *PreferenceBuilder {
+	return defaultFactory.Preference(userID)
+}
+
+// UserBuilder 构造用户，默认为普通用户，用户名和邮箱按序号生成，密码为 DefaultPassword
+type UserBuilder struct {
+	f        *Factory
+	user     model.User
+	password string
+}
+
+func (f *Factory) User() *UserBuilder {
+	n := f.seq.Add(1)
+	return &UserBuilder{
+		f: f,
+		user: model.User{
+			Username: fmt.Sprintf("user%d", n),
+			Email:    fmt.Sprintf("user%d@example.com", n),
+			Role:     model.RoleUser,
+		},
+		password: DefaultPassword,
+	}
+}
+
+func (b *UserBuilder) WithID(id uint) *UserBuilder {
+	b.user.ID = id
+	return b
+}
+
+func (b *UserBuilder) WithUsername(username string) *UserBuilder {
+	b.user.Username = username
+	return b
+}
+
+func (b *UserBuilder) WithEmail(email string) *UserBuilder {
+	b.user.Email = email
+	return b
+}
+
+// WithPassword 设置明文密码，Build 时哈希
+func (b *UserBuilder) WithPassword(password string) *UserBuilder {
+	b.password = password
+	return b
+}
+
+func (b *UserBuilder) WithRole(role string) *UserBuilder {
+	b.user.Role = role
+	return b
+}
+
+func (b *UserBuilder) Admin() *UserBuilder {
+	return b.WithRole(model.RoleAdmin)
+}
+
+func (b *UserBuilder) WithVersion(version uint) *UserBuilder {
+	b.user.Version = version
+	return b
+}
+
+func (b *UserBuilder) WithUsernameChangedAt(at time.Time) *UserBuilder {
+	b.user.UsernameChangedAt = &at
+	return b
+}
+
+// Build 返回未保存的用户。为了让测试更快，密码使用最低的 bcrypt 成本哈希
+func (b *UserBuilder) Build() *model.User {
+	user := b.user
+	hashed, err := bcrypt.GenerateFromPassword([]byte(b.password), bcrypt.MinCost)
+	if err != nil {
+		// 只有密码超过 72 字节时失败
+		panic(fmt.Sprintf("factory: hash password: %v", err))
+	}
+	user.Password = string(hashed)
+	return &user
+}
+
+// Create 构造并保存用户
+func (b *UserBuilder) Create(ctx context.Context) (*model.User, error) {
+	if b.f.users == nil {
+		return nil, ErrNoStore
+	}
+	user := b.Build()
+	if err := b.f.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// PreferenceBuilder 构造用户偏好设置，默认没有任何显式设置的项
+type PreferenceBuilder struct {
+	f    *Factory
+	pref model.UserPreference
+}
+
+func (f *Factory) Preference(userID uint) *PreferenceBuilder {
+	return &PreferenceBuilder{
+		f:    f,
+		pref: model.UserPreference{UserID: userID, Data: map[string]interface{}{}},
+	}
+}
+
+// Set 设置一项偏好，name 为顶层字段名
+func (b *PreferenceBuilder) Set(name string, value interface{}) *PreferenceBuilder {
+	b.pref.Data[name] = value
+	return b
+}
+
+func (b *PreferenceBuilder) Build() *model.UserPreference {
+	pref := b.pref
+	pref.Data = make(map[string]interface{}, len(b.pref.Data))
+	for k, v := range b.pref.Data {
+		pref.Data[k] = v
+	}
+	return &pref
+}
+
+func (b *PreferenceBuilder) Create(ctx context.Context) (*model.UserPreference, error) {
+	if b.f.preferences == nil {
+		return nil, ErrNoStore
+	}
+	pref := b.Build()
+	if err := b.f.preferences.Create(ctx, pref); err != nil {
+		return nil, err
+	}
+	return pref, nil
+}
@@ -0,0 +1,53 @@
+package factory
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/pkg/encryption"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	// model.User 的邮箱加密存储
+	encryption.SetDefault(encryption.NewTestKeyring())
+	os.Exit(m.Run())
+}
+
+func TestUserBuilder(t *testing.T) {
+	a := User().Build()
+	b := User().WithEmail("bob@example.com").WithPassword("secret123").Admin().Build()
+
+	assert.NotEqual(t, a.Username, b.Username)
+	assert.Equal(t, model.RoleUser, a.Role)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(a.Password), []byte(DefaultPassword)))
+	assert.Equal(t, "bob@example.com", b.Email)
+	assert.Equal(t, model.RoleAdmin, b.Role)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(b.Password), []byte("secret123")))
+
+	_, err := User().Create(context.Background())
+	assert.ErrorIs(t, err, ErrNoStore)
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	users := store.NewMemoryRepository[model.User]()
+	f := NewFrom(users, store.NewMemoryRepository[model.UserPreference]())
+
+	user, err := f.User().WithUsername("alice").Create(ctx)
+	require.NoError(t, err)
+	assert.NotZero(t, user.ID)
+
+	pref, err := f.Preference(user.ID).Set("locale", "zh-CN").Create(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "zh-CN", pref.Data["locale"])
+
+	got, err := users.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", got.Username)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/internal/model"
 	"github.com/jtsang4/go-stater/pkg/auth"
 	"github.com/jtsang4/go-stater/pkg/logger"
 	"go.uber.org/zap"
@@ -15,7 +16,7 @@ func AuthMiddleware(cfg config.JWTConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			logger.Logger.Info("missing authorization header")
+			logger.FromContext(c.Request.Context()).Info("missing authorization header")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
 			c.Abort()
 			return
@@ -23,7 +24,7 @@ func AuthMiddleware(cfg config.JWTConfig) gin.HandlerFunc {
 
 		bearerToken := strings.Split(authHeader, " ")
 		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			logger.Logger.Info("invalid authorization header format")
+			logger.FromContext(c.Request.Context()).Info("invalid authorization header format")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 			c.Abort()
 			return
@@ -31,7 +32,7 @@ func AuthMiddleware(cfg config.JWTConfig) gin.HandlerFunc {
 
 		claims, err := auth.ParseToken(bearerToken[1], cfg)
 		if err != nil {
-			logger.Logger.Error("failed to parse token", zap.Error(err))
+			logger.FromContext(c.Request.Context()).Error("failed to parse token", zap.Error(err))
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
@@ -39,6 +40,20 @@ func AuthMiddleware(cfg config.JWTConfig) gin.HandlerFunc {
 
 		// Store user information from claims in context
 		c.Set("user_id", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Next()
+	}
+}
+
+// AdminMiddleware 只允许管理员访问，需要在 AuthMiddleware 之后使用
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != model.RoleAdmin {
+			logger.FromContext(c.Request.Context()).Info("admin access denied", zap.Uint("user_id", c.GetUint("user_id")))
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -35,7 +35,7 @@ func ErrorHandler() gin.HandlerFunc {
 		}
 
 		err := c.Errors.Last()
-		logger.Logger.Error("request error", zap.Error(err))
+		logger.FromContext(c.Request.Context()).Error("request error", zap.Error(err))
 
 		if appErr, ok := err.Err.(*AppError); ok {
 			c.JSON(appErr.Code, appErr)
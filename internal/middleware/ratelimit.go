@@ -44,7 +44,7 @@ func RateLimitMiddleware(limiter *IPRateLimiter) gin.HandlerFunc {
 		ip := c.ClientIP()
 		l := limiter.GetLimiter(ip)
 		if !l.Allow() {
-			logger.Logger.Warn("rate limit exceeded",
+			logger.FromContext(c.Request.Context()).Warn("rate limit exceeded",
 				zap.String("ip", ip),
 				zap.String("path", c.Request.URL.Path),
 			)
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jtsang4/go-stater/pkg/database"
+)
+
+// primaryCookie 保存客户端最近一次写入的时间（Unix 毫秒）
+const primaryCookie = "db_last_write"
+
+// ReadYourWritesMiddleware 为每个请求创建数据库会话：请求中发生写入后，window 内的读请求使用主库。
+// 写入时间通过 cookie 带到同一客户端的后续请求，避免刚写入的数据因从库延迟读不到
+func ReadYourWritesMiddleware(window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var lastWrite time.Time
+		if v, err := c.Cookie(primaryCookie); err == nil {
+			if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+				lastWrite = time.UnixMilli(ms)
+			}
+		}
+
+		ctx := database.WithSession(c.Request.Context(), lastWrite, func(at time.Time) {
+			// 写入发生在响应之前，此时仍可以设置 cookie
+			maxAge := int((window + time.Second - 1) / time.Second)
+			c.SetCookie(primaryCookie, strconv.FormatInt(at.UnixMilli(), 10), maxAge, "/", "", false, true)
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
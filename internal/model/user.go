@@ -1,17 +1,57 @@
 package model
 
 import (
+	"strings"
 	"time"
 
+	"github.com/jtsang4/go-stater/pkg/encryption"
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	Username  string         `gorm:"size:32;uniqueIndex;not null" json:"username"`
-	Password  string         `gorm:"size:128;not null" json:"-"`
-	Email     string         `gorm:"size:128;uniqueIndex;not null" json:"email"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primarykey" json:"id"`
+	Username          string         `gorm:"size:32;uniqueIndex;not null" json:"username"`
+	Password          string         `gorm:"size:128;not null" json:"-"`
+	Email             string         `gorm:"size:512;not null;serializer:encrypted" json:"email"` // 加密存储，按 EmailHash 查询
+	EmailHash         string         `gorm:"size:64;uniqueIndex" json:"-"`                        // 邮箱的盲索引，由 BeforeSave 计算
+	Role              string         `gorm:"size:16;not null;default:user" json:"role"`
+	UsernameChangedAt *time.Time     `json:"username_changed_at,omitempty"`
+	Version           uint           `gorm:"not null;default:1" json:"version"` // 乐观锁版本号，每次更新加 1
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// BeforeCreate 将新用户的版本号设为 1。不依赖数据库默认值，MySQL 插入后不会回填默认值
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.Version == 0 {
+		u.Version = 1
+	}
+	return nil
+}
+
+// BeforeSave 计算邮箱的盲索引。按 map 更新（UpdateColumns）时不会调用，邮箱只能按结构体更新
+func (u *User) BeforeSave(tx *gorm.DB) (err error) {
+	u.EmailHash, err = HashEmail(u.Email)
+	return err
+}
+
+// HashEmail 返回邮箱的盲索引，邮箱不区分大小写
+func HashEmail(email string) (string, error) {
+	return encryption.BlindIndex("email", strings.ToLower(strings.TrimSpace(email)))
+}
+
+// UsernameHistory 记录用户名变更，ReservedUntil 之前旧用户名只能被原用户重新使用
+type UsernameHistory struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	UserID        uint      `gorm:"index;not null" json:"user_id"`
+	OldUsername   string    `gorm:"size:32;index;not null" json:"old_username"`
+	NewUsername   string    `gorm:"size:32;not null" json:"new_username"`
+	ReservedUntil time.Time `json:"reserved_until"`
+	CreatedAt     time.Time `json:"created_at"`
 }
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// UserPreference 以 JSON 文档形式保存用户显式设置过的偏好，未设置的项使用配置中的默认值
+type UserPreference struct {
+	UserID    uint                   `gorm:"primarykey" json:"user_id"`
+	Data      map[string]interface{} `gorm:"type:json;serializer:json" json:"data"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"gorm.io/gorm"
+)
+
+// PreferenceRepository 以 user_id 为主键保存偏好，整体覆盖使用 Upsert
+type PreferenceRepository struct {
+	store.RepositoryInterface[model.UserPreference]
+}
+
+type PreferenceRepositoryInterface interface {
+	store.RepositoryInterface[model.UserPreference]
+}
+
+func NewPreferenceRepository(db *gorm.DB) *PreferenceRepository {
+	return &PreferenceRepository{RepositoryInterface: store.NewRepository[model.UserPreference](db)}
+}
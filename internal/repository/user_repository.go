@@ -1,50 +1,73 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/store"
 	"gorm.io/gorm"
 )
 
+// ErrVersionConflict 表示用户在读取之后已被其他请求修改或删除
+var ErrVersionConflict = store.ErrVersionConflict
+
+// UserRepository 的增删改查来自 store.RepositoryInterface，这里只实现用户特有的查询。
+// model.User 有 Version 字段，Update、UpdateColumns 和 Delete 会检查版本号
 type UserRepository struct {
-	db *gorm.DB
+	store.RepositoryInterface[model.User]
+	histories store.RepositoryInterface[model.UsernameHistory]
+	tx        database.TxManagerInterface
 }
 
 type UserRepositoryInterface interface {
-	Create(user *model.User) error
-	GetByID(id uint) (*model.User, error)
-	GetByUsername(username string) (*model.User, error)
-	Update(user *model.User) error
-	Delete(id uint) error
+	store.RepositoryInterface[model.User]
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	ChangeUsername(ctx context.Context, user *model.User, history *model.UsernameHistory) error
+	GetLatestUsernameHistory(ctx context.Context, oldUsername string) (*model.UsernameHistory, error)
 }
 
 func NewUserRepository(db *gorm.DB) *UserRepository {
-	return &UserRepository{db: db}
+	return NewUserRepositoryFrom(
+		store.NewRepository[model.User](db),
+		store.NewRepository[model.UsernameHistory](db),
+		database.NewTxManager(db),
+	)
 }
 
-func (r *UserRepository) Create(user *model.User) error {
-	return r.db.Create(user).Error
+// NewUserRepositoryFrom 使用给定的存储创建 UserRepository，测试中可以传入 store.MemoryRepository
+func NewUserRepositoryFrom(users store.RepositoryInterface[model.User], histories store.RepositoryInterface[model.UsernameHistory], tx database.TxManagerInterface) *UserRepository {
+	return &UserRepository{RepositoryInterface: users, histories: histories, tx: tx}
 }
 
-func (r *UserRepository) GetByID(id uint) (*model.User, error) {
-	var user model.User
-	if err := r.db.First(&user, id).Error; err != nil {
-		return nil, err
-	}
-	return &user, nil
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return r.First(ctx, store.Eq("username", username))
 }
 
-func (r *UserRepository) GetByUsername(username string) (*model.User, error) {
-	var user model.User
-	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
+// GetByEmail 按邮箱的盲索引查询，邮箱加密存储，不能直接比较
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	hash, err := model.HashEmail(email)
+	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	return r.First(ctx, store.Eq("email_hash", hash))
 }
 
-func (r *UserRepository) Update(user *model.User) error {
-	return r.db.Save(user).Error
+// ChangeUsername 在同一事务中更新用户名并写入变更历史，已在事务中时使用 savepoint
+func (r *UserRepository) ChangeUsername(ctx context.Context, user *model.User, history *model.UsernameHistory) error {
+	return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := r.UpdateColumns(ctx, user, map[string]interface{}{
+			"username":            user.Username,
+			"username_changed_at": user.UsernameChangedAt,
+		}); err != nil {
+			return err
+		}
+		return r.histories.Create(ctx, history)
+	})
 }
 
-func (r *UserRepository) Delete(id uint) error {
-	return r.db.Delete(&model.User{}, id).Error
+// GetLatestUsernameHistory 返回最近一次放弃 oldUsername 的记录
+func (r *UserRepository) GetLatestUsernameHistory(ctx context.Context, oldUsername string) (*model.UsernameHistory, error) {
+	return r.histories.First(ctx, store.Eq("old_username", oldUsername), store.OrderByDesc("id"))
 }
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jtsang4/go-stater/internal/factory"
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/migrations"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/database/dbtest"
+	"github.com/jtsang4/go-stater/pkg/encryption"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	// model.User 的邮箱加密存储
+	encryption.SetDefault(encryption.NewTestKeyring())
+	os.Exit(m.Run())
+}
+
+func TestUserRepositoryEncryptsEmail(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.NewMigrated(t, migrations.Run)
+	repo := NewUserRepository(db)
+
+	user := factory.User().WithUsername("alice").WithEmail("alice@example.com").Build()
+	require.NoError(t, repo.Create(ctx, user))
+	var raw string
+	require.NoError(t, db.Raw("SELECT email FROM users WHERE id = ?", user.ID).Scan(&raw).Error)
+	assert.True(t, encryption.IsEncrypted(raw))
+	assert.NotContains(t, raw, "alice")
+
+	found, err := repo.GetByEmail(ctx, "Alice@Example.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	assert.Equal(t, "alice@example.com", found.Email)
+
+	// 盲索引保证邮箱唯一
+	err = repo.Create(ctx, factory.User().WithUsername("alice2").WithEmail("ALICE@example.com").Build())
+	assert.Error(t, err)
+
+	// 加密之前写入的明文仍可读取，cmd/encrypt 运行之后才能按邮箱查询
+	require.NoError(t, db.Exec("INSERT INTO users (username, password, email, role) VALUES ('bob', 'x', 'bob@example.com', 'user')").Error)
+	bob, err := repo.GetByUsername(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "bob@example.com", bob.Email)
+	_, err = repo.GetByEmail(ctx, "bob@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestUserRepositoryChangeUsername(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository(dbtest.NewMigrated(t, migrations.Run))
+
+	// 不指定角色时使用数据库默认值
+	user := factory.User().WithUsername("alice").WithRole("").Build()
+	require.NoError(t, repo.Create(ctx, user))
+	assert.Equal(t, model.RoleUser, user.Role)
+
+	now := time.Now()
+	user.Username = "alice2"
+	user.UsernameChangedAt = &now
+	require.NoError(t, repo.ChangeUsername(ctx, user, &model.UsernameHistory{UserID: user.ID, OldUsername: "alice", NewUsername: "alice2"}))
+
+	got, err := repo.GetByUsername(ctx, "alice2")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	_, err = repo.GetByUsername(ctx, "alice")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	history, err := repo.GetLatestUsernameHistory(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, history.UserID)
+}
+
+func TestPreferenceRepositorySave(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.NewMigrated(t, migrations.Run)
+	user, err := factory.New(db).User().Create(ctx)
+	require.NoError(t, err)
+
+	repo := NewPreferenceRepository(db)
+	require.NoError(t, repo.Upsert(ctx, []*model.UserPreference{factory.Preference(user.ID).Set("theme", "dark").Build()}))
+	require.NoError(t, repo.Upsert(ctx, []*model.UserPreference{factory.Preference(user.ID).Set("theme", "light").Build()}))
+
+	pref, err := repo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "light", pref.Data["theme"])
+}
+
+func TestRepositoriesJoinTransactionFromContext(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.NewMigrated(t, migrations.Run)
+	txm := database.NewTxManager(db)
+	users := NewUserRepository(db)
+	prefs := NewPreferenceRepository(db)
+
+	err := txm.WithinTx(ctx, func(ctx context.Context) error {
+		user := factory.User().WithUsername("carol").Build()
+		if err := users.Create(ctx, user); err != nil {
+			return err
+		}
+		if err := prefs.Upsert(ctx, []*model.UserPreference{factory.Preference(user.ID).Set("theme", "dark").Build()}); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	assert.EqualError(t, err, "abort")
+
+	_, err = users.GetByUsername(ctx, "carol")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "both writes are rolled back")
+
+	// 嵌套事务失败只回滚到 savepoint
+	require.NoError(t, txm.WithinTx(ctx, func(ctx context.Context) error {
+		if err := users.Create(ctx, factory.User().WithUsername("dave").Build()); err != nil {
+			return err
+		}
+		_ = txm.WithinTx(ctx, func(ctx context.Context) error {
+			if err := users.Create(ctx, factory.User().WithUsername("erin").Build()); err != nil {
+				return err
+			}
+			return errors.New("abort inner")
+		})
+		locked, err := users.GetByID(ctx, 1, store.ForUpdate())
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, "dave", locked.Username)
+		return nil
+	}))
+	_, err = users.GetByUsername(ctx, "dave")
+	assert.NoError(t, err)
+	_, err = users.GetByUsername(ctx, "erin")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestUserRepositoryVersionedWrites(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository(dbtest.NewMigrated(t, migrations.Run))
+
+	user := factory.User().WithUsername("carol").Build()
+	require.NoError(t, repo.Create(ctx, user))
+	assert.Equal(t, uint(1), user.Version)
+
+	// 两个请求读取了同一版本，后提交的请求失败
+	first, err := repo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	second, err := repo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+
+	first.Email = "first@example.com"
+	require.NoError(t, repo.Update(ctx, first))
+	assert.Equal(t, uint(2), first.Version)
+
+	second.Email = "second@example.com"
+	assert.ErrorIs(t, repo.Update(ctx, second), ErrVersionConflict)
+	assert.Equal(t, uint(1), second.Version)
+
+	got, err := repo.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "first@example.com", got.Email)
+	assert.Equal(t, uint(2), got.Version)
+	assert.Equal(t, user.Password, got.Password)
+	assert.WithinDuration(t, user.CreatedAt, got.CreatedAt, time.Second)
+
+	now := time.Now()
+	got.Username = "carol2"
+	got.UsernameChangedAt = &now
+	require.NoError(t, repo.ChangeUsername(ctx, got, &model.UsernameHistory{UserID: got.ID, OldUsername: "carol", NewUsername: "carol2"}))
+	assert.Equal(t, uint(3), got.Version)
+
+	assert.ErrorIs(t, repo.Delete(ctx, first), ErrVersionConflict)
+	require.NoError(t, repo.Delete(ctx, got))
+	_, err = repo.GetByID(ctx, user.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
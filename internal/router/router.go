@@ -1,13 +1,15 @@
 package router
 
 import (
+	"expvar"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jtsang4/go-stater/config"
 	"github.com/jtsang4/go-stater/internal/api"
 	"github.com/jtsang4/go-stater/internal/middleware"
 )
 
-func SetupRouter(r *gin.Engine, userHandler *api.UserHandler, cfg *config.Config, healthHandler *api.HealthHandler) {
+func SetupRouter(r *gin.Engine, userHandler *api.UserHandler, cfg *config.Config, healthHandler *api.HealthHandler, preferenceHandler *api.PreferenceHandler, cacheHandler *api.CacheHandler, userSearchHandler *api.UserSearchHandler) {
 	// Health check route
 	r.GET("/health", healthHandler.Health)
 
@@ -22,8 +24,28 @@ func SetupRouter(r *gin.Engine, userHandler *api.UserHandler, cfg *config.Config
 	protected := r.Group("/api/v1")
 	protected.Use(middleware.AuthMiddleware(cfg.JWT))
 	{
+		protected.GET("/users/me/preferences", preferenceHandler.GetMyPreferences)
+		protected.PATCH("/users/me/preferences", preferenceHandler.PatchMyPreferences)
+		protected.PUT("/users/me/username", userHandler.ChangeUsername)
+		// 搜索可以按邮箱查找用户，仅管理员（客服）可用
+		protected.GET("/users/search", middleware.AdminMiddleware(), userSearchHandler.Search)
+		protected.GET("/users/by-username/:username", userHandler.GetUserByUsername)
 		protected.GET("/users/:id", userHandler.GetUser)
 		protected.PUT("/users/:id", userHandler.UpdateUser)
+		protected.PATCH("/users/:id", userHandler.UpdateUser)
 		protected.DELETE("/users/:id", userHandler.DeleteUser)
 	}
+
+	// Admin routes
+	admin := r.Group("/api/v1/admin")
+	admin.Use(middleware.AuthMiddleware(cfg.JWT), middleware.AdminMiddleware())
+	{
+		admin.GET("/cache/stats", cacheHandler.Stats)
+		admin.GET("/cache/keys/:key", cacheHandler.InspectKey)
+		admin.GET("/cache/keys/:key/value", cacheHandler.GetKey)
+		admin.DELETE("/cache/keys/:key", cacheHandler.EvictKey)
+	}
+
+	// expvar 指标（包含缓存统计），仅管理员可访问
+	r.GET("/debug/vars", middleware.AuthMiddleware(cfg.JWT), middleware.AdminMiddleware(), gin.WrapH(expvar.Handler()))
 }
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/stretchr/testify/mock"
@@ -11,6 +12,14 @@ type MockCache struct {
 	mock.Mock
 }
 
+// newMockCache 返回 MockCache，标签的版本号视为已经存在，TagSet 建立版本号的 SetCounterNX 不会写入
+func newMockCache() *MockCache {
+	m := new(MockCache)
+	isTag := mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "tag:") })
+	m.On("SetCounterNX", mock.Anything, isTag, mock.Anything).Return(false, nil).Maybe()
+	return m
+}
+
 func (m *MockCache) Get(ctx context.Context, key string, dest interface{}) error {
 	args := m.Called(ctx, key, dest)
 	return args.Error(0)
@@ -25,3 +34,18 @@ func (m *MockCache) Delete(ctx context.Context, key string) error {
 	args := m.Called(ctx, key)
 	return args.Error(0)
 }
+
+func (m *MockCache) Incr(ctx context.Context, key string) (int64, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCache) SetCounterNX(ctx context.Context, key string, value int64) (bool, error) {
+	args := m.Called(ctx, key, value)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	args := m.Called(ctx, prefix)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jtsang4/go-stater/config"
+)
+
+// ErrInvalidPreferences 表示提交的偏好设置不符合配置中的 schema
+var ErrInvalidPreferences = errors.New("invalid preferences")
+
+const (
+	PreferenceTypeString   = "string"
+	PreferenceTypeBool     = "bool"
+	PreferenceTypeInt      = "int"
+	PreferenceTypeNumber   = "number"
+	PreferenceTypeTimezone = "timezone"
+	PreferenceTypeObject   = "object"
+)
+
+// PreferenceSchema 根据配置校验偏好文档并填充默认值
+type PreferenceSchema struct {
+	fields []config.PreferenceField
+}
+
+// NewPreferenceSchema 校验 schema 本身（类型、默认值）是否合法
+func NewPreferenceSchema(fields []config.PreferenceField) (*PreferenceSchema, error) {
+	if err := checkSchemaFields(fields, ""); err != nil {
+		return nil, err
+	}
+	return &PreferenceSchema{fields: fields}, nil
+}
+
+func checkSchemaFields(fields []config.PreferenceField, prefix string) error {
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		path := prefix + f.Name
+		if f.Name == "" {
+			return fmt.Errorf("preference field under %q has no name", prefix)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate preference field %q", path)
+		}
+		seen[f.Name] = true
+
+		switch f.Type {
+		case PreferenceTypeObject:
+			if err := checkSchemaFields(f.Fields, path+"."); err != nil {
+				return err
+			}
+		case PreferenceTypeString, PreferenceTypeBool, PreferenceTypeInt, PreferenceTypeNumber, PreferenceTypeTimezone:
+			if f.Default == nil {
+				continue
+			}
+			if err := validatePreferenceValue(f, f.Default, path); err != nil {
+				return fmt.Errorf("default of preference %q: %w", path, err)
+			}
+		default:
+			return fmt.Errorf("preference field %q has unknown type %q", path, f.Type)
+		}
+	}
+	return nil
+}
+
+// Validate 校验用户保存的（稀疏）偏好文档，不允许出现 schema 之外的字段
+func (s *PreferenceSchema) Validate(doc map[string]interface{}) error {
+	return validatePreferenceObject(s.fields, doc, "")
+}
+
+// WithDefaults 返回填充了默认值的完整偏好文档，不修改入参
+func (s *PreferenceSchema) WithDefaults(doc map[string]interface{}) map[string]interface{} {
+	return withPreferenceDefaults(s.fields, doc)
+}
+
+func validatePreferenceObject(fields []config.PreferenceField, doc map[string]interface{}, prefix string) error {
+	byName := make(map[string]config.PreferenceField, len(fields))
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+
+	for key, value := range doc {
+		path := prefix + key
+		f, ok := byName[key]
+		if !ok {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidPreferences, path)
+		}
+		if err := validatePreferenceValue(f, value, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePreferenceValue(f config.PreferenceField, value interface{}, path string) error {
+	switch f.Type {
+	case PreferenceTypeString:
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%w: %q must be a string", ErrInvalidPreferences, path)
+		}
+		if f.MaxLength > 0 && utf8.RuneCountInString(str) > f.MaxLength {
+			return fmt.Errorf("%w: %q must be at most %d characters", ErrInvalidPreferences, path, f.MaxLength)
+		}
+		if len(f.Enum) > 0 && !containsString(f.Enum, str) {
+			return fmt.Errorf("%w: %q must be one of %v", ErrInvalidPreferences, path, f.Enum)
+		}
+	case PreferenceTypeBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%w: %q must be a boolean", ErrInvalidPreferences, path)
+		}
+	case PreferenceTypeInt, PreferenceTypeNumber:
+		n, ok := toFloat(value)
+		if !ok {
+			return fmt.Errorf("%w: %q must be a number", ErrInvalidPreferences, path)
+		}
+		if f.Type == PreferenceTypeInt && n != math.Trunc(n) {
+			return fmt.Errorf("%w: %q must be an integer", ErrInvalidPreferences, path)
+		}
+		if f.Min != nil && n < *f.Min {
+			return fmt.Errorf("%w: %q must be >= %v", ErrInvalidPreferences, path, *f.Min)
+		}
+		if f.Max != nil && n > *f.Max {
+			return fmt.Errorf("%w: %q must be <= %v", ErrInvalidPreferences, path, *f.Max)
+		}
+	case PreferenceTypeTimezone:
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%w: %q must be a string", ErrInvalidPreferences, path)
+		}
+		if _, err := time.LoadLocation(str); err != nil || str == "" {
+			return fmt.Errorf("%w: %q is not a valid IANA time zone", ErrInvalidPreferences, path)
+		}
+	case PreferenceTypeObject:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: %q must be an object", ErrInvalidPreferences, path)
+		}
+		return validatePreferenceObject(f.Fields, obj, path+".")
+	}
+	return nil
+}
+
+func withPreferenceDefaults(fields []config.PreferenceField, doc map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		value, ok := doc[f.Name]
+		if f.Type == PreferenceTypeObject {
+			obj, _ := value.(map[string]interface{})
+			result[f.Name] = withPreferenceDefaults(f.Fields, obj)
+			continue
+		}
+		if ok {
+			result[f.Name] = value
+		} else if f.Default != nil {
+			result[f.Name] = f.Default
+		}
+	}
+	return result
+}
+
+// mergePatch 按 RFC 7386 (JSON Merge Patch) 将 patch 应用到 target，返回新文档
+func mergePatch(target map[string]interface{}, patch map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(target)+len(patch))
+	for k, v := range target {
+		result[k] = v
+	}
+
+	for k, v := range patch {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		if patchObj, ok := v.(map[string]interface{}); ok {
+			targetObj, _ := result[k].(map[string]interface{})
+			result[k] = mergePatch(targetObj, patchObj)
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/internal/repository"
+	"github.com/jtsang4/go-stater/pkg/cache"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const defaultPreferenceCacheTTL = 30 * time.Minute
+
+type PreferenceService struct {
+	repo   repository.PreferenceRepositoryInterface
+	tx     database.TxManagerInterface
+	cache  cache.RedisCacheInterface
+	tags   *cache.TagSet
+	schema *PreferenceSchema
+	ttl    time.Duration
+}
+
+func NewPreferenceService(repo repository.PreferenceRepositoryInterface, tx database.TxManagerInterface, c cache.RedisCacheInterface, cfg config.PreferencesConfig) (*PreferenceService, error) {
+	schema, err := NewPreferenceSchema(cfg.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultPreferenceCacheTTL
+	}
+
+	return &PreferenceService{
+		repo:   repo,
+		tx:     tx,
+		cache:  c,
+		tags:   cache.NewTagSet(c),
+		schema: schema,
+		ttl:    ttl,
+	}, nil
+}
+
+func preferencesTag(userID uint) string {
+	return fmt.Sprintf("user:%d:preferences", userID)
+}
+
+// preferenceCacheKey 同时依赖用户标签，用户被删除时偏好缓存一并失效
+func preferenceCacheKey(userID uint) cache.Key {
+	return cache.NewKey("user", userID, "preferences").WithTags(userTag(userID), preferencesTag(userID))
+}
+
+// GetPreferences 返回填充了默认值的完整偏好设置
+func (s *PreferenceService) GetPreferences(ctx context.Context, userID uint) (map[string]interface{}, error) {
+	cacheKey, keyErr := s.tags.Resolve(ctx, preferenceCacheKey(userID))
+
+	// 只有确认未命中时才回填缓存，缓存异常时直接读取数据库
+	cacheable := keyErr == nil
+	var prefs map[string]interface{}
+	if cacheable {
+		err := s.cache.Get(ctx, cacheKey, &prefs)
+		if err == nil {
+			return prefs, nil
+		}
+		if !errors.Is(err, cache.ErrMiss) {
+			logger.FromContext(ctx).Warn("failed to get preferences cache", zap.Uint("user_id", userID), zap.Error(err))
+			cacheable = false
+		}
+	}
+
+	// 回填缓存的数据从主库读取，避免把从库的旧数据缓存 ttl 之久
+	loadCtx := ctx
+	if cacheable {
+		loadCtx = database.WithPrimary(ctx)
+	}
+	stored, err := s.loadStored(loadCtx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs = s.schema.WithDefaults(stored)
+	if cacheable {
+		if err := s.cache.Set(ctx, cacheKey, prefs, s.ttl); err != nil {
+			logger.FromContext(ctx).Warn("failed to set preferences cache", zap.Error(err))
+		}
+	}
+
+	return prefs, nil
+}
+
+// PatchPreferences 以 JSON Merge Patch 语义更新偏好设置：
+// null 表示恢复默认值，对象按字段递归合并，其余值直接替换
+func (s *PreferenceService) PatchPreferences(ctx context.Context, userID uint, patch map[string]interface{}) (map[string]interface{}, error) {
+	// 读取、合并和保存在同一事务中，并发的修改不会丢失。
+	// 记录不存在时 FOR UPDATE 锁不住任何行，并发的首次修改中后插入的一方因主键冲突失败，
+	// 重新执行一次即可读到并锁定先插入的记录
+	var merged map[string]interface{}
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		merged, err = s.patch(ctx, userID, patch)
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := s.schema.WithDefaults(merged)
+	s.refreshCache(ctx, userID, prefs)
+
+	return prefs, nil
+}
+
+// patch 在事务中锁定、合并并保存偏好，记录不存在时插入，并发插入时返回 gorm.ErrDuplicatedKey
+func (s *PreferenceService) patch(ctx context.Context, userID uint, patch map[string]interface{}) (map[string]interface{}, error) {
+	var merged map[string]interface{}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		pref, err := s.repo.GetByID(ctx, userID, store.ForUpdate())
+		exists := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		stored := map[string]interface{}{}
+		if exists && pref.Data != nil {
+			stored = pref.Data
+		}
+
+		merged = mergePatch(stored, patch)
+		if err := s.schema.Validate(merged); err != nil {
+			return err
+		}
+
+		if !exists {
+			return s.repo.Create(ctx, &model.UserPreference{UserID: userID, Data: merged})
+		}
+		return s.repo.Upsert(ctx, []*model.UserPreference{{UserID: userID, Data: merged}})
+	})
+	return merged, err
+}
+
+// refreshCache 使旧的偏好缓存失效并写入最新值，数据库已提交，不受请求取消的影响
+func (s *PreferenceService) refreshCache(ctx context.Context, userID uint, prefs map[string]interface{}) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.tags.InvalidateTag(ctx, preferencesTag(userID)); err != nil {
+		logger.FromContext(ctx).Error("failed to invalidate preferences cache", zap.Uint("user_id", userID), zap.Error(err))
+		return
+	}
+
+	cacheKey, err := s.tags.Resolve(ctx, preferenceCacheKey(userID))
+	if err != nil {
+		return
+	}
+	if err := s.cache.Set(ctx, cacheKey, prefs, s.ttl); err != nil {
+		logger.FromContext(ctx).Warn("failed to set preferences cache", zap.Error(err))
+	}
+}
+
+// loadStored 读取用户显式保存过的偏好，没有记录时返回空文档
+func (s *PreferenceService) loadStored(ctx context.Context, userID uint) (map[string]interface{}, error) {
+	pref, err := s.repo.GetByID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return map[string]interface{}{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pref.Data == nil {
+		return map[string]interface{}{}, nil
+	}
+	return pref.Data, nil
+}
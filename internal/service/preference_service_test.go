@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/internal/factory"
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/pkg/cache"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var testPreferencesConfig = config.PreferencesConfig{
+	Fields: []config.PreferenceField{
+		{Name: "display_name", Type: "string", MaxLength: 8},
+		{Name: "locale", Type: "string", Enum: []string{"en-US", "zh-CN"}, Default: "en-US"},
+		{Name: "timezone", Type: "timezone", Default: "UTC"},
+		{Name: "notifications", Type: "object", Fields: []config.PreferenceField{
+			{Name: "email", Type: "bool", Default: true},
+			{Name: "push", Type: "bool", Default: false},
+		}},
+	},
+}
+
+func TestNewPreferenceSchemaRejectsInvalidDefault(t *testing.T) {
+	_, err := NewPreferenceSchema([]config.PreferenceField{
+		{Name: "locale", Type: "string", Enum: []string{"en-US"}, Default: "fr-FR"},
+	})
+	assert.Error(t, err)
+
+	_, err = NewPreferenceSchema([]config.PreferenceField{{Name: "x", Type: "color"}})
+	assert.Error(t, err)
+}
+
+func TestGetPreferences(t *testing.T) {
+	repo := store.NewMemoryRepository[model.UserPreference]()
+	mockCache := newMockCache()
+	service, err := NewPreferenceService(repo, &MockTxManager{}, mockCache, testPreferencesConfig)
+	assert.NoError(t, err)
+
+	require.NoError(t, repo.Create(context.Background(), factory.Preference(1).Set("locale", "zh-CN").Build()))
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(cache.ErrMiss)
+	mockCache.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+	mockCache.On("Set", mock.Anything, "user:1:preferences@1.1", mock.Anything, mock.Anything).Return(nil)
+
+	prefs, err := service.GetPreferences(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"locale":        "zh-CN",
+		"timezone":      "UTC",
+		"notifications": map[string]interface{}{"email": true, "push": false},
+	}, prefs)
+}
+
+func TestPatchPreferences(t *testing.T) {
+	tests := []struct {
+		name    string
+		stored  map[string]interface{}
+		patch   map[string]interface{}
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "merge nested object",
+			stored: map[string]interface{}{"locale": "zh-CN"},
+			patch:  map[string]interface{}{"notifications": map[string]interface{}{"push": true}},
+			want: map[string]interface{}{
+				"locale":        "zh-CN",
+				"timezone":      "UTC",
+				"notifications": map[string]interface{}{"email": true, "push": true},
+			},
+		},
+		{
+			name:   "null resets to default",
+			stored: map[string]interface{}{"locale": "zh-CN", "display_name": "bob"},
+			patch:  map[string]interface{}{"locale": nil},
+			want: map[string]interface{}{
+				"display_name":  "bob",
+				"locale":        "en-US",
+				"timezone":      "UTC",
+				"notifications": map[string]interface{}{"email": true, "push": false},
+			},
+		},
+		{
+			name:    "unknown field",
+			patch:   map[string]interface{}{"theme": "dark"},
+			wantErr: true,
+		},
+		{
+			name:    "value not in enum",
+			patch:   map[string]interface{}{"locale": "fr-FR"},
+			wantErr: true,
+		},
+		{
+			name:    "string too long",
+			patch:   map[string]interface{}{"display_name": "a very long name"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid timezone",
+			patch:   map[string]interface{}{"timezone": "Mars/Olympus"},
+			wantErr: true,
+		},
+		{
+			name:    "wrong nested type",
+			patch:   map[string]interface{}{"notifications": map[string]interface{}{"email": "yes"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := store.NewMemoryRepository[model.UserPreference]()
+			mockCache := newMockCache()
+			service, err := NewPreferenceService(repo, &MockTxManager{}, mockCache, testPreferencesConfig)
+			assert.NoError(t, err)
+
+			if tt.stored != nil {
+				require.NoError(t, repo.Create(ctx, &model.UserPreference{UserID: 1, Data: tt.stored}))
+			}
+			mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(cache.ErrMiss)
+			mockCache.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+			mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			got, err := service.PatchPreferences(ctx, 1, tt.patch)
+			stored, getErr := repo.GetByID(ctx, uint(1))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidPreferences)
+				if tt.stored == nil {
+					assert.ErrorIs(t, getErr, gorm.ErrRecordNotFound)
+				} else {
+					require.NoError(t, getErr)
+					assert.Equal(t, tt.stored, stored.Data)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+				require.NoError(t, getErr)
+				assert.Equal(t, tt.want, service.schema.WithDefaults(stored.Data))
+			}
+		})
+	}
+}
+
+// racingPreferenceRepository 第一次读取时模拟另一个请求抢先插入了偏好记录
+type racingPreferenceRepository struct {
+	*store.MemoryRepository[model.UserPreference]
+	raced bool
+}
+
+func (r *racingPreferenceRepository) GetByID(ctx context.Context, id interface{}, specs ...store.Spec) (*model.UserPreference, error) {
+	if !r.raced {
+		r.raced = true
+		if err := r.MemoryRepository.Create(ctx, factory.Preference(1).Set("locale", "zh-CN").Build()); err != nil {
+			return nil, err
+		}
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.MemoryRepository.GetByID(ctx, id, specs...)
+}
+
+// 并发的首次修改不会覆盖先插入的记录
+func TestPatchPreferencesConcurrentFirstWrite(t *testing.T) {
+	ctx := context.Background()
+	repo := &racingPreferenceRepository{MemoryRepository: store.NewMemoryRepository[model.UserPreference]()}
+	mockCache := newMockCache()
+	service, err := NewPreferenceService(repo, &MockTxManager{}, mockCache, testPreferencesConfig)
+	require.NoError(t, err)
+	mockCache.On("Incr", mock.Anything, mock.Anything).Return(int64(1), nil)
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(cache.ErrMiss)
+	mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	got, err := service.PatchPreferences(ctx, 1, map[string]interface{}{"display_name": "bob"})
+	require.NoError(t, err)
+	assert.Equal(t, "zh-CN", got["locale"])
+	assert.Equal(t, "bob", got["display_name"])
+
+	stored, err := repo.MemoryRepository.GetByID(ctx, uint(1))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"locale": "zh-CN", "display_name": "bob"}, stored.Data)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/pkg/cache"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// 用户相关的缓存条目都带有 user:<id> 标签（见 cache.TagSet），
+// 用户被修改或删除时使该标签失效，即可丢弃所有派生条目（用户资料、偏好设置等）
+
+// 标签失效失败后在后台重试的次数和首次间隔，间隔每次翻倍
+const (
+	invalidateRetries = 5
+	invalidateBackoff = 200 * time.Millisecond
+)
+
+func userTag(id uint) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+func userProfileKey(id uint) cache.Key {
+	return cache.NewKey("user", id, "profile").WithTags(userTag(id))
+}
+
+// userSnapshot 是缓存中保存的用户，只包含 API 可见的字段。
+// 密码哈希等 json:"-" 的字段在任何编码格式下（包括 gob）都不会写入缓存
+type userSnapshot struct {
+	ID                uint       `json:"id"`
+	Username          string     `json:"username"`
+	Email             string     `json:"email"`
+	Role              string     `json:"role"`
+	UsernameChangedAt *time.Time `json:"username_changed_at,omitempty"`
+	Version           uint       `json:"version"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+func newUserSnapshot(u *model.User) *userSnapshot {
+	return &userSnapshot{
+		ID:                u.ID,
+		Username:          u.Username,
+		Email:             u.Email,
+		Role:              u.Role,
+		UsernameChangedAt: u.UsernameChangedAt,
+		Version:           u.Version,
+		CreatedAt:         u.CreatedAt,
+		UpdatedAt:         u.UpdatedAt,
+	}
+}
+
+func (u *userSnapshot) user() *model.User {
+	return &model.User{
+		ID:                u.ID,
+		Username:          u.Username,
+		Email:             u.Email,
+		Role:              u.Role,
+		UsernameChangedAt: u.UsernameChangedAt,
+		Version:           u.Version,
+		CreatedAt:         u.CreatedAt,
+		UpdatedAt:         u.UpdatedAt,
+	}
+}
+
+// invalidateUser 在数据库写操作成功后调用：使 user:<id> 标签失效，
+// user 不为 nil 时将其写入新的 key（write-through）。
+// 数据库已经提交，即使请求已被取消也必须清除缓存，否则会一直读到旧数据
+func (s *UserService) invalidateUser(ctx context.Context, id uint, user *model.User) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.tags.InvalidateTag(ctx, userTag(id)); err != nil {
+		logger.FromContext(ctx).Error("failed to invalidate user cache, deleting current entries", zap.Uint("user_id", id), zap.Error(err))
+		s.dropUser(ctx, id)
+		return
+	}
+
+	if user == nil {
+		return
+	}
+
+	key, err := s.tags.Resolve(ctx, userProfileKey(id))
+	if err != nil {
+		logger.FromContext(ctx).Warn("failed to resolve user cache key", zap.Uint("user_id", id), zap.Error(err))
+		return
+	}
+	if err := s.loader.Store(ctx, key, s.cfg.CacheTTL, newUserSnapshot(user)); err != nil {
+		logger.FromContext(ctx).Warn("failed to set cache", zap.Uint("user_id", id), zap.Error(err))
+	}
+}
+
+// dropUser 在 user:<id> 标签无法失效时删除当前版本的用户缓存，并在后台重试使标签失效。
+// 删除之前开始的读请求仍可能回填旧数据，标签失效后这些数据也不会再被读取
+func (s *UserService) dropUser(ctx context.Context, id uint) {
+	for _, key := range []cache.Key{userProfileKey(id), preferenceCacheKey(id)} {
+		resolved, err := s.tags.Resolve(ctx, key)
+		if err == nil {
+			err = s.cache.Delete(ctx, resolved)
+		}
+		if err != nil {
+			logger.FromContext(ctx).Warn("failed to delete user cache", zap.Uint("user_id", id), zap.String("key", key.String()), zap.Error(err))
+		}
+	}
+
+	go func() {
+		delay := s.invalidateBackoff
+		for i := 0; i < invalidateRetries; i++ {
+			time.Sleep(delay)
+			delay *= 2
+			if err := s.tags.InvalidateTag(ctx, userTag(id)); err == nil {
+				return
+			}
+		}
+		logger.FromContext(ctx).Error("gave up invalidating user cache", zap.Uint("user_id", id), zap.Int("retries", invalidateRetries))
+	}()
+}
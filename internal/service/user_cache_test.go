@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/internal/factory"
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// cachedUser 返回将 user 作为缓存命中结果写入 Get 参数的 Run 函数
+func cachedUser(user *model.User) func(args mock.Arguments) {
+	return func(args mock.Arguments) {
+		entry, _ := cache.NewEntry(user, time.Now().Add(time.Hour))
+		*args.Get(2).(*cache.Entry) = *entry
+	}
+}
+
+// userEntry 匹配 Set 写入的、内容满足 fn 的缓存条目
+func userEntry(fn func(u *model.User) bool) interface{} {
+	return mock.MatchedBy(func(entry *cache.Entry) bool {
+		var user model.User
+		return entry.Decode(&user) == nil && fn(&user)
+	})
+}
+
+func expectCachedVersion(mockCache *MockCache, key string, version int64) {
+	mockCache.On("Get", mock.Anything, key, mock.AnythingOfType("*int64")).
+		Run(func(args mock.Arguments) {
+			*args.Get(2).(*int64) = version
+		}).
+		Return(nil).Once()
+}
+
+func TestUpdateUserNoStaleRead(t *testing.T) {
+	s := newTestUserStore(t, factory.User().WithID(1).WithUsername("testuser").WithEmail("old@example.com").Build())
+	mockCache := newMockCache()
+	service := NewUserService(s, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), config.UserConfig{})
+
+	// 缓存中已有旧数据
+	expectCachedVersion(mockCache, "tag:user:1", 1)
+	mockCache.On("Get", mock.Anything, "user:1:profile@1", mock.AnythingOfType("*cache.Entry")).
+		Run(cachedUser(factory.User().WithID(1).WithUsername("testuser").WithEmail("old@example.com").Build())).
+		Return(nil).Once()
+
+	got, err := service.GetUserByID(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "old@example.com", got.Email)
+
+	// 更新后版本号自增并写入新版本
+	var written *cache.Entry
+	mockCache.On("Incr", mock.Anything, "tag:user:1").Return(int64(2), nil).Once()
+	expectCachedVersion(mockCache, "tag:user:1", 2)
+	mockCache.On("Set", mock.Anything, "user:1:profile@2", mock.AnythingOfType("*cache.Entry"), mock.Anything).
+		Run(func(args mock.Arguments) {
+			written = args.Get(2).(*cache.Entry)
+		}).
+		Return(nil).Once()
+
+	_, err = service.UpdateUser(context.Background(), 1, &UpdateUserRequest{Email: "new@example.com"}, 0)
+	assert.NoError(t, err)
+
+	// 再次读取命中新版本
+	expectCachedVersion(mockCache, "tag:user:1", 2)
+	mockCache.On("Get", mock.Anything, "user:1:profile@2", mock.AnythingOfType("*cache.Entry")).
+		Run(func(args mock.Arguments) {
+			*args.Get(2).(*cache.Entry) = *written
+		}).
+		Return(nil).Once()
+
+	got, err = service.GetUserByID(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "new@example.com", got.Email)
+
+	mockCache.AssertExpectations(t)
+	mockCache.AssertNumberOfCalls(t, "Get", 5)
+}
+
+func TestDeleteUserRacingWithCachePopulate(t *testing.T) {
+	s := newTestUserStore(t, factory.User().WithID(1).WithUsername("testuser").WithEmail("test@example.com").Build())
+	repo := &hookedUserRepository{UserRepositoryInterface: s}
+	mockCache := newMockCache()
+	service := NewUserService(repo, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), config.UserConfig{})
+
+	// 读请求缓存未命中，读数据库期间用户被删除，随后读请求回填了旧数据
+	expectCachedVersion(mockCache, "tag:user:1", 1)
+	mockCache.On("Get", mock.Anything, "user:1:profile@1", mock.AnythingOfType("*cache.Entry")).
+		Return(cache.ErrMiss).Once()
+	repo.onGet = func() {
+		assert.NoError(t, service.DeleteUser(context.Background(), 1, 1))
+	}
+	mockCache.On("Incr", mock.Anything, "tag:user:1").Return(int64(2), nil).Once()
+	mockCache.On("Set", mock.Anything, "user:1:profile@1", mock.AnythingOfType("*cache.Entry"), mock.Anything).Return(nil).Once()
+
+	_, err := service.GetUserByID(context.Background(), 1)
+	assert.NoError(t, err)
+
+	// 之后的读请求使用新版本号，不会读到回填的旧数据
+	expectCachedVersion(mockCache, "tag:user:1", 2)
+	mockCache.On("Get", mock.Anything, "user:1:profile@2", mock.AnythingOfType("*cache.Entry")).
+		Return(cache.ErrMiss).Once()
+
+	got, err := service.GetUserByID(context.Background(), 1)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Nil(t, got)
+
+	mockCache.AssertExpectations(t)
+}
+
+func TestGetUserByIDBypassesUnavailableCache(t *testing.T) {
+	s := newTestUserStore(t, factory.User().WithID(1).WithUsername("testuser").WithEmail("test@example.com").Build())
+	mockCache := newMockCache()
+	service := NewUserService(s, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), config.UserConfig{})
+
+	// 无法确定版本号时直接读数据库，不读写缓存数据
+	mockCache.On("Get", mock.Anything, "tag:user:1", mock.AnythingOfType("*int64")).Return(errors.New("connection refused"))
+
+	got, err := service.GetUserByID(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "testuser", got.Username)
+	// 缓存异常不应被当作版本号丢失而使标签失效
+	mockCache.AssertNotCalled(t, "Incr", mock.Anything, mock.Anything)
+	mockCache.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetUserByIDDoesNotTreatCacheErrorAsMiss(t *testing.T) {
+	s := newTestUserStore(t, factory.User().WithID(1).WithUsername("testuser").WithEmail("test@example.com").Build())
+	mockCache := newMockCache()
+	service := NewUserService(s, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), config.UserConfig{})
+
+	// 读取数据失败（而不是未命中）时回源，但不回填缓存
+	expectCachedVersion(mockCache, "tag:user:1", 2)
+	mockCache.On("Get", mock.Anything, "user:1:profile@2", mock.Anything).Return(errors.New("i/o timeout"))
+
+	got, err := service.GetUserByID(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "testuser", got.Username)
+	mockCache.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// 缓存中的用户不包含密码哈希，使用 gob 编码时也可以缓存
+func TestUserCacheOmitsPassword(t *testing.T) {
+	ctx := context.Background()
+	s := newTestUserStore(t, factory.User().WithID(1).WithUsername("testuser").WithPassword("secret123").Build())
+	memoryCache, err := cache.NewMemoryCache(0, cache.EvictionLRU)
+	require.NoError(t, err)
+	gob, err := cache.NewSerializer(cache.CodecGob, cache.CompressionNone, 0)
+	require.NoError(t, err)
+	memoryCache.SetSerializer(gob)
+	service := NewUserService(s, &MockTxManager{}, s.outbox, memoryCache, cache.NewMemoryLocker(), config.UserConfig{})
+
+	user, err := service.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "testuser", user.Username)
+	assert.Empty(t, user.Password)
+
+	key, err := cache.NewTagSet(memoryCache).Resolve(ctx, userProfileKey(1))
+	require.NoError(t, err)
+	var entry cache.Entry
+	require.NoError(t, memoryCache.Get(ctx, key, &entry), "the user is cached")
+	var cached model.User
+	require.NoError(t, entry.Decode(&cached))
+	assert.Equal(t, uint(1), cached.ID)
+	assert.Empty(t, cached.Password)
+}
+
+// 回填缓存时从主库读取，避免缓存从库的旧数据
+func TestUserCacheFillReadsPrimary(t *testing.T) {
+	ctx := context.Background()
+	s := newTestUserStore(t, factory.User().WithID(1).WithUsername("testuser").Build())
+	repo := &hookedUserRepository{UserRepositoryInterface: s}
+	memoryCache, err := cache.NewMemoryCache(0, cache.EvictionLRU)
+	require.NoError(t, err)
+	service := NewUserService(repo, &MockTxManager{}, s.outbox, memoryCache, cache.NewMemoryLocker(), config.UserConfig{})
+
+	_, err = service.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+	_, err = service.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true}, repo.primary, "the second read is served from cache")
+}
+
+// 标签无法失效时删除当前版本的条目，并在后台重试
+func TestInvalidateUserFallsBackToDelete(t *testing.T) {
+	s := newTestUserStore(t, factory.User().WithID(1).WithUsername("testuser").WithEmail("old@example.com").Build())
+	mockCache := newMockCache()
+	service := NewUserService(s, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), config.UserConfig{})
+	service.invalidateBackoff = time.Millisecond
+
+	mockCache.On("Incr", mock.Anything, "tag:user:1").Return(int64(0), errors.New("i/o timeout")).Once()
+	mockCache.On("Get", mock.Anything, "tag:user:1", mock.AnythingOfType("*int64")).
+		Run(func(args mock.Arguments) {
+			*args.Get(2).(*int64) = 1
+		}).
+		Return(nil)
+	mockCache.On("Get", mock.Anything, "tag:user:1:preferences", mock.AnythingOfType("*int64")).
+		Run(func(args mock.Arguments) {
+			*args.Get(2).(*int64) = 1
+		}).
+		Return(nil)
+	mockCache.On("Delete", mock.Anything, "user:1:profile@1").Return(nil).Once()
+	mockCache.On("Delete", mock.Anything, "user:1:preferences@1.1").Return(nil).Once()
+	retried := make(chan struct{})
+	mockCache.On("Incr", mock.Anything, "tag:user:1").Return(int64(2), nil).Once().
+		Run(func(mock.Arguments) { close(retried) })
+
+	_, err := service.UpdateUser(context.Background(), 1, &UpdateUserRequest{Email: "new@example.com"}, 0)
+	require.NoError(t, err)
+
+	select {
+	case <-retried:
+	case <-time.After(time.Second):
+		t.Fatal("invalidation was not retried")
+	}
+	mockCache.AssertExpectations(t)
+	mockCache.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/internal/repository"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/jtsang4/go-stater/pkg/search"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// UserSearchFields 是进程内索引的搜索字段，用户名匹配的权重更高。
+// 进程内索引保存解密后的邮箱，邮箱支持部分匹配和容错搜索
+var UserSearchFields = []search.Field{
+	{Name: "username", Weight: 2},
+	{Name: "email", Weight: 1},
+}
+
+// UserDBSearchFields 是数据库全文索引的搜索字段。邮箱加密存储，数据库无法建立全文索引，
+// 只能通过盲索引按完整邮箱精确查找
+var UserDBSearchFields = []search.Field{
+	{Name: "username", Weight: 2},
+}
+
+// NewUserSearchIndex 按配置创建用户搜索索引，数据库索引不包含邮箱
+func NewUserSearchIndex(cfg config.SearchConfig, db *gorm.DB) (search.IndexInterface, error) {
+	if cfg.Backend == search.BackendMemory {
+		return search.NewMemoryIndex(UserSearchFields...), nil
+	}
+	return search.NewIndex[model.User](cfg, db, UserDBSearchFields...)
+}
+
+// UserSearchService 按用户名和邮箱搜索用户。它实现了 UserHook，注册到 UserService 后
+// 用户的创建、修改和删除会同步到索引（DBIndex 由数据库维护，同步不做任何事）。
+// 使用数据库索引时邮箱不参与全文搜索，查询包含 @ 时按完整邮箱查找
+type UserSearchService struct {
+	repo  repository.UserRepositoryInterface
+	index search.IndexInterface
+}
+
+func NewUserSearchService(repo repository.UserRepositoryInterface, index search.IndexInterface) *UserSearchService {
+	return &UserSearchService{repo: repo, index: index}
+}
+
+// indexesEmail 判断索引中是否有邮箱，只有进程内索引保存解密后的邮箱
+func (s *UserSearchService) indexesEmail() bool {
+	_, ok := s.index.(*search.MemoryIndex)
+	return ok
+}
+
+// UserSearchHit 是一条用户搜索结果，Highlights 中匹配的部分用 <em> 标记
+type UserSearchHit struct {
+	User       *model.User       `json:"user"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type UserSearchResult struct {
+	Hits  []UserSearchHit `json:"hits"`
+	Total int             `json:"total"`
+}
+
+type SearchUsersRequest struct {
+	Query  string `form:"q" binding:"required,max=100"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+}
+
+// Search 返回按相关度排序的用户
+func (s *UserSearchService) Search(ctx context.Context, req *SearchUsersRequest) (*UserSearchResult, error) {
+	if strings.Contains(req.Query, "@") && !s.indexesEmail() {
+		return s.searchEmail(ctx, req)
+	}
+
+	result, err := s.index.Search(ctx, search.Query{Text: req.Query, Limit: req.Limit, Offset: req.Offset})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+	users := make(map[uint]*model.User, len(ids))
+	if len(ids) > 0 {
+		found, err := s.repo.Find(ctx, store.In("id", ids...))
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range found {
+			users[user.ID] = user
+		}
+	}
+
+	hits := make([]UserSearchHit, 0, len(result.Hits))
+	total := result.Total
+	for _, hit := range result.Hits {
+		user, ok := users[hit.ID]
+		if !ok {
+			// 进程内索引可能还没有同步其他实例删除的用户
+			total--
+			continue
+		}
+		hits = append(hits, UserSearchHit{User: user, Score: hit.Score, Highlights: hit.Highlights})
+	}
+	return &UserSearchResult{Hits: hits, Total: total}, nil
+}
+
+// searchEmail 通过盲索引按完整邮箱查找，邮箱不区分大小写，最多返回一个用户
+func (s *UserSearchService) searchEmail(ctx context.Context, req *SearchUsersRequest) (*UserSearchResult, error) {
+	result := &UserSearchResult{Hits: []UserSearchHit{}}
+	user, err := s.repo.GetByEmail(ctx, req.Query)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Total = 1
+	if req.Offset == 0 {
+		result.Hits = append(result.Hits, UserSearchHit{
+			User:       user,
+			Score:      1,
+			Highlights: map[string]string{"email": "<em>" + html.EscapeString(user.Email) + "</em>"},
+		})
+	}
+	return result, nil
+}
+
+// UserSaved 实现 UserHook
+func (s *UserSearchService) UserSaved(ctx context.Context, user *model.User) {
+	if err := s.index.Index(ctx, userDocument(user)); err != nil {
+		logger.FromContext(ctx).Warn("failed to index user", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+}
+
+// UserDeleted 实现 UserHook
+func (s *UserSearchService) UserDeleted(ctx context.Context, id uint) {
+	if err := s.index.Delete(ctx, id); err != nil {
+		logger.FromContext(ctx).Warn("failed to remove user from index", zap.Uint("user_id", id), zap.Error(err))
+	}
+}
+
+// Rebuild 从数据库加载全部用户重建进程内索引，DBIndex 不需要重建
+func (s *UserSearchService) Rebuild(ctx context.Context) error {
+	index, ok := s.index.(*search.MemoryIndex)
+	if !ok {
+		return nil
+	}
+	users, err := s.repo.Find(ctx, store.OrderBy("id"))
+	if err != nil {
+		return err
+	}
+	docs := make([]search.Document, len(users))
+	for i, user := range users {
+		docs[i] = userDocument(user)
+	}
+	return index.Reset(ctx, docs...)
+}
+
+// RunRebuild 每隔 interval 重建进程内索引，直到 ctx 取消。多实例部署时用于同步其他实例的修改
+func (s *UserSearchService) RunRebuild(ctx context.Context, interval time.Duration) {
+	if _, ok := s.index.(*search.MemoryIndex); !ok || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Rebuild(ctx); err != nil && ctx.Err() == nil {
+				logger.Logger.Warn("Failed to rebuild user search index", zap.Error(err))
+			}
+		}
+	}
+}
+
+func userDocument(user *model.User) search.Document {
+	return search.Document{ID: user.ID, Fields: map[string]string{
+		"username": user.Username,
+		"email":    user.Email,
+	}}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/internal/factory"
+	"github.com/jtsang4/go-stater/pkg/cache"
+	"github.com/jtsang4/go-stater/pkg/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func searchUsernames(t *testing.T, s *UserSearchService, q string) []string {
+	t.Helper()
+	result, err := s.Search(context.Background(), &SearchUsersRequest{Query: q})
+	require.NoError(t, err)
+	usernames := make([]string, len(result.Hits))
+	for i, hit := range result.Hits {
+		usernames[i] = hit.User.Username
+	}
+	return usernames
+}
+
+func TestUserSearchFollowsUserChanges(t *testing.T) {
+	ctx := context.Background()
+	s := newTestUserStore(t, factory.User().WithUsername("alice").WithEmail("alice@example.com").Build())
+	memoryCache, err := cache.NewMemoryCache(0, "lru")
+	require.NoError(t, err)
+	userService := NewUserService(s, &MockTxManager{}, s.outbox, memoryCache, cache.NewMemoryLocker(), config.UserConfig{})
+	searchService := NewUserSearchService(s, search.NewMemoryIndex(UserSearchFields...))
+	userService.AddHook(searchService)
+
+	// 已有用户在重建索引后才能搜到
+	assert.Empty(t, searchUsernames(t, searchService, "alice"))
+	require.NoError(t, searchService.Rebuild(ctx))
+	assert.Equal(t, []string{"alice"}, searchUsernames(t, searchService, "alcie"))
+
+	bob, err := userService.CreateUser(ctx, &CreateUserRequest{Username: "bob", Password: "password123", Email: "bob@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, searchUsernames(t, searchService, "bob"))
+
+	_, err = userService.UpdateUser(ctx, bob.ID, &UpdateUserRequest{Email: "robert@corp.io"}, 0)
+	require.NoError(t, err)
+	// 进程内索引保存解密后的邮箱，支持部分匹配和容错搜索
+	assert.Equal(t, []string{"bob"}, searchUsernames(t, searchService, "corp"))
+	assert.Equal(t, []string{"bob"}, searchUsernames(t, searchService, "Robert@corp.io"))
+	assert.Equal(t, []string{"alice"}, searchUsernames(t, searchService, "exmaple"))
+
+	_, err = userService.ChangeUsername(ctx, bob.ID, &ChangeUsernameRequest{Username: "robert"}, 0)
+	require.NoError(t, err)
+	result, err := searchService.Search(ctx, &SearchUsersRequest{Query: "robert"})
+	require.NoError(t, err)
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, "<em>robert</em>", result.Hits[0].Highlights["username"])
+
+	require.NoError(t, userService.DeleteUser(ctx, bob.ID, 0))
+	assert.Empty(t, searchUsernames(t, searchService, "robert"))
+}
+
+func TestUserSearchSkipsStaleHits(t *testing.T) {
+	ctx := context.Background()
+	alice := factory.User().WithID(1).WithUsername("alice").Build()
+	s := newTestUserStore(t, alice)
+	searchService := NewUserSearchService(s, search.NewMemoryIndex(UserSearchFields...))
+	require.NoError(t, searchService.Rebuild(ctx))
+
+	// 其他实例删除的用户仍在进程内索引中
+	require.NoError(t, s.Delete(ctx, alice))
+	result, err := searchService.Search(ctx, &SearchUsersRequest{Query: "alice"})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+	assert.Zero(t, result.Total)
+}
+
+// usernameIndex 只索引用户名，模拟数据库全文索引（不是 *search.MemoryIndex）
+type usernameIndex struct {
+	*search.MemoryIndex
+}
+
+// 数据库索引不包含加密的邮箱，只能按完整邮箱精确查找
+func TestUserSearchEmailWithoutEmailIndex(t *testing.T) {
+	ctx := context.Background()
+	alice := factory.User().WithUsername("alice").WithEmail("alice@example.com").Build()
+	s := newTestUserStore(t, alice)
+	index := usernameIndex{search.NewMemoryIndex(UserDBSearchFields...)}
+	searchService := NewUserSearchService(s, index)
+	searchService.UserSaved(ctx, alice)
+
+	assert.Equal(t, []string{"alice"}, searchUsernames(t, searchService, "Alice@Example.com"))
+	assert.Empty(t, searchUsernames(t, searchService, "example"))
+	assert.Empty(t, searchUsernames(t, searchService, "alice@example"))
+}
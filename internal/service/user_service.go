@@ -11,81 +11,241 @@ import (
 	"github.com/jtsang4/go-stater/internal/repository"
 	"github.com/jtsang4/go-stater/pkg/auth"
 	"github.com/jtsang4/go-stater/pkg/cache"
+	"github.com/jtsang4/go-stater/pkg/database"
 	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/jtsang4/go-stater/pkg/outbox"
+	"github.com/jtsang4/go-stater/pkg/store"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+const defaultUserCacheTTL = time.Hour
+
+const (
+	usernameLockTTL  = 10 * time.Second
+	usernameLockWait = 5 * time.Second
+)
+
+// 用户相关的领域事件，写入 outbox 后异步投递
+const (
+	userAggregate         = "user"
+	EventUserRegistered   = "user.registered"
+	EventUserEmailChanged = "user.email_changed"
+)
+
+var (
+	// ErrVersionMismatch 表示客户端持有的版本（If-Match）已不是最新版本
+	ErrVersionMismatch = errors.New("user has been modified, reload and retry")
+	// ErrVersionConflict 表示写入时用户已被并发修改
+	ErrVersionConflict = repository.ErrVersionConflict
+
+	ErrUsernameTaken    = errors.New("username already exists")
+	ErrUsernameReserved = errors.New("username is reserved")
+	ErrEmailTaken       = errors.New("email already exists")
+	// ErrUserExists 表示写入时违反了用户名或邮箱的唯一索引，通常是并发注册
+	ErrUserExists = errors.New("username or email already exists")
+	// ErrUsernameUnchanged 表示新用户名与当前用户名相同
+	ErrUsernameUnchanged = errors.New("new username is the same as the current one")
+	// ErrUsernameChangeTooSoon 表示距离上次改名不足 UsernameChangeDays 天
+	ErrUsernameChangeTooSoon = errors.New("username was changed too recently")
+)
+
+// UserHook 在创建、修改或删除用户的事务提交后调用，例如同步搜索索引。
+// 调用时修改已经提交，实现自行处理和记录错误
+type UserHook interface {
+	UserSaved(ctx context.Context, user *model.User)
+	UserDeleted(ctx context.Context, id uint)
+}
+
 type UserService struct {
-	repo  repository.UserRepositoryInterface
-	cache cache.RedisCacheInterface
+	repo   repository.UserRepositoryInterface
+	tx     database.TxManagerInterface
+	outbox outbox.OutboxInterface
+	cache  cache.RedisCacheInterface
+	loader *cache.Loader
+	tags   *cache.TagSet
+	locker *cache.Locker
+	cfg    config.UserConfig
+	hooks  []UserHook
+
+	invalidateBackoff time.Duration
 }
 
-func NewUserService(repo repository.UserRepositoryInterface, cache cache.RedisCacheInterface) *UserService {
+func NewUserService(repo repository.UserRepositoryInterface, tx database.TxManagerInterface, events outbox.OutboxInterface, c cache.RedisCacheInterface, locker *cache.Locker, cfg config.UserConfig) *UserService {
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = defaultUserCacheTTL
+	}
+
 	return &UserService{
-		repo:  repo,
-		cache: cache,
+		repo:   repo,
+		tx:     tx,
+		outbox: events,
+		cache:  c,
+		loader: cache.NewLoader(c, cache.LoaderOptions{
+			StaleTTL:    cfg.CacheStaleTTL,
+			Jitter:      cfg.CacheJitter,
+			NegativeTTL: cfg.CacheNegativeTTL,
+			LoadTimeout: cfg.CacheLoadTimeout,
+		}),
+		tags:   cache.NewTagSet(c),
+		locker: locker,
+		cfg:    cfg,
+
+		invalidateBackoff: invalidateBackoff,
 	}
 }
 
+// AddHook 注册 UserHook，需要在处理请求之前调用
+func (s *UserService) AddHook(hook UserHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+func (s *UserService) userSaved(ctx context.Context, user *model.User) {
+	for _, hook := range s.hooks {
+		hook.UserSaved(ctx, user)
+	}
+}
+
+func (s *UserService) userDeleted(ctx context.Context, id uint) {
+	for _, hook := range s.hooks {
+		hook.UserDeleted(ctx, id)
+	}
+}
+
+// UserRegisteredEvent 是 user.registered 事件的内容。
+// 事件明文存储在 outbox 表并投递到下游，不包含邮箱，消费者按 user_id 查询
+type UserRegisteredEvent struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// UserEmailChangedEvent 是 user.email_changed 事件的内容，同样不包含邮箱
+type UserEmailChangedEvent struct {
+	UserID uint `json:"user_id"`
+}
+
+// addEvent 将用户事件写入 outbox，需要在修改用户的事务中调用
+func (s *UserService) addEvent(ctx context.Context, userID uint, eventType string, payload interface{}) error {
+	event, err := outbox.NewEvent(userAggregate, userID, eventType, payload)
+	if err != nil {
+		return err
+	}
+	return s.outbox.Add(ctx, event)
+}
+
+// withUsernameLock 在持有用户名锁的情况下执行 fn，避免多个实例并发检查后创建相同的用户名。
+// 最多等待 usernameLockWait 获取锁，锁丢失时 fn 的 ctx 会被取消。
+// 锁的后端不可用（例如 Redis 宕机或熔断）时不加锁执行 fn，由 username 的唯一索引防止重复
+func (s *UserService) withUsernameLock(ctx context.Context, username string, fn func(ctx context.Context) error) error {
+	locked := false
+	err := s.locker.WithLockWait(ctx, "username:"+username, usernameLockTTL, usernameLockWait, func(ctx context.Context) error {
+		locked = true
+		return fn(ctx)
+	})
+	if locked || !errors.Is(err, cache.ErrLockUnavailable) {
+		return err
+	}
+	logger.FromContext(ctx).Warn("username lock unavailable, relying on unique index", zap.String("username", username), zap.Error(err))
+	return fn(ctx)
+}
+
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=32"`
 	Password string `json:"password" binding:"required,min=6,max=32"`
-	Email    string `json:"email" binding:"required,email"`
+	Email    string `json:"email" binding:"required,email,max=128"`
 }
 
-func (s *UserService) CreateUser(req *CreateUserRequest) (*model.User, error) {
-	// Check if username exists
-	if _, err := s.repo.GetByUsername(req.Username); err == nil {
-		return nil, errors.New("username already exists")
-	}
-
+func (s *UserService) CreateUser(ctx context.Context, req *CreateUserRequest) (*model.User, error) {
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
 
-	user := &model.User{
-		Username: req.Username,
-		Password: string(hashedPassword),
-		Email:    req.Email,
-	}
+	var user *model.User
+	err = s.withUsernameLock(ctx, req.Username, func(ctx context.Context) error {
+		return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+			// Check if username exists
+			if _, err := s.repo.GetByUsername(ctx, req.Username); err == nil {
+				return ErrUsernameTaken
+			}
 
-	if err := s.repo.Create(user); err != nil {
+			// Check if username is reserved by a recent rename
+			if reserved, err := s.isUsernameReserved(ctx, req.Username, 0); err != nil {
+				return err
+			} else if reserved {
+				return ErrUsernameReserved
+			}
+
+			// 邮箱加密存储，按盲索引检查是否已被使用
+			if _, err := s.repo.GetByEmail(ctx, req.Email); err == nil {
+				return ErrEmailTaken
+			}
+
+			// 事务可能重试，每次都使用新的对象
+			user = &model.User{
+				Username: req.Username,
+				Password: string(hashedPassword),
+				Email:    req.Email,
+				Role:     model.RoleUser,
+			}
+			if err := s.repo.Create(ctx, user); err != nil {
+				if errors.Is(err, gorm.ErrDuplicatedKey) {
+					return ErrUserExists
+				}
+				return err
+			}
+			return s.addEvent(ctx, user.ID, EventUserRegistered, UserRegisteredEvent{
+				UserID:   user.ID,
+				Username: user.Username,
+			})
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	// 清除创建前可能缓存的 "用户不存在" 结果
+	s.invalidateUser(ctx, user.ID, user)
+	s.userSaved(ctx, user)
+
 	return user, nil
 }
 
-func (s *UserService) GetUserByID(id uint) (*model.User, error) {
-	// 尝试从缓存获取
-	var user *model.User
-	cacheKey := fmt.Sprintf("user:%d", id)
-
-	ctx := context.Background()
-	err := s.cache.Get(ctx, cacheKey, &user)
-	if err == nil {
-		return user, nil
+func (s *UserService) GetUserByID(ctx context.Context, id uint) (*model.User, error) {
+	key, err := s.tags.Resolve(ctx, userProfileKey(id))
+	if err != nil {
+		// 无法确定当前的标签版本时绕过缓存，避免读到旧数据
+		logger.FromContext(ctx).Warn("failed to resolve user cache key", zap.Uint("user_id", id), zap.Error(err))
+		return s.repo.GetByID(ctx, id)
 	}
 
-	// 缓存未命中，从数据库获取
-	user, err = s.repo.GetByID(id)
+	// 缓存未命中时从数据库加载，同一用户的并发加载只会查询一次数据库。
+	// 回填的数据会在缓存中保存 CacheTTL，必须从主库读取，不能使用落后的从库
+	var snapshot userSnapshot
+	err = s.loader.GetOrLoad(ctx, key, s.cfg.CacheTTL, &snapshot, func(ctx context.Context) (interface{}, error) {
+		user, err := s.repo.GetByID(database.WithPrimary(ctx), id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, cache.ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return newUserSnapshot(user), nil
+	})
+	if errors.Is(err, cache.ErrNotFound) {
+		return nil, gorm.ErrRecordNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// 设置缓存
-	if err := s.cache.Set(ctx, cacheKey, user, time.Hour); err != nil {
-		logger.Logger.Warn("failed to set cache", zap.Error(err))
-	}
-
-	return user, nil
+	return snapshot.user(), nil
 }
 
-func (s *UserService) ValidateUser(username, password string) (*model.User, error) {
-	user, err := s.repo.GetByUsername(username)
+func (s *UserService) ValidateUser(ctx context.Context, username, password string) (*model.User, error) {
+	user, err := s.repo.GetByUsername(ctx, username)
 	if err != nil {
 		return nil, errors.New("invalid username or password")
 	}
@@ -102,14 +262,14 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-func (s *UserService) Login(req *LoginRequest, cfg config.JWTConfig) (string, error) {
-	user, err := s.ValidateUser(req.Username, req.Password)
+func (s *UserService) Login(ctx context.Context, req *LoginRequest, cfg config.JWTConfig) (string, error) {
+	user, err := s.ValidateUser(ctx, req.Username, req.Password)
 	if err != nil {
 		return "", err
 	}
 
 	// Generate JWT token
-	token, err := auth.GenerateToken(user.ID, cfg)
+	token, err := auth.GenerateToken(user.ID, user.Role, cfg)
 	if err != nil {
 		return "", err
 	}
@@ -118,35 +278,185 @@ func (s *UserService) Login(req *LoginRequest, cfg config.JWTConfig) (string, er
 }
 
 type UpdateUserRequest struct {
-	Email    string `json:"email" binding:"omitempty,email"`
+	Email    string `json:"email" binding:"omitempty,email,max=128"`
 	Password string `json:"password" binding:"omitempty,min=6,max=32"`
 }
 
-func (s *UserService) UpdateUser(id uint, req *UpdateUserRequest) (*model.User, error) {
-	user, err := s.repo.GetByID(id)
+// UpdateUser 更新用户信息。version 为客户端读取到的版本号，与当前版本不一致时返回 ErrVersionMismatch，0 表示不检查
+func (s *UserService) UpdateUser(ctx context.Context, id uint, req *UpdateUserRequest, version uint) (*model.User, error) {
+	// 在事务外计算哈希，避免长时间持有行锁
+	var hashedPassword []byte
+	if req.Password != "" {
+		var err error
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 读取和保存在同一事务中，并发更新不会覆盖彼此的修改
+	var user *model.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.repo.GetByID(ctx, id, store.ForUpdate())
+		if err != nil {
+			return err
+		}
+		if version != 0 && user.Version != version {
+			return ErrVersionMismatch
+		}
+
+		oldEmail := user.Email
+		if req.Email != "" && req.Email != oldEmail {
+			if other, err := s.repo.GetByEmail(ctx, req.Email); err == nil && other.ID != user.ID {
+				return ErrEmailTaken
+			}
+			user.Email = req.Email
+		}
+		if hashedPassword != nil {
+			user.Password = string(hashedPassword)
+		}
+
+		if err := s.repo.Update(ctx, user); err != nil {
+			return err
+		}
+		if user.Email == oldEmail {
+			return nil
+		}
+		return s.addEvent(ctx, user.ID, EventUserEmailChanged, UserEmailChangedEvent{UserID: user.ID})
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if req.Email != "" {
-		user.Email = req.Email
-	}
+	s.invalidateUser(ctx, user.ID, user)
+	s.userSaved(ctx, user)
 
-	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	return user, nil
+}
+
+// DeleteUser 删除用户，version 的含义与 UpdateUser 相同
+func (s *UserService) DeleteUser(ctx context.Context, id uint, version uint) error {
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetByID(ctx, id, store.ForUpdate())
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if version != 0 && user.Version != version {
+			return ErrVersionMismatch
 		}
-		user.Password = string(hashedPassword)
+		return s.repo.Delete(ctx, user)
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := s.repo.Update(user); err != nil {
+	s.invalidateUser(ctx, id, nil)
+	s.userDeleted(ctx, id)
+
+	return nil
+}
+
+type ChangeUsernameRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=32"`
+}
+
+// ChangeUsername 修改用户名。两次修改之间至少间隔 UsernameChangeDays 天，
+// 旧用户名在 UsernameReservationDays 天内保留给原用户，防止被他人抢注。
+// version 为客户端读取到的版本号，与当前版本不一致时返回 ErrVersionMismatch，0 表示不检查
+func (s *UserService) ChangeUsername(ctx context.Context, id uint, req *ChangeUsernameRequest, version uint) (*model.User, error) {
+	now := time.Now()
+	var user *model.User
+	err := s.withUsernameLock(ctx, req.Username, func(ctx context.Context) error {
+		// 锁定用户行，并发的改名请求依次检查修改间隔
+		return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+			var err error
+			user, err = s.repo.GetByID(ctx, id, store.ForUpdate())
+			if err != nil {
+				return err
+			}
+			if version != 0 && user.Version != version {
+				return ErrVersionMismatch
+			}
+
+			if user.Username == req.Username {
+				return ErrUsernameUnchanged
+			}
+
+			if s.cfg.UsernameChangeDays > 0 && user.UsernameChangedAt != nil {
+				nextAllowed := user.UsernameChangedAt.AddDate(0, 0, s.cfg.UsernameChangeDays)
+				if now.Before(nextAllowed) {
+					return fmt.Errorf("%w: it can only be changed once every %d days, next change allowed after %s",
+						ErrUsernameChangeTooSoon, s.cfg.UsernameChangeDays, nextAllowed.Format(time.RFC3339))
+				}
+			}
+
+			if existing, err := s.repo.GetByUsername(ctx, req.Username); err == nil && existing.ID != user.ID {
+				return ErrUsernameTaken
+			}
+
+			if reserved, err := s.isUsernameReserved(ctx, req.Username, user.ID); err != nil {
+				return err
+			} else if reserved {
+				return ErrUsernameReserved
+			}
+
+			history := &model.UsernameHistory{
+				UserID:        user.ID,
+				OldUsername:   user.Username,
+				NewUsername:   req.Username,
+				ReservedUntil: now.AddDate(0, 0, s.cfg.UsernameReservationDays),
+			}
+			user.Username = req.Username
+			user.UsernameChangedAt = &now
+
+			err = s.repo.ChangeUsername(ctx, user, history)
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrUsernameTaken
+			}
+			return err
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	s.invalidateUser(ctx, user.ID, user)
+	s.userSaved(ctx, user)
+
 	return user, nil
 }
 
-func (s *UserService) DeleteUser(id uint) error {
-	return s.repo.Delete(id)
+// GetUserByUsername 按用户名查找用户。若 username 是某个用户改名前的旧用户名，
+// 返回该用户的当前信息，并将 renamed 置为 true
+func (s *UserService) GetUserByUsername(ctx context.Context, username string) (user *model.User, renamed bool, err error) {
+	user, err = s.repo.GetByUsername(ctx, username)
+	if err == nil {
+		return user, false, nil
+	}
+
+	history, historyErr := s.repo.GetLatestUsernameHistory(ctx, username)
+	if historyErr != nil {
+		return nil, false, err
+	}
+
+	user, err = s.GetUserByID(ctx, history.UserID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return user, true, nil
+}
+
+// isUsernameReserved 判断 username 是否仍在其他用户的保留期内，ownerID 为允许使用该保留名的用户。
+// 查询出错时返回错误，不能当作未保留，否则数据库异常时保留名可能被他人占用
+func (s *UserService) isUsernameReserved(ctx context.Context, username string, ownerID uint) (bool, error) {
+	history, err := s.repo.GetLatestUsernameHistory(ctx, username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return history.UserID != ownerID && history.ReservedUntil.After(time.Now()), nil
 }
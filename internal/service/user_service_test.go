@@ -1,61 +1,120 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/internal/factory"
 	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/internal/repository"
+	"github.com/jtsang4/go-stater/pkg/cache"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/encryption"
+	"github.com/jtsang4/go-stater/pkg/outbox"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
-// Mock repository
-type MockUserRepository struct {
-	mock.Mock
+func TestMain(m *testing.M) {
+	// model.User 的邮箱加密存储
+	encryption.SetDefault(encryption.NewTestKeyring())
+	os.Exit(m.Run())
 }
 
-func (m *MockUserRepository) Create(user *model.User) error {
-	args := m.Called(user)
-	return args.Error(0)
+// testUserStore 是基于内存存储的用户仓储，users、histories 和 events 用于准备数据和检查结果
+type testUserStore struct {
+	*repository.UserRepository
+	users     *store.MemoryRepository[model.User]
+	histories *store.MemoryRepository[model.UsernameHistory]
+	events    *store.MemoryRepository[outbox.Event]
+	outbox    *outbox.Outbox
 }
 
-func (m *MockUserRepository) GetByID(id uint) (*model.User, error) {
-	args := m.Called(id)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
+// newTestUserStore 创建内存用户仓储并写入 users
+func newTestUserStore(t *testing.T, users ...*model.User) *testUserStore {
+	t.Helper()
+	s := &testUserStore{
+		users:     store.NewMemoryRepository[model.User](),
+		histories: store.NewMemoryRepository[model.UsernameHistory](),
+		events:    store.NewMemoryRepository[outbox.Event](),
 	}
-	return args.Get(0).(*model.User), args.Error(1)
+	s.outbox = outbox.NewOutboxFrom(s.events)
+	s.UserRepository = repository.NewUserRepositoryFrom(s.users, s.histories, &MockTxManager{})
+	for _, user := range users {
+		require.NoError(t, s.users.Create(context.Background(), user))
+	}
+	return s
+}
+
+// reserve 写入一条 username 被 userID 放弃、保留到 until 的历史记录
+func (s *testUserStore) reserve(t *testing.T, username string, userID uint, until time.Time) {
+	t.Helper()
+	require.NoError(t, s.histories.Create(context.Background(), &model.UsernameHistory{
+		UserID:        userID,
+		OldUsername:   username,
+		NewUsername:   username + "-renamed",
+		ReservedUntil: until,
+	}))
 }
 
-func (m *MockUserRepository) GetByUsername(username string) (*model.User, error) {
-	args := m.Called(username)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
+// assertEvents 检查 outbox 中按顺序写入了 types 类型的事件
+func (s *testUserStore) assertEvents(t *testing.T, types ...string) []*outbox.Event {
+	t.Helper()
+	events, err := s.events.Find(context.Background(), store.OrderBy("id"))
+	require.NoError(t, err)
+	got := make([]string, len(events))
+	for i, event := range events {
+		got[i] = event.Type
+	}
+	if len(types) == 0 {
+		assert.Empty(t, got)
+	} else {
+		assert.Equal(t, types, got)
 	}
-	return args.Get(0).(*model.User), args.Error(1)
+	return events
 }
 
-func (m *MockUserRepository) Update(user *model.User) error {
-	args := m.Called(user)
-	return args.Error(0)
+// hookedUserRepository 在 GetByID 返回前执行一次 onGet，用于模拟读取之后发生的并发写入
+type hookedUserRepository struct {
+	repository.UserRepositoryInterface
+	onGet   func()
+	primary []bool // 每次 GetByID 是否要求读主库
 }
 
-func (m *MockUserRepository) Delete(id uint) error {
-	args := m.Called(id)
-	return args.Error(0)
+func (r *hookedUserRepository) GetByID(ctx context.Context, id interface{}, specs ...store.Spec) (*model.User, error) {
+	r.primary = append(r.primary, database.PrimaryRequested(ctx))
+	user, err := r.UserRepositoryInterface.GetByID(ctx, id, specs...)
+	if onGet := r.onGet; onGet != nil {
+		r.onGet = nil
+		onGet()
+	}
+	return user, err
 }
 
-func TestCreateUser(t *testing.T) {
-	mockRepo := new(MockUserRepository)
-	mockCache := new(MockCache)
-	service := NewUserService(mockRepo, mockCache)
+// MockTxManager 直接执行 fn，不提供回滚
+type MockTxManager struct {
+	calls int
+}
 
+func (m *MockTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.calls++
+	return fn(ctx)
+}
+
+func TestCreateUser(t *testing.T) {
 	tests := []struct {
 		name    string
 		req     *CreateUserRequest
-		mock    func()
+		setup   func(t *testing.T, s *testUserStore, mockCache *MockCache)
 		wantErr bool
 	}{
 		{
@@ -65,12 +124,25 @@ func TestCreateUser(t *testing.T) {
 				Password: "password123",
 				Email:    "test@example.com",
 			},
-			mock: func() {
-				mockRepo.On("GetByUsername", "testuser").Return(nil, errors.New("not found"))
-				mockRepo.On("Create", mock.AnythingOfType("*model.User")).Return(nil)
+			setup: func(t *testing.T, s *testUserStore, mockCache *MockCache) {
+				mockCache.On("Incr", mock.Anything, "tag:user:1").Return(int64(1), nil)
+				expectCachedVersion(mockCache, "tag:user:1", 1)
+				mockCache.On("Set", mock.Anything, "user:1:profile@1", mock.AnythingOfType("*cache.Entry"), mock.Anything).Return(nil)
 			},
 			wantErr: false,
 		},
+		{
+			name: "username reserved",
+			req: &CreateUserRequest{
+				Username: "reserveduser",
+				Password: "password123",
+				Email:    "test@example.com",
+			},
+			setup: func(t *testing.T, s *testUserStore, mockCache *MockCache) {
+				s.reserve(t, "reserveduser", 7, time.Now().Add(time.Hour))
+			},
+			wantErr: true,
+		},
 		{
 			name: "username exists",
 			req: &CreateUserRequest{
@@ -78,8 +150,20 @@ func TestCreateUser(t *testing.T) {
 				Password: "password123",
 				Email:    "test@example.com",
 			},
-			mock: func() {
-				mockRepo.On("GetByUsername", "existinguser").Return(&model.User{}, nil)
+			setup: func(t *testing.T, s *testUserStore, mockCache *MockCache) {
+				require.NoError(t, s.Create(context.Background(), factory.User().WithUsername("existinguser").WithEmail("existing@example.com").Build()))
+			},
+			wantErr: true,
+		},
+		{
+			name: "email exists",
+			req: &CreateUserRequest{
+				Username: "newuser",
+				Password: "password123",
+				Email:    "Existing@Example.com",
+			},
+			setup: func(t *testing.T, s *testUserStore, mockCache *MockCache) {
+				require.NoError(t, s.Create(context.Background(), factory.User().WithUsername("existinguser").WithEmail("existing@example.com").Build()))
 			},
 			wantErr: true,
 		},
@@ -87,68 +171,154 @@ func TestCreateUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.mock()
-			user, err := service.CreateUser(tt.req)
+			s := newTestUserStore(t)
+			mockCache := newMockCache()
+			service := NewUserService(s, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), config.UserConfig{})
+			tt.setup(t, s, mockCache)
+			before, err := s.Count(context.Background())
+			require.NoError(t, err)
+
+			user, err := service.CreateUser(context.Background(), tt.req)
 			if tt.wantErr {
 				assert.Error(t, err)
 				assert.Nil(t, user)
+				after, err := s.Count(context.Background())
+				require.NoError(t, err)
+				assert.Equal(t, before, after, "no user is created")
+				s.assertEvents(t)
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, user)
+				stored, err := s.GetByUsername(context.Background(), tt.req.Username)
+				require.NoError(t, err)
+				assert.Equal(t, user.ID, stored.ID)
+				assert.NotEqual(t, tt.req.Password, stored.Password)
+				events := s.assertEvents(t, EventUserRegistered)
+				assert.NotContains(t, events[0].Payload, tt.req.Email, "events are stored in plaintext")
+				mockCache.AssertExpectations(t)
 			}
 		})
 	}
 }
 
+// racingUserRepository 的 GetByUsername 总是查不到，模拟检查之后另一个请求抢先创建了同名用户
+type racingUserRepository struct {
+	repository.UserRepositoryInterface
+}
+
+func (r *racingUserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+// brokenHistoryRepository 查询用户名历史时总是出错
+type brokenHistoryRepository struct {
+	repository.UserRepositoryInterface
+}
+
+func (r *brokenHistoryRepository) GetLatestUsernameHistory(ctx context.Context, oldUsername string) (*model.UsernameHistory, error) {
+	return nil, errors.New("connection reset")
+}
+
+// 无法确认用户名是否被保留时拒绝使用，而不是当作未保留
+func TestUsernameReservationCheckFails(t *testing.T) {
+	ctx := context.Background()
+	s := newTestUserStore(t, factory.User().WithID(1).WithUsername("olduser").Build())
+	service := NewUserService(&brokenHistoryRepository{s}, &MockTxManager{}, s.outbox, newMockCache(), cache.NewMemoryLocker(), config.UserConfig{})
+
+	_, err := service.CreateUser(ctx, &CreateUserRequest{Username: "reserved", Password: "password123", Email: "new@example.com"})
+	assert.EqualError(t, err, "connection reset")
+	_, err = service.ChangeUsername(ctx, 1, &ChangeUsernameRequest{Username: "reserved"}, 0)
+	assert.EqualError(t, err, "connection reset")
+
+	stored, err := s.GetByID(ctx, uint(1))
+	require.NoError(t, err)
+	assert.Equal(t, "olduser", stored.Username)
+	count, err := s.Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+// Redis 不可用时不加锁注册，由唯一索引防止重复的用户名
+func TestCreateUserWithoutLocker(t *testing.T) {
+	ctx := context.Background()
+	redisServer := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	redisServer.Close()
+	locker := cache.NewRedisLocker(client, "")
+
+	c, err := cache.NewMemoryCache(0, cache.EvictionLRU)
+	require.NoError(t, err)
+	s := newTestUserStore(t)
+	service := NewUserService(&racingUserRepository{s}, &MockTxManager{}, s.outbox, c, locker, config.UserConfig{})
+
+	req := &CreateUserRequest{Username: "testuser", Password: "password123", Email: "test@example.com"}
+	user, err := service.CreateUser(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "testuser", user.Username)
+
+	_, err = service.CreateUser(ctx, &CreateUserRequest{Username: "testuser", Password: "password123", Email: "other@example.com"})
+	assert.ErrorIs(t, err, ErrUserExists)
+	count, err := s.Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
 func TestGetUserByID(t *testing.T) {
-	mockRepo := new(MockUserRepository)
-	mockCache := new(MockCache)
-	service := NewUserService(mockRepo, mockCache)
+	s := newTestUserStore(t, factory.User().WithID(2).WithUsername("testuser2").WithEmail("test2@example.com").Build())
+	mockCache := newMockCache()
+	service := NewUserService(s, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), config.UserConfig{})
 
 	tests := []struct {
-		name    string
-		id      uint
-		mock    func()
-		want    *model.User
-		wantErr bool
+		name     string
+		id       uint
+		mock     func()
+		wantName string
+		wantErr  bool
 	}{
 		{
 			name: "success from cache",
 			id:   1,
 			mock: func() {
-				user := &model.User{ID: 1, Username: "testuser"}
-				mockCache.On("Get", mock.Anything, "user:1", mock.AnythingOfType("*model.User")).
+				user := factory.User().WithID(1).WithUsername("testuser").Build()
+				mockCache.On("Get", mock.Anything, "tag:user:1", mock.AnythingOfType("*int64")).
 					Run(func(args mock.Arguments) {
-						arg := args.Get(2).(*model.User)
-						*arg = *user
+						*args.Get(2).(*int64) = 3
 					}).
 					Return(nil)
+				mockCache.On("Get", mock.Anything, "user:1:profile@3", mock.AnythingOfType("*cache.Entry")).
+					Run(cachedUser(user)).
+					Return(nil)
 			},
-			want:    &model.User{ID: 1, Username: "testuser"},
-			wantErr: false,
+			wantName: "testuser",
+			wantErr:  false,
 		},
 		{
 			name: "success from database",
 			id:   2,
 			mock: func() {
-				user := &model.User{ID: 2, Username: "testuser2"}
-				mockCache.On("Get", mock.Anything, "user:2", mock.AnythingOfType("*model.User")).
-					Return(errors.New("cache miss"))
-				mockRepo.On("GetByID", uint(2)).Return(user, nil)
-				mockCache.On("Set", mock.Anything, "user:2", user, mock.Anything).Return(nil)
+				mockCache.On("Get", mock.Anything, "tag:user:2", mock.AnythingOfType("*int64")).
+					Return(cache.ErrMiss)
+				mockCache.On("Incr", mock.Anything, "tag:user:2").Return(int64(1), nil)
+				mockCache.On("Get", mock.Anything, "user:2:profile@1", mock.AnythingOfType("*cache.Entry")).
+					Return(cache.ErrMiss)
+				mockCache.On("Set", mock.Anything, "user:2:profile@1", userEntry(func(u *model.User) bool {
+					return u.ID == 2 && u.Username == "testuser2"
+				}), mock.Anything).Return(nil)
 			},
-			want:    &model.User{ID: 2, Username: "testuser2"},
-			wantErr: false,
+			wantName: "testuser2",
+			wantErr:  false,
 		},
 		{
 			name: "user not found",
 			id:   3,
 			mock: func() {
-				mockCache.On("Get", mock.Anything, "user:3", mock.AnythingOfType("*model.User")).
-					Return(errors.New("cache miss"))
-				mockRepo.On("GetByID", uint(3)).Return(nil, errors.New("not found"))
+				mockCache.On("Get", mock.Anything, "tag:user:3", mock.AnythingOfType("*int64")).
+					Return(cache.ErrMiss)
+				mockCache.On("Incr", mock.Anything, "tag:user:3").Return(int64(1), nil)
+				mockCache.On("Get", mock.Anything, "user:3:profile@1", mock.AnythingOfType("*cache.Entry")).
+					Return(cache.ErrMiss)
 			},
-			want:    nil,
 			wantErr: true,
 		},
 	}
@@ -156,27 +326,26 @@ func TestGetUserByID(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock()
-			got, err := service.GetUserByID(tt.id)
+			got, err := service.GetUserByID(context.Background(), tt.id)
 			if tt.wantErr {
 				assert.Error(t, err)
 				assert.Nil(t, got)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.want, got)
+				assert.Equal(t, tt.id, got.ID)
+				assert.Equal(t, tt.wantName, got.Username)
 			}
 		})
 	}
 }
 
 func TestLogin(t *testing.T) {
-	mockRepo := new(MockUserRepository)
-	mockCache := new(MockCache)
-	service := NewUserService(mockRepo, mockCache)
+	s := newTestUserStore(t, factory.User().WithUsername("testuser").WithEmail("test@example.com").Build())
+	service := NewUserService(s, &MockTxManager{}, s.outbox, newMockCache(), cache.NewMemoryLocker(), config.UserConfig{})
 
 	tests := []struct {
 		name    string
 		req     *LoginRequest
-		mock    func()
 		wantErr bool
 	}{
 		{
@@ -185,15 +354,6 @@ func TestLogin(t *testing.T) {
 				Username: "testuser",
 				Password: "password123",
 			},
-			mock: func() {
-				hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
-				user := &model.User{
-					ID:       1,
-					Username: "testuser",
-					Password: string(hashedPassword),
-				}
-				mockRepo.On("GetByUsername", "testuser").Return(user, nil)
-			},
 			wantErr: false,
 		},
 		{
@@ -202,15 +362,6 @@ func TestLogin(t *testing.T) {
 				Username: "testuser",
 				Password: "wrongpassword",
 			},
-			mock: func() {
-				hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
-				user := &model.User{
-					ID:       1,
-					Username: "testuser",
-					Password: string(hashedPassword),
-				}
-				mockRepo.On("GetByUsername", "testuser").Return(user, nil)
-			},
 			wantErr: true,
 		},
 		{
@@ -219,17 +370,13 @@ func TestLogin(t *testing.T) {
 				Username: "nonexistent",
 				Password: "password123",
 			},
-			mock: func() {
-				mockRepo.On("GetByUsername", "nonexistent").Return(nil, errors.New("not found"))
-			},
 			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.mock()
-			token, err := service.Login(tt.req, config.JWTConfig{Secret: "test", ExpireTime: 24})
+			token, err := service.Login(context.Background(), tt.req, config.JWTConfig{Secret: "test", ExpireTime: 24})
 			if tt.wantErr {
 				assert.Error(t, err)
 				assert.Empty(t, token)
@@ -240,3 +387,212 @@ func TestLogin(t *testing.T) {
 		})
 	}
 }
+
+func TestChangeUsername(t *testing.T) {
+	cfg := config.UserConfig{UsernameChangeDays: 30, UsernameReservationDays: 90}
+	recently := time.Now().Add(-24 * time.Hour)
+	longAgo := time.Now().AddDate(0, 0, -31)
+
+	tests := []struct {
+		name    string
+		user    *model.User
+		newName string
+		version uint
+		setup   func(t *testing.T, s *testUserStore, mockCache *MockCache)
+		wantErr error
+	}{
+		{
+			name:    "success",
+			user:    factory.User().WithID(1).WithUsername("olduser").WithUsernameChangedAt(longAgo).Build(),
+			newName: "newuser",
+			version: 1,
+			setup: func(t *testing.T, s *testUserStore, mockCache *MockCache) {
+				mockCache.On("Incr", mock.Anything, "tag:user:1").Return(int64(4), nil)
+				expectCachedVersion(mockCache, "tag:user:1", 4)
+				mockCache.On("Set", mock.Anything, "user:1:profile@4", userEntry(func(u *model.User) bool {
+					return u.Username == "newuser"
+				}), mock.Anything).Return(nil)
+			},
+		},
+		{
+			name:    "reclaim own reserved username",
+			user:    factory.User().WithID(1).WithUsername("newuser").WithUsernameChangedAt(longAgo).Build(),
+			newName: "olduser",
+			setup: func(t *testing.T, s *testUserStore, mockCache *MockCache) {
+				s.reserve(t, "olduser", 1, time.Now().Add(time.Hour))
+				mockCache.On("Incr", mock.Anything, "tag:user:1").Return(int64(2), nil)
+				expectCachedVersion(mockCache, "tag:user:1", 2)
+				mockCache.On("Set", mock.Anything, "user:1:profile@2", mock.Anything, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name:    "changed too recently",
+			user:    factory.User().WithID(1).WithUsername("olduser").WithUsernameChangedAt(recently).Build(),
+			newName: "newuser",
+			setup:   func(t *testing.T, s *testUserStore, mockCache *MockCache) {},
+			wantErr: ErrUsernameChangeTooSoon,
+		},
+		{
+			name:    "username taken",
+			user:    factory.User().WithID(1).WithUsername("olduser").Build(),
+			newName: "taken",
+			setup: func(t *testing.T, s *testUserStore, mockCache *MockCache) {
+				require.NoError(t, s.Create(context.Background(), factory.User().WithID(2).WithUsername("taken").WithEmail("taken@example.com").Build()))
+			},
+			wantErr: ErrUsernameTaken,
+		},
+		{
+			name:    "username reserved by another user",
+			user:    factory.User().WithID(1).WithUsername("olduser").Build(),
+			newName: "reserved",
+			setup: func(t *testing.T, s *testUserStore, mockCache *MockCache) {
+				s.reserve(t, "reserved", 2, time.Now().Add(time.Hour))
+			},
+			wantErr: ErrUsernameReserved,
+		},
+		{
+			name:    "stale version",
+			user:    factory.User().WithID(1).WithUsername("olduser").WithUsernameChangedAt(longAgo).Build(),
+			newName: "newuser",
+			version: 2,
+			setup:   func(t *testing.T, s *testUserStore, mockCache *MockCache) {},
+			wantErr: ErrVersionMismatch,
+		},
+		{
+			name:    "same username",
+			user:    factory.User().WithID(1).WithUsername("olduser").Build(),
+			newName: "olduser",
+			setup:   func(t *testing.T, s *testUserStore, mockCache *MockCache) {},
+			wantErr: ErrUsernameUnchanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			oldName := tt.user.Username
+			tt.user.Email = oldName + "@example.com"
+			s := newTestUserStore(t, tt.user)
+			mockCache := newMockCache()
+			service := NewUserService(s, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), cfg)
+			tt.setup(t, s, mockCache)
+
+			user, err := service.ChangeUsername(ctx, tt.user.ID, &ChangeUsernameRequest{Username: tt.newName}, tt.version)
+			stored, getErr := s.GetByID(ctx, tt.user.ID)
+			require.NoError(t, getErr)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, user)
+				assert.Equal(t, oldName, stored.Username)
+				_, err := s.GetLatestUsernameHistory(ctx, oldName)
+				assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.newName, user.Username)
+				assert.Equal(t, tt.newName, stored.Username)
+				assert.Equal(t, user.Version, stored.Version)
+
+				history, err := s.GetLatestUsernameHistory(ctx, oldName)
+				require.NoError(t, err)
+				assert.Equal(t, tt.user.ID, history.UserID)
+				assert.Equal(t, tt.newName, history.NewUsername)
+				assert.True(t, history.ReservedUntil.After(time.Now().AddDate(0, 0, 89)))
+				mockCache.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestGetUserByUsernameFollowsRename(t *testing.T) {
+	s := newTestUserStore(t, factory.User().WithID(5).WithUsername("current").WithEmail("current@example.com").Build())
+	s.reserve(t, "previous", 5, time.Now().Add(-time.Hour))
+	mockCache := newMockCache()
+	service := NewUserService(s, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), config.UserConfig{})
+
+	mockCache.On("Get", mock.Anything, "tag:user:5", mock.Anything).Return(cache.ErrMiss)
+	mockCache.On("Incr", mock.Anything, "tag:user:5").Return(int64(1), nil)
+	mockCache.On("Get", mock.Anything, "user:5:profile@1", mock.Anything).Return(cache.ErrMiss)
+	mockCache.On("Set", mock.Anything, "user:5:profile@1", mock.AnythingOfType("*cache.Entry"), mock.Anything).Return(nil)
+
+	got, renamed, err := service.GetUserByUsername(context.Background(), "previous")
+	assert.NoError(t, err)
+	assert.True(t, renamed)
+	assert.Equal(t, uint(5), got.ID)
+	assert.Equal(t, "current", got.Username)
+}
+
+func TestUpdateUserVersionCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    uint
+		concurrent bool // 读取之后有其他请求修改了用户
+		wantErr    error
+	}{
+		{name: "matching version", version: 3},
+		{name: "no version check", version: 0},
+		{name: "stale version", version: 2, wantErr: ErrVersionMismatch},
+		{name: "concurrent update", version: 3, concurrent: true, wantErr: ErrVersionConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestUserStore(t, factory.User().WithID(1).WithUsername("testuser").WithEmail("old@example.com").WithVersion(3).Build())
+			repo := &hookedUserRepository{UserRepositoryInterface: s}
+			if tt.concurrent {
+				repo.onGet = func() {
+					user, err := s.GetByID(ctx, 1)
+					require.NoError(t, err)
+					require.NoError(t, s.Update(ctx, user))
+				}
+			}
+			mockCache := newMockCache()
+			service := NewUserService(repo, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), config.UserConfig{})
+			mockCache.On("Incr", mock.Anything, "tag:user:1").Return(int64(2), nil)
+			mockCache.On("Get", mock.Anything, "tag:user:1", mock.Anything).Return(cache.ErrMiss)
+			mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			user, err := service.UpdateUser(ctx, 1, &UpdateUserRequest{Email: "new@example.com"}, tt.version)
+			stored, getErr := s.GetByID(ctx, 1)
+			require.NoError(t, getErr)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, user)
+				assert.Equal(t, "old@example.com", stored.Email)
+				mockCache.AssertNotCalled(t, "Incr", mock.Anything, mock.Anything)
+				s.assertEvents(t)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "new@example.com", user.Email)
+			assert.Equal(t, uint(4), user.Version)
+			assert.Equal(t, "new@example.com", stored.Email)
+			s.assertEvents(t, EventUserEmailChanged)
+		})
+	}
+}
+
+func TestUpdateUserEmailChangedEvent(t *testing.T) {
+	ctx := context.Background()
+	s := newTestUserStore(t, factory.User().WithID(1).WithUsername("testuser").WithEmail("old@example.com").Build())
+	mockCache := newMockCache()
+	service := NewUserService(s, &MockTxManager{}, s.outbox, mockCache, cache.NewMemoryLocker(), config.UserConfig{})
+	mockCache.On("Incr", mock.Anything, "tag:user:1").Return(int64(2), nil)
+	mockCache.On("Get", mock.Anything, "tag:user:1", mock.Anything).Return(cache.ErrMiss)
+	mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	// 只修改密码或邮箱不变时不产生事件
+	_, err := service.UpdateUser(ctx, 1, &UpdateUserRequest{Password: "newpassword"}, 0)
+	require.NoError(t, err)
+	_, err = service.UpdateUser(ctx, 1, &UpdateUserRequest{Email: "old@example.com"}, 0)
+	require.NoError(t, err)
+	s.assertEvents(t)
+
+	_, err = service.UpdateUser(ctx, 1, &UpdateUserRequest{Email: "new@example.com"}, 0)
+	require.NoError(t, err)
+	events := s.assertEvents(t, EventUserEmailChanged)
+	assert.Equal(t, "user", events[0].AggregateType)
+	assert.Equal(t, "1", events[0].AggregateID)
+	assert.NotEmpty(t, events[0].EventID)
+	assert.JSONEq(t, `{"user_id":1}`, events[0].Payload)
+}
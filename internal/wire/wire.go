@@ -8,28 +8,101 @@ import (
 	"github.com/jtsang4/go-stater/internal/service"
 	"github.com/jtsang4/go-stater/pkg/cache"
 	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/encryption"
+	"github.com/jtsang4/go-stater/pkg/outbox"
+	"github.com/jtsang4/go-stater/pkg/search"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 // ProviderSet 是所有provider的集合
 var ProviderSet = wire.NewSet(
+	ProvideRedisClient,
+	ProvideCache,
+	ProvideLocker,
+	ProvideTxManager,
+	ProvideOutbox,
+	ProvideKeyring,
 	ProvideUserRepository,
 	ProvideUserService,
 	ProvideUserHandler,
+	ProvideSearchIndex,
+	ProvideUserSearchService,
+	ProvideUserSearchHandler,
+	ProvidePreferenceRepository,
+	ProvidePreferenceService,
+	ProvidePreferenceHandler,
+	ProvideCacheHandler,
 )
 
-func ProvideUserRepository(db *gorm.DB) *repository.UserRepository {
+func ProvideRedisClient(cfg *config.Config) (redis.UniversalClient, *cache.CircuitBreaker, error) {
+	return cache.NewRedisClient(cfg.Redis)
+}
+
+func ProvideCache(cfg *config.Config, client redis.UniversalClient) (cache.RedisCacheInterface, error) {
+	return cache.NewCache(cfg.Cache, client)
+}
+
+func ProvideLocker(cfg *config.Config, client redis.UniversalClient) *cache.Locker {
+	return cache.NewLocker(cfg.Cache, client)
+}
+
+func ProvideTxManager(db *gorm.DB) *database.TxManager {
+	return database.NewTxManager(db)
+}
+
+func ProvideOutbox(db *gorm.DB) *outbox.Outbox {
+	return outbox.NewOutbox(db)
+}
+
+// ProvideKeyring 创建 Keyring 并设为默认，加密字段的序列化器使用默认 Keyring
+func ProvideKeyring(cfg *config.Config) (*encryption.Keyring, error) {
+	return encryption.InitKeyring(cfg)
+}
+
+// ProvideUserRepository 依赖 Keyring，保证读写用户之前已经设置了默认 Keyring
+func ProvideUserRepository(db *gorm.DB, _ *encryption.Keyring) *repository.UserRepository {
 	return repository.NewUserRepository(db)
 }
 
-func ProvideUserService(repo *repository.UserRepository, cache *cache.RedisCache) *service.UserService {
-	return service.NewUserService(repo, cache)
+func ProvideUserService(repo *repository.UserRepository, tx *database.TxManager, events *outbox.Outbox, cache cache.RedisCacheInterface, locker *cache.Locker, searchService *service.UserSearchService, cfg *config.Config) *service.UserService {
+	s := service.NewUserService(repo, tx, events, cache, locker, cfg.User)
+	s.AddHook(searchService)
+	return s
 }
 
 func ProvideUserHandler(s *service.UserService, cfg *config.Config) *api.UserHandler {
 	return api.NewUserHandler(s, cfg)
 }
 
+func ProvideSearchIndex(cfg *config.Config, db *gorm.DB) (search.IndexInterface, error) {
+	return service.NewUserSearchIndex(cfg.Search, db)
+}
+
+func ProvideUserSearchService(repo *repository.UserRepository, index search.IndexInterface) *service.UserSearchService {
+	return service.NewUserSearchService(repo, index)
+}
+
+func ProvideUserSearchHandler(s *service.UserSearchService) *api.UserSearchHandler {
+	return api.NewUserSearchHandler(s)
+}
+
+func ProvidePreferenceRepository(db *gorm.DB) *repository.PreferenceRepository {
+	return repository.NewPreferenceRepository(db)
+}
+
+func ProvidePreferenceService(repo *repository.PreferenceRepository, tx *database.TxManager, cache cache.RedisCacheInterface, cfg *config.Config) (*service.PreferenceService, error) {
+	return service.NewPreferenceService(repo, tx, cache, cfg.Preferences)
+}
+
+func ProvidePreferenceHandler(s *service.PreferenceService) *api.PreferenceHandler {
+	return api.NewPreferenceHandler(s)
+}
+
+func ProvideCacheHandler(c cache.RedisCacheInterface) *api.CacheHandler {
+	return api.NewCacheHandler(c, cache.DefaultMetrics)
+}
+
 // InitializeAPI 初始化API服务
 func InitializeAPI(cfg *config.Config) (*api.UserHandler, error) {
 	wire.Build(
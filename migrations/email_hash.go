@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/pkg/encryption"
+	"github.com/jtsang4/go-stater/pkg/migrate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 20261018120000_encrypt_user_email 之后已有用户的邮箱仍为明文且 email_hash 为 NULL，
+// 唯一索引和按邮箱查询都会漏掉这些用户，因此在同一次部署中加密并计算盲索引。
+// 需要在执行迁移前调用 encryption.SetDefault
+func init() {
+	goMigrations = append(goMigrations, migrate.Migration{
+		Version: 20261018120001,
+		Name:    "backfill_user_email_hash",
+		Up: func(tx *gorm.DB) error {
+			_, err := encryption.Rotate[model.User](tx.Statement.Context, tx, encryption.RotateOptions{
+				Columns: []string{"email_hash"},
+			})
+			return err
+		},
+		Down: decryptUserEmails,
+	})
+}
+
+// decryptUserEmails 将邮箱解密为明文，回滚 encrypt_user_email 之前执行
+func decryptUserEmails(tx *gorm.DB) error {
+	k, err := encryption.Default()
+	if err != nil {
+		return err
+	}
+	var last uint
+	for {
+		var rows []struct {
+			ID    uint
+			Email string
+		}
+		// 按表名读写，不经过序列化器
+		err := tx.Table("users").Select("id", "email").Where("id > ?", last).Order("id").Limit(500).Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		last = rows[len(rows)-1].ID
+		for _, row := range rows {
+			if !encryption.IsEncrypted(row.Email) {
+				continue
+			}
+			email, err := k.Decrypt(row.Email, "email")
+			if err != nil {
+				return err
+			}
+			err = tx.Table("users").Where(clause.Eq{Column: clause.Column{Name: "id"}, Value: row.ID}).
+				Updates(map[string]interface{}{"email": email, "email_hash": nil}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package migrations 包含应用的数据库迁移。
+// SQL 迁移按驱动存放在 mysql、postgres 和 sqlite 目录下，使用 go run ./cmd/migrate create <name> 生成
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/jtsang4/go-stater/pkg/migrate"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// goMigrations 是用 Go 实现的迁移，适用于需要在代码中回填或转换数据的场景，
+// 与 SQL 迁移一起按版本号排序执行，版本号不能与 SQL 迁移重复
+var goMigrations []migrate.Migration
+
+// Load 返回 driver 对应的全部迁移
+func Load(driver string) ([]migrate.Migration, error) {
+	sub, err := fs.Sub(files, database.NormalizeDriver(driver))
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := migrate.LoadSQL(sub)
+	if err != nil {
+		return nil, fmt.Errorf("load %s migrations: %w", driver, err)
+	}
+	return append(migrations, goMigrations...), nil
+}
+
+// NewMigrator 创建 cfg.Driver 对应的 Migrator
+func NewMigrator(db *gorm.DB, cfg config.DatabaseConfig) (*migrate.Migrator, error) {
+	migrations, err := Load(cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewMigrator(db, migrations)
+}
+
+// Run 按 cfg.Migrate 在启动时处理迁移：auto 执行未执行的迁移，
+// check 在数据库落后时返回 migrate.ErrSchemaBehind，off 不做任何处理
+func Run(ctx context.Context, db *gorm.DB, cfg config.DatabaseConfig) error {
+	switch cfg.Migrate {
+	case migrate.ModeOff:
+		return nil
+	case "", migrate.ModeAuto, migrate.ModeCheck:
+	default:
+		return fmt.Errorf("unknown migrate mode %q", cfg.Migrate)
+	}
+
+	m, err := NewMigrator(db, cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Migrate == migrate.ModeCheck {
+		return m.Check(ctx)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		return err
+	}
+	if len(applied) > 0 {
+		logger.Logger.Info("Database migrated", zap.Int("applied", len(applied)))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package migrations
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/database/dbtest"
+	"github.com/jtsang4/go-stater/pkg/encryption"
+	"github.com/jtsang4/go-stater/pkg/migrate"
+	"github.com/jtsang4/go-stater/pkg/outbox"
+	"github.com/jtsang4/go-stater/pkg/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// model.User 的邮箱加密存储
+	encryption.SetDefault(encryption.NewTestKeyring())
+	os.Exit(m.Run())
+}
+
+func TestLoadAllDrivers(t *testing.T) {
+	var versions []int64
+	for _, driver := range []string{database.DriverMySQL, database.DriverPostgres, database.DriverSQLite} {
+		migrations, err := Load(driver)
+		require.NoError(t, err, driver)
+		require.NotEmpty(t, migrations, driver)
+
+		var driverVersions []int64
+		for _, m := range migrations {
+			assert.NotNil(t, m.Down, "%s %d_%s has no down migration", driver, m.Version, m.Name)
+			driverVersions = append(driverVersions, m.Version)
+		}
+		if versions == nil {
+			versions = driverVersions
+		}
+		assert.Equal(t, versions, driverVersions, "%s migrations are out of sync with mysql", driver)
+	}
+
+	_, err := Load("oracle")
+	assert.Error(t, err)
+}
+
+// TestSchemaMatchesModels 确保迁移创建的表包含模型中的全部字段和索引
+func TestSchemaMatchesModels(t *testing.T) {
+	ctx := context.Background()
+	cfg := dbtest.Config(t)
+	db := dbtest.Open(t, cfg)
+
+	require.NoError(t, Run(ctx, db, cfg))
+	cfg.Migrate = migrate.ModeCheck
+	require.NoError(t, Run(ctx, db, cfg))
+
+	for _, m := range []interface{}{&model.User{}, &model.UsernameHistory{}, &model.UserPreference{}, &outbox.Event{}} {
+		stmt := db.Model(m).Statement
+		require.NoError(t, stmt.Parse(m))
+		s := stmt.Schema
+		require.True(t, db.Migrator().HasTable(s.Table), s.Table)
+		for _, field := range s.Fields {
+			if field.DBName != "" && !field.IgnoreMigration {
+				assert.True(t, db.Migrator().HasColumn(m, field.DBName), "%s.%s", s.Table, field.DBName)
+			}
+		}
+		for _, idx := range s.ParseIndexes() {
+			assert.True(t, db.Migrator().HasIndex(m, idx.Name), "%s index %s", s.Table, idx.Name)
+		}
+	}
+
+	m, err := NewMigrator(db, cfg)
+	require.NoError(t, err)
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	_, err = m.Down(ctx, len(statuses))
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable(&model.User{}))
+}
+
+// TestSQLiteUserSearchIndex 确保触发器将 users 的写入同步到 users_fts
+func TestSQLiteUserSearchIndex(t *testing.T) {
+	ctx := context.Background()
+	cfg := dbtest.Config(t)
+	db := dbtest.Open(t, cfg)
+	require.NoError(t, Run(ctx, db, cfg))
+
+	// 邮箱加密存储，只索引用户名
+	index, err := search.NewDBIndex[model.User](db, search.Field{Name: "username"})
+	require.NoError(t, err)
+	find := func(q string) []uint {
+		result, err := index.Search(ctx, search.Query{Text: q})
+		require.NoError(t, err)
+		ids := make([]uint, len(result.Hits))
+		for i, hit := range result.Hits {
+			ids[i] = hit.ID
+		}
+		return ids
+	}
+
+	users := []*model.User{
+		{Username: "alice", Email: "alice@example.com", Password: "x"},
+		{Username: "bob", Email: "bob@example.com", Password: "x"},
+	}
+	require.NoError(t, db.Create(&users).Error)
+	assert.Equal(t, []uint{users[0].ID}, find("alcie"))
+
+	assert.Empty(t, find("example"))
+
+	require.NoError(t, db.Model(users[1]).Update("username", "robert").Error)
+	assert.Equal(t, []uint{users[1].ID}, find("robrt"))
+
+	require.NoError(t, db.Unscoped().Delete(users[0]).Error)
+	assert.Empty(t, find("alice"))
+}
+
+// TestBackfillUserEmailHash 确保已有用户的邮箱在迁移时加密，回滚时解密
+func TestBackfillUserEmailHash(t *testing.T) {
+	ctx := context.Background()
+	cfg := dbtest.Config(t)
+	db := dbtest.Open(t, cfg)
+	m, err := NewMigrator(db, cfg)
+	require.NoError(t, err)
+	require.NoError(t, Run(ctx, db, cfg))
+
+	// 回滚到加密之前，写入明文邮箱
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	steps := 0
+	for _, status := range statuses {
+		if status.Version >= 20261018120000 {
+			steps++
+		}
+	}
+	_, err = m.Down(ctx, steps)
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("INSERT INTO users (username, password, email, role, created_at, updated_at) VALUES ('alice', 'x', 'Alice@Example.com', 'user', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)").Error)
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	var raw struct {
+		Email     string
+		EmailHash *string
+	}
+	require.NoError(t, db.Table("users").Select("email", "email_hash").Take(&raw).Error)
+	assert.True(t, encryption.IsEncrypted(raw.Email))
+	hash, err := model.HashEmail("alice@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, raw.EmailHash)
+	assert.Equal(t, hash, *raw.EmailHash)
+
+	var user model.User
+	require.NoError(t, db.Take(&user).Error)
+	assert.Equal(t, "Alice@Example.com", user.Email)
+
+	_, err = m.Down(ctx, steps)
+	require.NoError(t, err)
+	require.NoError(t, db.Table("users").Select("email").Take(&raw).Error)
+	assert.Equal(t, "Alice@Example.com", raw.Email)
+}
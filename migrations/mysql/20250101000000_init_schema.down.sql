@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `user_preferences`;
+DROP TABLE IF EXISTS `username_histories`;
+DROP TABLE IF EXISTS `users`;
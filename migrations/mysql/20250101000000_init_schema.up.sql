@@ -0,0 +1,36 @@
+-- 基线结构，与此前 AutoMigrate 创建的表一致；已有的表会被跳过
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `username` varchar(32) NOT NULL,
+  `password` varchar(128) NOT NULL,
+  `email` varchar(128) NOT NULL,
+  `role` varchar(16) NOT NULL DEFAULT 'user',
+  `username_changed_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_users_username` (`username`),
+  UNIQUE INDEX `idx_users_email` (`email`),
+  INDEX `idx_users_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `username_histories` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `old_username` varchar(32) NOT NULL,
+  `new_username` varchar(32) NOT NULL,
+  `reserved_until` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_username_histories_user_id` (`user_id`),
+  INDEX `idx_username_histories_old_username` (`old_username`)
+);
+
+CREATE TABLE IF NOT EXISTS `user_preferences` (
+  `user_id` bigint unsigned NOT NULL,
+  `data` json,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`user_id`)
+);
@@ -0,0 +1,18 @@
+-- 待投递的领域事件，与业务数据在同一事务中写入
+CREATE TABLE `outbox_events` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `event_id` varchar(36) NOT NULL,
+  `aggregate_type` varchar(64) NOT NULL,
+  `aggregate_id` varchar(64) NOT NULL,
+  `type` varchar(128) NOT NULL,
+  `payload` text NOT NULL,
+  `attempts` bigint NOT NULL DEFAULT 0,
+  `next_attempt_at` datetime(3) NULL,
+  `last_error` varchar(512) NULL,
+  `published_at` datetime(3) NULL,
+  `failed_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_outbox_events_event_id` (`event_id`),
+  INDEX `idx_outbox_events_published_at` (`published_at`)
+);
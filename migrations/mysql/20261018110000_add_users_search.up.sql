@@ -0,0 +1,2 @@
+-- 用户搜索的全文索引，ngram 解析器按二元组切分，支持部分匹配和拼写容错
+ALTER TABLE users ADD FULLTEXT INDEX idx_users_search (username, email) WITH PARSER ngram;
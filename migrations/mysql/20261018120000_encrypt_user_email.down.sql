@@ -0,0 +1,8 @@
+-- 加密后的邮箱超过 128 个字符，回滚时保留列宽，邮箱仍为密文
+ALTER TABLE users DROP INDEX idx_users_search;
+ALTER TABLE users ADD FULLTEXT INDEX idx_users_search (username, email) WITH PARSER ngram;
+
+ALTER TABLE users
+  DROP INDEX `idx_users_email_hash`,
+  DROP COLUMN `email_hash`,
+  ADD UNIQUE INDEX `idx_users_email` (`email`);
@@ -0,0 +1,12 @@
+-- 邮箱加密存储：加密后的值更长，唯一约束和精确查询改用盲索引 email_hash。
+-- 已有的邮箱仍为明文，由随后的 Go 迁移 backfill_user_email_hash（migrations/email_hash.go）加密并计算盲索引
+ALTER TABLE users
+  MODIFY `email` varchar(512) NOT NULL,
+  ADD COLUMN `email_hash` varchar(64) NULL AFTER `email`,
+  DROP INDEX `idx_users_email`,
+  ADD UNIQUE INDEX `idx_users_email_hash` (`email_hash`);
+
+-- 密文不能做全文搜索，搜索索引只保留用户名：邮箱只能按完整值通过盲索引查找，
+-- 部分匹配和容错搜索邮箱需要使用进程内索引（search.backend: memory）
+ALTER TABLE users DROP INDEX idx_users_search;
+ALTER TABLE users ADD FULLTEXT INDEX idx_users_search (username) WITH PARSER ngram;
@@ -0,0 +1,33 @@
+-- 基线结构，与此前 AutoMigrate 创建的表一致；已有的表会被跳过
+CREATE TABLE IF NOT EXISTS users (
+  id bigserial PRIMARY KEY,
+  username varchar(32) NOT NULL,
+  password varchar(128) NOT NULL,
+  email varchar(128) NOT NULL,
+  role varchar(16) NOT NULL DEFAULT 'user',
+  username_changed_at timestamptz,
+  created_at timestamptz,
+  updated_at timestamptz,
+  deleted_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS username_histories (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL,
+  old_username varchar(32) NOT NULL,
+  new_username varchar(32) NOT NULL,
+  reserved_until timestamptz,
+  created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_username_histories_user_id ON username_histories (user_id);
+CREATE INDEX IF NOT EXISTS idx_username_histories_old_username ON username_histories (old_username);
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+  user_id bigint PRIMARY KEY,
+  data json,
+  created_at timestamptz,
+  updated_at timestamptz
+);
@@ -0,0 +1,17 @@
+-- 待投递的领域事件，与业务数据在同一事务中写入
+CREATE TABLE outbox_events (
+  id bigserial PRIMARY KEY,
+  event_id varchar(36) NOT NULL,
+  aggregate_type varchar(64) NOT NULL,
+  aggregate_id varchar(64) NOT NULL,
+  type varchar(128) NOT NULL,
+  payload text NOT NULL,
+  attempts bigint NOT NULL DEFAULT 0,
+  next_attempt_at timestamptz,
+  last_error varchar(512),
+  published_at timestamptz,
+  failed_at timestamptz,
+  created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_outbox_events_event_id ON outbox_events (event_id);
+CREATE INDEX idx_outbox_events_published_at ON outbox_events (published_at);
@@ -0,0 +1,4 @@
+-- 保留 pg_trgm 扩展，其他对象可能依赖它
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_username_trgm;
+DROP INDEX IF EXISTS idx_users_search;
@@ -0,0 +1,6 @@
+-- 用户搜索：tsvector 前缀匹配和 pg_trgm 模糊匹配。创建扩展需要数据库的 CREATE 权限
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+-- 表达式必须与 search.DBIndex 查询中的一致才会使用索引
+CREATE INDEX idx_users_search ON users USING gin (to_tsvector('simple', username || ' ' || email));
+CREATE INDEX idx_users_username_trgm ON users USING gin (username gin_trgm_ops);
+CREATE INDEX idx_users_email_trgm ON users USING gin (email gin_trgm_ops);
@@ -0,0 +1,8 @@
+-- 加密后的邮箱超过 128 个字符，回滚时保留列宽，邮箱仍为密文
+DROP INDEX IF EXISTS idx_users_search;
+CREATE INDEX idx_users_search ON users USING gin (to_tsvector('simple', username || ' ' || email));
+CREATE INDEX idx_users_email_trgm ON users USING gin (email gin_trgm_ops);
+
+DROP INDEX IF EXISTS idx_users_email_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS email_hash;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,33 @@
+-- 基线结构，与此前 AutoMigrate 创建的表一致；已有的表会被跳过
+CREATE TABLE IF NOT EXISTS users (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  username text NOT NULL,
+  password text NOT NULL,
+  email text NOT NULL,
+  role text NOT NULL DEFAULT 'user',
+  username_changed_at datetime,
+  created_at datetime,
+  updated_at datetime,
+  deleted_at datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS username_histories (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  user_id integer NOT NULL,
+  old_username text NOT NULL,
+  new_username text NOT NULL,
+  reserved_until datetime,
+  created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_username_histories_user_id ON username_histories (user_id);
+CREATE INDEX IF NOT EXISTS idx_username_histories_old_username ON username_histories (old_username);
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+  user_id integer PRIMARY KEY,
+  data json,
+  created_at datetime,
+  updated_at datetime
+);
@@ -0,0 +1,2 @@
+-- 乐观锁版本号，每次更新加 1
+ALTER TABLE users ADD COLUMN version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,17 @@
+-- 待投递的领域事件，与业务数据在同一事务中写入
+CREATE TABLE outbox_events (
+  id integer PRIMARY KEY AUTOINCREMENT,
+  event_id text NOT NULL,
+  aggregate_type text NOT NULL,
+  aggregate_id text NOT NULL,
+  type text NOT NULL,
+  payload text NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt_at datetime,
+  last_error text,
+  published_at datetime,
+  failed_at datetime,
+  created_at datetime
+);
+CREATE UNIQUE INDEX idx_outbox_events_event_id ON outbox_events (event_id);
+CREATE INDEX idx_outbox_events_published_at ON outbox_events (published_at);
@@ -0,0 +1,17 @@
+-- 用户搜索的 FTS5 索引，内容来自 users 表，由触发器同步。trigram 分词支持子串匹配
+CREATE VIRTUAL TABLE users_fts USING fts5(username, email, content='users', content_rowid='id', tokenize='trigram');
+
+CREATE TRIGGER users_fts_ai AFTER INSERT ON users BEGIN
+    INSERT INTO users_fts(rowid, username, email) VALUES (new.id, new.username, new.email);
+END;
+
+CREATE TRIGGER users_fts_ad AFTER DELETE ON users BEGIN
+    INSERT INTO users_fts(users_fts, rowid, username, email) VALUES ('delete', old.id, old.username, old.email);
+END;
+
+CREATE TRIGGER users_fts_au AFTER UPDATE OF username, email ON users BEGIN
+    INSERT INTO users_fts(users_fts, rowid, username, email) VALUES ('delete', old.id, old.username, old.email);
+    INSERT INTO users_fts(rowid, username, email) VALUES (new.id, new.username, new.email);
+END;
+
+INSERT INTO users_fts(users_fts) VALUES ('rebuild');
@@ -0,0 +1,25 @@
+DROP TRIGGER IF EXISTS users_fts_au;
+DROP TRIGGER IF EXISTS users_fts_ad;
+DROP TRIGGER IF EXISTS users_fts_ai;
+DROP TABLE IF EXISTS users_fts;
+
+CREATE VIRTUAL TABLE users_fts USING fts5(username, email, content='users', content_rowid='id', tokenize='trigram');
+
+CREATE TRIGGER users_fts_ai AFTER INSERT ON users BEGIN
+    INSERT INTO users_fts(rowid, username, email) VALUES (new.id, new.username, new.email);
+END;
+
+CREATE TRIGGER users_fts_ad AFTER DELETE ON users BEGIN
+    INSERT INTO users_fts(users_fts, rowid, username, email) VALUES ('delete', old.id, old.username, old.email);
+END;
+
+CREATE TRIGGER users_fts_au AFTER UPDATE OF username, email ON users BEGIN
+    INSERT INTO users_fts(users_fts, rowid, username, email) VALUES ('delete', old.id, old.username, old.email);
+    INSERT INTO users_fts(rowid, username, email) VALUES (new.id, new.username, new.email);
+END;
+
+INSERT INTO users_fts(users_fts) VALUES ('rebuild');
+
+DROP INDEX IF EXISTS idx_users_email_hash;
+ALTER TABLE users DROP COLUMN email_hash;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
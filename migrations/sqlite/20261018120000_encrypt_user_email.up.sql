@@ -0,0 +1,29 @@
+-- 邮箱加密存储，唯一约束和精确查询改用盲索引 email_hash。
+-- 已有的邮箱仍为明文，由随后的 Go 迁移 backfill_user_email_hash（migrations/email_hash.go）加密并计算盲索引
+ALTER TABLE users ADD COLUMN email_hash text;
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX idx_users_email_hash ON users (email_hash);
+
+-- 密文不能做全文搜索，搜索索引只保留用户名：邮箱只能按完整值通过盲索引查找，
+-- 部分匹配和容错搜索邮箱需要使用进程内索引（search.backend: memory）
+DROP TRIGGER IF EXISTS users_fts_au;
+DROP TRIGGER IF EXISTS users_fts_ad;
+DROP TRIGGER IF EXISTS users_fts_ai;
+DROP TABLE IF EXISTS users_fts;
+
+CREATE VIRTUAL TABLE users_fts USING fts5(username, content='users', content_rowid='id', tokenize='trigram');
+
+CREATE TRIGGER users_fts_ai AFTER INSERT ON users BEGIN
+    INSERT INTO users_fts(rowid, username) VALUES (new.id, new.username);
+END;
+
+CREATE TRIGGER users_fts_ad AFTER DELETE ON users BEGIN
+    INSERT INTO users_fts(users_fts, rowid, username) VALUES ('delete', old.id, old.username);
+END;
+
+CREATE TRIGGER users_fts_au AFTER UPDATE OF username ON users BEGIN
+    INSERT INTO users_fts(users_fts, rowid, username) VALUES ('delete', old.id, old.username);
+    INSERT INTO users_fts(rowid, username) VALUES (new.id, new.username);
+END;
+
+INSERT INTO users_fts(users_fts) VALUES ('rebuild');
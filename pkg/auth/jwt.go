@@ -9,13 +9,15 @@ import (
 )
 
 type Claims struct {
-	UserID uint `json:"user_id"`
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, cfg config.JWTConfig) (string, error) {
+func GenerateToken(userID uint, role string, cfg config.JWTConfig) (string, error) {
 	claims := Claims{
 		UserID: userID,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * cfg.ExpireTime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
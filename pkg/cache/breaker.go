@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// ErrCircuitOpen 表示 Redis 被判定为不可用，请求未发送直接失败
+var ErrCircuitOpen = errors.New("redis circuit breaker is open")
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 10 * time.Second
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// CircuitBreaker 在连续失败 threshold 次后熔断，熔断期间所有请求直接返回 ErrCircuitOpen；
+// openTimeout 之后放行一个探测请求，成功则恢复，失败则继续熔断
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	state       string
+	failures    int
+	openedAt    time.Time
+	probing     bool
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+}
+
+// NewCircuitBreaker 创建熔断器，参数 <= 0 时使用默认值（5 次、10s）
+func NewCircuitBreaker(threshold int, openTimeout time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	if openTimeout <= 0 {
+		openTimeout = defaultOpenTimeout
+	}
+	return &CircuitBreaker{
+		state:       BreakerClosed,
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+	}
+}
+
+// State 返回当前状态：closed、open 或 half-open
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow 判断是否放行请求，半开状态下同一时间只放行一个探测请求
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setStateLocked(BreakerHalfOpen)
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success 记录一次成功的请求
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	b.setStateLocked(BreakerClosed)
+}
+
+// Failure 记录一次失败的请求
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.setStateLocked(BreakerOpen)
+	}
+}
+
+// Trip 立即熔断，例如启动时无法连接 Redis
+func (b *CircuitBreaker) Trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.openedAt = b.now()
+	b.probing = false
+	b.setStateLocked(BreakerOpen)
+}
+
+// release 结束探测请求但不改变状态，用于调用方主动取消的请求
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) setStateLocked(state string) {
+	if b.state == state {
+		return
+	}
+	logger.Logger.Warn("redis circuit breaker state changed", zap.String("from", b.state), zap.String("to", state))
+	b.state = state
+}
+
+// record 根据请求结果更新熔断器：key 不存在和 Redis 返回的错误回复说明连接正常，
+// 调用方取消的请求不计入结果，其余错误（超时、连接失败等）计为失败
+func (b *CircuitBreaker) record(err error) {
+	var redisErr redis.Error
+	switch {
+	case err == nil, errors.Is(err, redis.Nil), errors.As(err, &redisErr) && !errors.Is(err, redis.ErrClosed):
+		b.Success()
+	case errors.Is(err, context.Canceled):
+		b.release()
+	default:
+		b.Failure()
+	}
+}
+
+// breakerHook 将熔断器接入 go-redis，覆盖缓存、锁和失效广播等所有命令
+type breakerHook struct {
+	breaker *CircuitBreaker
+}
+
+func (h breakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h breakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !h.breaker.Allow() {
+			cmd.SetErr(ErrCircuitOpen)
+			return ErrCircuitOpen
+		}
+		err := next(ctx, cmd)
+		h.breaker.record(err)
+		return err
+	}
+}
+
+func (h breakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !h.breaker.Allow() {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrCircuitOpen)
+			}
+			return ErrCircuitOpen
+		}
+		err := next(ctx, cmds)
+		h.breaker.record(err)
+		return err
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jtsang4/go-stater/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := NewCircuitBreaker(2, time.Second)
+	b.now = clock.Now
+
+	b.Failure()
+	assert.Equal(t, BreakerClosed, b.State())
+	b.Success()
+	b.Failure()
+	assert.Equal(t, BreakerClosed, b.State(), "success resets the failure count")
+	b.Failure()
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.False(t, b.Allow())
+
+	// 超时后只放行一个探测请求，探测失败则继续熔断
+	clock.Advance(time.Second)
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	b.Failure()
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.False(t, b.Allow())
+
+	clock.Advance(time.Second)
+	assert.True(t, b.Allow())
+	b.Success()
+	assert.Equal(t, BreakerClosed, b.State())
+	assert.True(t, b.Allow())
+}
+
+func TestRedisClientBypassesUnhealthyRedis(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	client, breaker, err := NewRedisClient(config.RedisConfig{
+		Addr:           s.Addr(),
+		DialTimeout:    100 * time.Millisecond,
+		CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	clock := &fakeClock{now: time.Now()}
+	breaker.now = clock.Now
+	c := NewRedisCacheWithClient(client)
+
+	require.NoError(t, Ping(ctx, client, breaker))
+	var v string
+	assert.ErrorIs(t, c.Get(ctx, "missing", &v), ErrMiss, "a miss is not a failure")
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	s.Close()
+	for i := 0; i < 2; i++ {
+		assert.Error(t, c.Get(ctx, "k", &v))
+	}
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	// 熔断期间不再访问 Redis
+	start := time.Now()
+	assert.ErrorIs(t, c.Get(ctx, "k", &v), ErrCircuitOpen)
+	assert.ErrorIs(t, c.Set(ctx, "k", "v", 0), ErrCircuitOpen)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	require.NoError(t, s.Restart())
+	clock.Advance(time.Minute)
+	require.NoError(t, c.Set(ctx, "k", "v", 0))
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestNewRedisClientValidatesConfig(t *testing.T) {
+	_, _, err := NewRedisClient(config.RedisConfig{Mode: "sentinel"})
+	assert.Error(t, err)
+	_, _, err = NewRedisClient(config.RedisConfig{Mode: "cluster", Addrs: []string{"localhost:7000"}, DB: 1})
+	assert.Error(t, err)
+	_, _, err = NewRedisClient(config.RedisConfig{TLS: config.RedisTLSConfig{Enabled: true, CAFile: "missing.pem"}})
+	assert.Error(t, err)
+	_, _, err = NewRedisClient(config.RedisConfig{Mode: "proxy"})
+	assert.Error(t, err)
+}
+
+func TestClusterDeleteByPrefix(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	client, _, err := NewRedisClient(config.RedisConfig{Mode: RedisModeCluster, Addrs: []string{s.Addr()}})
+	require.NoError(t, err)
+	defer client.Close()
+	c := NewRedisCacheWithClient(client)
+
+	for i := 0; i < 50; i++ {
+		require.NoError(t, c.Set(ctx, fmt.Sprintf("app:v1:user:%d", i), i, 0))
+	}
+	require.NoError(t, c.Set(ctx, "app:v2:user:1", 1, 0))
+
+	n, err := c.DeleteByPrefix(ctx, "app:v1:")
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), n)
+	assert.True(t, s.Exists("app:v2:user:1"))
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DriverMemory = "memory"
+	DriverRedis  = "redis"
+	DriverTiered = "tiered"
+)
+
+const defaultL1TTL = time.Minute
+
+// ErrMiss 表示 key 不存在或已过期
+var ErrMiss = errors.New("cache miss")
+
+// NewCache 根据配置创建缓存：memory 仅使用进程内缓存，redis 仅使用 Redis，
+// tiered 在 Redis 前加一层进程内缓存，并通过 Redis pub/sub 在实例之间同步失效。
+// 配置了 KeyPrefix 时所有 key 都会加上该前缀。访问统计记录在 DefaultMetrics 中。
+// client 由调用方创建（见 NewRedisClient）并负责关闭，memory 模式下不会使用
+func NewCache(cfg config.CacheConfig, client redis.UniversalClient) (RedisCacheInterface, error) {
+	c, err := newBackend(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.KeyPrefix != "" {
+		c = NewPrefixedCache(c, cfg.KeyPrefix)
+	}
+	return NewInstrumentedCache(c, DefaultMetrics), nil
+}
+
+// NewLocker 根据配置创建分布式锁：memory 模式下使用进程内锁，其余模式使用 Redis
+func NewLocker(cfg config.CacheConfig, client redis.UniversalClient) *Locker {
+	if cfg.Driver == DriverMemory {
+		return NewMemoryLocker()
+	}
+	return NewRedisLocker(client, cfg.KeyPrefix)
+}
+
+func newBackend(cfg config.CacheConfig, client redis.UniversalClient) (RedisCacheInterface, error) {
+	serializer, err := NewSerializer(cfg.Codec, cfg.Compression, cfg.CompressionThreshold)
+	if err != nil {
+		return nil, err
+	}
+	newRedis := func() *RedisCache {
+		c := NewRedisCacheWithClient(client)
+		c.SetSerializer(serializer)
+		return c
+	}
+	newMemory := func() (*MemoryCache, error) {
+		c, err := NewMemoryCache(cfg.Memory.MaxEntries, cfg.Memory.Eviction)
+		if err != nil {
+			return nil, err
+		}
+		c.SetSerializer(serializer.withoutCompression())
+		c.OnEvict(func(key string) {
+			DefaultMetrics.Evict(strings.TrimPrefix(key, cfg.KeyPrefix))
+		})
+		return c, nil
+	}
+
+	switch cfg.Driver {
+	case "", DriverRedis:
+		return newRedis(), nil
+	case DriverMemory:
+		return newMemory()
+	case DriverTiered:
+		l1, err := newMemory()
+		if err != nil {
+			return nil, err
+		}
+		l1TTL := cfg.L1TTL
+		if l1TTL <= 0 {
+			l1TTL = defaultL1TTL
+		}
+		l2 := newRedis()
+		inv := NewInvalidator(l2.Client(), cfg.InvalidationChannel)
+		tiered := NewTieredCache(l1, l2, l1TTL, inv)
+		tiered.StartInvalidation(inv)
+		return tiered, nil
+	default:
+		return nil, fmt.Errorf("unknown cache driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	CodecJSON    = "json"
+	CodecMsgpack = "msgpack"
+	CodecGob     = "gob"
+
+	CompressionNone   = "none"
+	CompressionZstd   = "zstd"
+	CompressionSnappy = "snappy"
+)
+
+const defaultCompressionThreshold = 1024
+
+// 缓存值的第一个字节是格式头：最高位固定为 1，4~6 位为压缩算法，低 4 位为编码格式。
+// 合法的 JSON 文本不会以 >= 0x80 的字节开头，因此没有格式头的值按旧版 JSON 解析，
+// 切换编码格式后新旧数据可以共存，无需清空 Redis
+const (
+	headerFlag     = 0x80
+	codecMask      = 0x0f
+	compressShift  = 4
+	compressMask   = 0x07
+	compressorNone = 0
+)
+
+// Codec 负责值的序列化
+type Codec interface {
+	ID() byte
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Compressor 负责序列化结果的压缩
+type Compressor interface {
+	ID() byte
+	Name() string
+	Compress(src []byte) []byte
+	Decompress(src []byte) ([]byte, error)
+}
+
+var codecs = map[byte]Codec{}
+
+var compressors = map[byte]Compressor{}
+
+func init() {
+	for _, c := range []Codec{jsonCodec{}, msgpackCodec{}, gobCodec{}} {
+		codecs[c.ID()] = c
+	}
+	for _, c := range []Compressor{newZstdCompressor(), snappyCompressor{}} {
+		compressors[c.ID()] = c
+	}
+
+	// 偏好设置等动态结构以 interface{} 保存，gob 需要预先注册其具体类型
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// Serializer 使用指定的编码格式写入值，序列化结果超过 threshold 字节时压缩；
+// 读取时根据格式头选择解码方式，与写入时的配置无关
+type Serializer struct {
+	codec      Codec
+	compressor Compressor
+	threshold  int
+}
+
+var defaultSerializer = &Serializer{codec: jsonCodec{}}
+
+// NewSerializer 根据名称创建 Serializer，threshold <= 0 时使用默认阈值 1KB
+func NewSerializer(codec, compression string, threshold int) (*Serializer, error) {
+	s := &Serializer{threshold: threshold}
+	if s.threshold <= 0 {
+		s.threshold = defaultCompressionThreshold
+	}
+
+	switch codec {
+	case "", CodecJSON:
+		s.codec = jsonCodec{}
+	case CodecMsgpack:
+		s.codec = msgpackCodec{}
+	case CodecGob:
+		s.codec = gobCodec{}
+	default:
+		return nil, fmt.Errorf("unknown cache codec %q", codec)
+	}
+
+	switch compression {
+	case "", CompressionNone:
+	case CompressionZstd:
+		s.compressor = compressors[zstdID]
+	case CompressionSnappy:
+		s.compressor = compressors[snappyID]
+	default:
+		return nil, fmt.Errorf("unknown cache compression %q", compression)
+	}
+
+	return s, nil
+}
+
+// withoutCompression 返回使用相同编码格式但不压缩的 Serializer，用于进程内缓存
+func (s *Serializer) withoutCompression() *Serializer {
+	return &Serializer{codec: s.codec}
+}
+
+func (s *Serializer) Marshal(v interface{}) ([]byte, error) {
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressor byte = compressorNone
+	if s.compressor != nil && len(data) > s.threshold {
+		data = s.compressor.Compress(data)
+		compressor = s.compressor.ID()
+	}
+
+	out := make([]byte, 0, len(data)+1)
+	out = append(out, headerFlag|compressor<<compressShift|s.codec.ID())
+	return append(out, data...), nil
+}
+
+// Unmarshal 按格式头解码 data，可以读取任意 Serializer 写入的值
+func (s *Serializer) Unmarshal(data []byte, v interface{}) error {
+	return unmarshalValue(data, v)
+}
+
+func unmarshalValue(data []byte, v interface{}) error {
+	if len(data) == 0 || data[0]&headerFlag == 0 {
+		return json.Unmarshal(data, v)
+	}
+
+	header := data[0]
+	codec, ok := codecs[header&codecMask]
+	if !ok {
+		return fmt.Errorf("unknown cache codec id %d", header&codecMask)
+	}
+
+	data = data[1:]
+	if id := header >> compressShift & compressMask; id != compressorNone {
+		compressor, ok := compressors[id]
+		if !ok {
+			return fmt.Errorf("unknown cache compression id %d", id)
+		}
+		var err error
+		if data, err = compressor.Decompress(data); err != nil {
+			return err
+		}
+	}
+
+	return codec.Unmarshal(data, v)
+}
+
+// describeFormat 根据格式头返回编码格式和压缩算法的名称
+func describeFormat(data []byte) (codec, compression string) {
+	if len(data) == 0 || data[0]&headerFlag == 0 {
+		return CodecJSON, CompressionNone
+	}
+
+	codec, compression = "unknown", "unknown"
+	if c, ok := codecs[data[0]&codecMask]; ok {
+		codec = c.Name()
+	}
+	id := data[0] >> compressShift & compressMask
+	if id == compressorNone {
+		compression = CompressionNone
+	} else if c, ok := compressors[id]; ok {
+		compression = c.Name()
+	}
+	return codec, compression
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte { return 1 }
+
+func (jsonCodec) Name() string { return CodecJSON }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// msgpackCodec 使用 json tag 作为字段名，与 JSON 编码时可见的字段保持一致
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte { return 2 }
+
+func (msgpackCodec) Name() string { return CodecMsgpack }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	dec.UseLooseInterfaceDecoding(true)
+	return dec.Decode(v)
+}
+
+// gobCodec 保留 Go 类型信息，但会忽略 json tag 编码所有导出字段。为避免密码哈希等 json:"-" 的字段
+// 被写入缓存（并通过管理接口读出），拒绝编码含有这类字段的类型，需要缓存时请定义不含这些字段的类型
+type gobCodec struct{}
+
+func (gobCodec) ID() byte { return 3 }
+
+func (gobCodec) Name() string { return CodecGob }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	if v != nil {
+		if field := hiddenField(reflect.TypeOf(v)); field != "" {
+			return nil, fmt.Errorf("cache: gob would store %s, which is hidden from JSON (json:\"-\")", field)
+		}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// hiddenFields 缓存每个类型的 hiddenField 结果
+var hiddenFields sync.Map
+
+// hiddenField 返回 t 中（包括嵌套的结构体、指针、切片和 map）第一个带 json:"-" 的导出字段，没有时返回空字符串
+func hiddenField(t reflect.Type) string {
+	if field, ok := hiddenFields.Load(t); ok {
+		return field.(string)
+	}
+	field := findHiddenField(t, make(map[reflect.Type]bool))
+	hiddenFields.Store(t, field)
+	return field
+}
+
+func findHiddenField(t reflect.Type, seen map[reflect.Type]bool) string {
+	if seen[t] {
+		return ""
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return findHiddenField(t.Elem(), seen)
+	case reflect.Map:
+		if field := findHiddenField(t.Key(), seen); field != "" {
+			return field
+		}
+		return findHiddenField(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if f.Tag.Get("json") == "-" {
+				return t.String() + "." + f.Name
+			}
+			if field := findHiddenField(f.Type, seen); field != "" {
+				return field
+			}
+		}
+	}
+	return ""
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+const (
+	zstdID   = 1
+	snappyID = 2
+)
+
+// zstdCompressor 的 encoder/decoder 只使用 EncodeAll/DecodeAll，可以并发调用
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func newZstdCompressor() *zstdCompressor {
+	encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	decoder, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	return &zstdCompressor{encoder: encoder, decoder: decoder}
+}
+
+func (c *zstdCompressor) ID() byte { return zstdID }
+
+func (c *zstdCompressor) Name() string { return CompressionZstd }
+
+func (c *zstdCompressor) Compress(src []byte) []byte {
+	return c.encoder.EncodeAll(src, nil)
+}
+
+func (c *zstdCompressor) Decompress(src []byte) ([]byte, error) {
+	return c.decoder.DecodeAll(src, nil)
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) ID() byte { return snappyID }
+
+func (snappyCompressor) Name() string { return CompressionSnappy }
+
+func (snappyCompressor) Compress(src []byte) []byte {
+	return s2.EncodeSnappy(nil, src)
+}
+
+func (snappyCompressor) Decompress(src []byte) ([]byte, error) {
+	return s2.Decode(nil, src)
+}
+
+// serializerOf 返回缓存写入时使用的 Serializer，未知实现按 JSON 处理
+func serializerOf(c RedisCacheInterface) *Serializer {
+	if p, ok := c.(interface{ Serializer() *Serializer }); ok {
+		return p.Serializer()
+	}
+	return defaultSerializer
+}
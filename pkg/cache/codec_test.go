@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type codecTestValue struct {
+	ID        uint                   `json:"id"`
+	Name      string                 `json:"name"`
+	CreatedAt time.Time              `json:"created_at"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+func TestSerializerRoundTrip(t *testing.T) {
+	value := codecTestValue{
+		ID:        7,
+		Name:      strings.Repeat("alice", 500),
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Data:      map[string]interface{}{"locale": "zh-CN", "push": true},
+	}
+
+	for _, codec := range []string{CodecJSON, CodecMsgpack, CodecGob} {
+		for _, compression := range []string{CompressionNone, CompressionZstd, CompressionSnappy} {
+			t.Run(codec+"/"+compression, func(t *testing.T) {
+				s, err := NewSerializer(codec, compression, 0)
+				require.NoError(t, err)
+
+				data, err := s.Marshal(value)
+				require.NoError(t, err)
+				if compression != CompressionNone {
+					assert.NotZero(t, data[0]>>compressShift&compressMask, "large values should be compressed")
+				}
+
+				var got codecTestValue
+				require.NoError(t, defaultSerializer.Unmarshal(data, &got))
+				assert.Equal(t, value.ID, got.ID)
+				assert.Equal(t, value.Name, got.Name)
+				assert.True(t, value.CreatedAt.Equal(got.CreatedAt))
+				assert.Equal(t, value.Data, got.Data)
+			})
+		}
+	}
+}
+
+func TestSerializerSkipsCompressionBelowThreshold(t *testing.T) {
+	s, err := NewSerializer(CodecJSON, CompressionZstd, 1024)
+	require.NoError(t, err)
+
+	data, err := s.Marshal("small")
+	require.NoError(t, err)
+	assert.Equal(t, byte(headerFlag|1), data[0])
+	assert.Equal(t, `"small"`, string(data[1:]))
+}
+
+func TestSerializerReadsLegacyJSON(t *testing.T) {
+	var got codecTestValue
+	require.NoError(t, defaultSerializer.Unmarshal([]byte(`{"id":1,"name":"bob"}`), &got))
+	assert.Equal(t, uint(1), got.ID)
+	assert.Equal(t, "bob", got.Name)
+
+	var n int64
+	require.NoError(t, defaultSerializer.Unmarshal([]byte("42"), &n))
+	assert.Equal(t, int64(42), n)
+}
+
+// gob 会编码 json:"-" 的字段，因此拒绝含有这类字段的类型
+func TestGobRejectsHiddenFields(t *testing.T) {
+	type account struct {
+		Name     string `json:"name"`
+		Password string `json:"-"`
+	}
+	type wrapper struct {
+		Accounts map[string][]*account
+	}
+
+	s, err := NewSerializer(CodecGob, CompressionNone, 0)
+	require.NoError(t, err)
+	for _, v := range []interface{}{account{}, &account{}, []account{}, wrapper{}} {
+		_, err := s.Marshal(v)
+		assert.ErrorContains(t, err, "account.Password", "%T", v)
+	}
+
+	json, err := NewSerializer(CodecJSON, CompressionNone, 0)
+	require.NoError(t, err)
+	data, err := json.Marshal(account{Name: "bob", Password: "hash"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hash")
+}
+
+func TestNewSerializerRejectsUnknown(t *testing.T) {
+	_, err := NewSerializer("xml", CompressionNone, 0)
+	assert.Error(t, err)
+	_, err = NewSerializer(CodecJSON, "lz4", 0)
+	assert.Error(t, err)
+}
+
+// 切换编码格式后，之前写入的值仍可读取
+func TestRedisCacheCodecMigration(t *testing.T) {
+	s := miniredis.RunT(t)
+	ctx := context.Background()
+	c := NewRedisCache(s.Addr(), "", 0)
+
+	require.NoError(t, s.Set("legacy", `{"id":1,"name":"legacy"}`))
+	require.NoError(t, c.Set(ctx, "json", codecTestValue{ID: 2, Name: "json"}, 0))
+
+	msgpack, err := NewSerializer(CodecMsgpack, CompressionSnappy, 0)
+	require.NoError(t, err)
+	c.SetSerializer(msgpack)
+	require.NoError(t, c.Set(ctx, "msgpack", codecTestValue{ID: 3, Name: "msgpack"}, 0))
+
+	for key, id := range map[string]uint{"legacy": 1, "json": 2, "msgpack": 3} {
+		var got codecTestValue
+		require.NoError(t, c.Get(ctx, key, &got), key)
+		assert.Equal(t, id, got.ID)
+		assert.Equal(t, key, got.Name)
+	}
+}
+
+func TestLoaderUsesCacheCodec(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemoryCache(0, EvictionLRU)
+	require.NoError(t, err)
+	s, err := NewSerializer(CodecGob, CompressionNone, 0)
+	require.NoError(t, err)
+	c.SetSerializer(s)
+	loader := NewLoader(c, LoaderOptions{})
+
+	want := codecTestValue{ID: 1, Name: "bob"}
+	require.NoError(t, loader.Store(ctx, "k", time.Minute, want))
+
+	var got codecTestValue
+	require.NoError(t, loader.GetOrLoad(ctx, "k", time.Minute, &got, func(ctx context.Context) (interface{}, error) {
+		t.Fatal("should be served from cache")
+		return nil, nil
+	}))
+	assert.Equal(t, "bob", got.Name)
+
+	n, err := c.Incr(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// InstrumentedCache 记录每次访问的命中、未命中和错误次数。
+// 只有 ErrMiss 计为未命中，其余错误（例如 Redis 不可用）计为错误
+type InstrumentedCache struct {
+	cache   RedisCacheInterface
+	metrics *Metrics
+}
+
+func NewInstrumentedCache(cache RedisCacheInterface, metrics *Metrics) *InstrumentedCache {
+	return &InstrumentedCache{cache: cache, metrics: metrics}
+}
+
+func (c *InstrumentedCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	err := c.cache.Set(ctx, key, value, expiration)
+	if err != nil {
+		c.metrics.Error(key)
+	}
+	return err
+}
+
+func (c *InstrumentedCache) Get(ctx context.Context, key string, dest interface{}) error {
+	err := c.cache.Get(ctx, key, dest)
+	switch {
+	case err == nil:
+		c.metrics.Hit(key)
+	case errors.Is(err, ErrMiss):
+		c.metrics.Miss(key)
+	default:
+		c.metrics.Error(key)
+	}
+	return err
+}
+
+func (c *InstrumentedCache) Delete(ctx context.Context, key string) error {
+	err := c.cache.Delete(ctx, key)
+	if err != nil {
+		c.metrics.Error(key)
+	}
+	return err
+}
+
+func (c *InstrumentedCache) Incr(ctx context.Context, key string) (int64, error) {
+	n, err := c.cache.Incr(ctx, key)
+	if err != nil {
+		c.metrics.Error(key)
+	}
+	return n, err
+}
+
+func (c *InstrumentedCache) SetCounterNX(ctx context.Context, key string, value int64) (bool, error) {
+	ok, err := c.cache.SetCounterNX(ctx, key, value)
+	if err != nil {
+		c.metrics.Error(key)
+	}
+	return ok, err
+}
+
+func (c *InstrumentedCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	n, err := c.cache.DeleteByPrefix(ctx, prefix)
+	if err != nil {
+		c.metrics.Error(prefix)
+	}
+	return n, err
+}
+
+// Inspect 实现 Inspector
+func (c *InstrumentedCache) Inspect(ctx context.Context, key string) (*KeyInfo, error) {
+	return Inspect(ctx, c.cache, key)
+}
+
+// Metrics 返回统计数据
+func (c *InstrumentedCache) Metrics() *Metrics {
+	return c.metrics
+}
+
+// Serializer 返回被包装的缓存写入时使用的 Serializer
+func (c *InstrumentedCache) Serializer() *Serializer {
+	return serializerOf(c.cache)
+}
+
+// Close 关闭被包装的缓存
+func (c *InstrumentedCache) Close() error {
+	if closer, ok := c.cache.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// KeyInfo 描述缓存中的一个 key，用于调试
+type KeyInfo struct {
+	Key         string `json:"key"`
+	Size        int    `json:"size"`   // 序列化后的字节数
+	TTLMs       int64  `json:"ttl_ms"` // -1 表示永不过期
+	Codec       string `json:"codec"`
+	Compression string `json:"compression"`
+	InL1        *bool  `json:"in_l1,omitempty"` // 仅 tiered 模式
+
+	raw []byte
+}
+
+// Decode 将 key 的值解码到 dest
+func (i *KeyInfo) Decode(dest interface{}) error {
+	return unmarshalValue(i.raw, dest)
+}
+
+// Value 将值解码为通用结构；Loader 写入的条目会展开为 value、not_found 和 fresh_until
+func (i *KeyInfo) Value() (interface{}, error) {
+	var entry Entry
+	if err := i.Decode(&entry); err == nil && !entry.FreshUntil.IsZero() {
+		unwrapped := map[string]interface{}{"fresh_until": entry.FreshUntil}
+		if entry.NotFound {
+			unwrapped["not_found"] = true
+			return unwrapped, nil
+		}
+		var value interface{}
+		if err := unmarshalValue(entry.Value, &value); err != nil {
+			return nil, err
+		}
+		unwrapped["value"] = value
+		return unwrapped, nil
+	}
+
+	var value interface{}
+	if err := i.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Inspector 由支持查看原始数据的缓存实现，key 不存在时返回 ErrMiss
+type Inspector interface {
+	Inspect(ctx context.Context, key string) (*KeyInfo, error)
+}
+
+// ErrInspectUnsupported 表示缓存实现不支持 Inspect
+var ErrInspectUnsupported = errors.New("cache does not support inspection")
+
+// Inspect 查看 key 的元数据和原始值
+func Inspect(ctx context.Context, c RedisCacheInterface, key string) (*KeyInfo, error) {
+	inspector, ok := c.(Inspector)
+	if !ok {
+		return nil, ErrInspectUnsupported
+	}
+	return inspector.Inspect(ctx, key)
+}
+
+func newKeyInfo(key string, raw []byte, ttl time.Duration) *KeyInfo {
+	info := &KeyInfo{
+		Key:   key,
+		Size:  len(raw),
+		TTLMs: -1,
+		raw:   raw,
+	}
+	if ttl > 0 {
+		info.TTLMs = ttl.Milliseconds()
+	}
+	info.Codec, info.Compression = describeFormat(raw)
+	return info
+}
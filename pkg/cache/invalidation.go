@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const DefaultInvalidationChannel = "cache:invalidate"
+
+const resubscribeBackoff = time.Second
+
+// Publisher 广播 key 失效消息，由 TieredCache 在覆盖或删除 key 时调用
+type Publisher interface {
+	Publish(ctx context.Context, keys ...string) error
+	PublishPrefix(ctx context.Context, prefix string) error
+}
+
+// InvalidationTarget 是接收失效消息的进程内缓存
+type InvalidationTarget interface {
+	Evict(ctx context.Context, keys ...string)
+	EvictPrefix(ctx context.Context, prefix string)
+	EvictAll()
+}
+
+type invalidationMessage struct {
+	Origin   string   `json:"o"`
+	Keys     []string `json:"k,omitempty"`
+	Prefixes []string `json:"p,omitempty"`
+}
+
+// Invalidator 通过 Redis pub/sub 在多个实例之间同步进程内缓存：
+// 本实例写入 L2 后发布失效消息，其他实例收到后删除各自 L1 中的 key。
+// 断线期间的消息会丢失，因此每次（重新）订阅成功或订阅出错时都会清空本地缓存
+type Invalidator struct {
+	client  redis.UniversalClient
+	channel string
+	id      string
+}
+
+func NewInvalidator(client redis.UniversalClient, channel string) *Invalidator {
+	if channel == "" {
+		channel = DefaultInvalidationChannel
+	}
+	return &Invalidator{
+		client:  client,
+		channel: channel,
+		id:      newInstanceID(),
+	}
+}
+
+func (i *Invalidator) Publish(ctx context.Context, keys ...string) error {
+	return i.publish(ctx, invalidationMessage{Origin: i.id, Keys: keys})
+}
+
+func (i *Invalidator) PublishPrefix(ctx context.Context, prefix string) error {
+	return i.publish(ctx, invalidationMessage{Origin: i.id, Prefixes: []string{prefix}})
+}
+
+func (i *Invalidator) publish(ctx context.Context, msg invalidationMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return i.client.Publish(ctx, i.channel, data).Err()
+}
+
+// Run 订阅失效消息并从 local 中删除对应的 key，直到 ctx 结束
+func (i *Invalidator) Run(ctx context.Context, local InvalidationTarget) {
+	pubsub := i.client.Subscribe(ctx, i.channel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// 连接断开期间可能错过了失效消息，go-redis 会在下次 Receive 时重连并重新订阅
+			logger.Logger.Warn("cache invalidation subscription error", zap.Error(err))
+			local.EvictAll()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeBackoff):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" {
+				local.EvictAll()
+			}
+		case *redis.Message:
+			var inv invalidationMessage
+			if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil {
+				logger.Logger.Warn("invalid cache invalidation message", zap.String("payload", m.Payload), zap.Error(err))
+				continue
+			}
+			if inv.Origin == i.id {
+				continue
+			}
+			local.Evict(ctx, inv.Keys...)
+			for _, prefix := range inv.Prefixes {
+				local.EvictPrefix(ctx, prefix)
+			}
+		}
+	}
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
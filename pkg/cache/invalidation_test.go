@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestInstance 模拟一个 API 实例：独立的 L1，共享 miniredis 作为 L2
+func newTestInstance(t *testing.T, addr string) (*TieredCache, *MemoryCache) {
+	l1, err := NewMemoryCache(100, EvictionLRU)
+	require.NoError(t, err)
+
+	l2 := NewRedisCache(addr, "", 0)
+	inv := NewInvalidator(l2.Client(), "test:invalidate")
+	c := NewTieredCache(l1, l2, time.Hour, inv)
+	c.StartInvalidation(inv)
+	t.Cleanup(func() { c.Close() })
+
+	return c, l1
+}
+
+// waitSubscribers 等待 n 个实例完成订阅
+func waitSubscribers(t *testing.T, s *miniredis.Miniredis, n int) {
+	require.Eventually(t, func() bool {
+		return s.PubSubNumSub("test:invalidate")["test:invalidate"] == n
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestInvalidationAcrossInstances(t *testing.T) {
+	s := miniredis.RunT(t)
+	ctx := context.Background()
+
+	a, _ := newTestInstance(t, s.Addr())
+	b, bL1 := newTestInstance(t, s.Addr())
+	waitSubscribers(t, s, 2)
+
+	require.NoError(t, a.Set(ctx, "user:1", "v1", time.Hour))
+
+	// b 读取后 L1 中缓存了 v1（a 的失效消息可能晚于读取到达，此时不会回填）
+	var v string
+	require.Eventually(t, func() bool {
+		return b.Get(ctx, "user:1", &v) == nil && bL1.Len() == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "v1", v)
+
+	// a 覆盖写入后 b 的 L1 被清除，随后读到新值
+	require.NoError(t, a.Set(ctx, "user:1", "v2", time.Hour))
+	require.Eventually(t, func() bool { return bL1.Len() == 0 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, b.Get(ctx, "user:1", &v))
+	assert.Equal(t, "v2", v)
+
+	// a 删除后 b 也读不到
+	require.NoError(t, a.Delete(ctx, "user:1"))
+	require.Eventually(t, func() bool {
+		return b.Get(ctx, "user:1", &v) == ErrMiss
+	}, time.Second, 10*time.Millisecond)
+
+	// Incr 同样会广播
+	_, err := a.Incr(ctx, "user:1:version")
+	require.NoError(t, err)
+	var ver int64
+	require.NoError(t, b.Get(ctx, "user:1:version", &ver))
+	assert.Equal(t, int64(1), ver)
+	_, err = a.Incr(ctx, "user:1:version")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return b.Get(ctx, "user:1:version", &ver) == nil && ver == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestInvalidationFlushesOnResubscribe(t *testing.T) {
+	s := miniredis.RunT(t)
+	ctx := context.Background()
+
+	a, _ := newTestInstance(t, s.Addr())
+	b, bL1 := newTestInstance(t, s.Addr())
+	waitSubscribers(t, s, 2)
+
+	require.NoError(t, a.Set(ctx, "user:1", "v1", time.Hour))
+	var v string
+	require.Eventually(t, func() bool {
+		return b.Get(ctx, "user:1", &v) == nil && bL1.Len() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Redis 重启期间的失效消息会丢失，b 重新订阅后清空整个 L1
+	s.Close()
+	require.NoError(t, s.Restart())
+	waitSubscribers(t, s, 2)
+
+	require.Eventually(t, func() bool { return bL1.Len() == 0 }, 3*time.Second, 10*time.Millisecond)
+}
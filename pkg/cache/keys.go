@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key 描述一个缓存条目：由若干部分组成的名字，以及该条目依赖的标签。
+// 任一标签失效后，通过 TagSet.Resolve 得到的物理 key 都会改变，旧条目不再被读取
+type Key struct {
+	name string
+	tags []string
+}
+
+// NewKey 以 ":" 连接各部分生成 key，例如 NewKey("user", 42, "profile") => user:42:profile
+func NewKey(parts ...interface{}) Key {
+	strs := make([]string, len(parts))
+	for i, p := range parts {
+		strs[i] = fmt.Sprint(p)
+	}
+	return Key{name: strings.Join(strs, ":")}
+}
+
+// WithTags 返回附加了标签的 key
+func (k Key) WithTags(tags ...string) Key {
+	k.tags = append(append([]string(nil), k.tags...), tags...)
+	return k
+}
+
+func (k Key) String() string {
+	return k.name
+}
+
+func (k Key) Tags() []string {
+	return k.tags
+}
+
+// TagSet 基于标签版本号实现按标签失效：每个标签对应一个版本号 (tag:<tag>)，
+// 物理 key 中包含其所有标签的当前版本号，InvalidateTag 自增版本号即可让所有派生条目失效，
+// 旧条目由 TTL 自然清理。并发的读请求若在失效前读到旧数据，只会回填到旧版本的 key 上
+type TagSet struct {
+	cache RedisCacheInterface
+}
+
+func NewTagSet(cache RedisCacheInterface) *TagSet {
+	return &TagSet{cache: cache}
+}
+
+func tagVersionKey(tag string) string {
+	return "tag:" + tag
+}
+
+// Resolve 返回 key 在各标签当前版本下的物理 key，例如 user:42:profile@3.1。
+// 返回错误时无法保证一致性，调用方应绕过缓存
+func (t *TagSet) Resolve(ctx context.Context, key Key) (string, error) {
+	if len(key.tags) == 0 {
+		return key.name, nil
+	}
+
+	versions := make([]string, len(key.tags))
+	for i, tag := range key.tags {
+		version, err := t.version(ctx, tag)
+		if err != nil {
+			return "", err
+		}
+		versions[i] = strconv.FormatInt(version, 10)
+	}
+	return key.name + "@" + strings.Join(versions, "."), nil
+}
+
+// InvalidateTag 使依赖这些标签的所有条目失效
+func (t *TagSet) InvalidateTag(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		if err := t.seed(ctx, tag); err != nil {
+			return fmt.Errorf("invalidate tag %q: %w", tag, err)
+		}
+		if _, err := t.cache.Incr(ctx, tagVersionKey(tag)); err != nil {
+			return fmt.Errorf("invalidate tag %q: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// version 读取标签的当前版本号，版本号不存在（例如被淘汰）时重新建立；缓存异常时返回错误
+func (t *TagSet) version(ctx context.Context, tag string) (int64, error) {
+	var version int64
+	err := t.cache.Get(ctx, tagVersionKey(tag), &version)
+	if err == nil {
+		return version, nil
+	}
+	if !errors.Is(err, ErrMiss) {
+		return 0, err
+	}
+	if err := t.seed(ctx, tag); err != nil {
+		return 0, err
+	}
+	// 并发建立时以先写入的为准
+	if err := t.cache.Get(ctx, tagVersionKey(tag), &version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// seed 在标签的版本号不存在时以当前的纳秒时间戳建立。版本号被淘汰后不能从 1 重新计数，
+// 否则 @1、@2 等尚未过期的旧条目会被当作当前版本再次读取
+func (t *TagSet) seed(ctx context.Context, tag string) error {
+	_, err := t.cache.SetCounterNX(ctx, tagVersionKey(tag), time.Now().UnixNano())
+	return err
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKey(t *testing.T) {
+	key := NewKey("user", 42, "profile").WithTags("user:42")
+	assert.Equal(t, "user:42:profile", key.String())
+	assert.Equal(t, []string{"user:42"}, key.Tags())
+}
+
+func TestTagSetInvalidateTag(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemoryCache(0, EvictionLRU)
+	require.NoError(t, err)
+	tags := NewTagSet(c)
+
+	profile := NewKey("user", 42, "profile").WithTags("user:42")
+	prefs := NewKey("user", 42, "preferences").WithTags("user:42", "user:42:preferences")
+	other := NewKey("user", 43, "profile").WithTags("user:43")
+
+	resolve := func(key Key) string {
+		resolved, err := tags.Resolve(ctx, key)
+		require.NoError(t, err)
+		return resolved
+	}
+
+	for _, key := range []Key{profile, prefs, other} {
+		require.NoError(t, c.Set(ctx, resolve(key), "cached", 0))
+	}
+
+	require.NoError(t, tags.InvalidateTag(ctx, "user:42"))
+
+	var v string
+	assert.ErrorIs(t, c.Get(ctx, resolve(profile), &v), ErrMiss)
+	assert.ErrorIs(t, c.Get(ctx, resolve(prefs), &v), ErrMiss)
+	assert.NoError(t, c.Get(ctx, resolve(other), &v))
+
+	// 只失效偏好标签不影响用户资料
+	require.NoError(t, c.Set(ctx, resolve(profile), "cached", 0))
+	require.NoError(t, tags.InvalidateTag(ctx, "user:42:preferences"))
+	assert.NoError(t, c.Get(ctx, resolve(profile), &v))
+	assert.ErrorIs(t, c.Get(ctx, resolve(prefs), &v), ErrMiss)
+}
+
+// 标签的版本号被淘汰后重新建立，不能回到仍未过期的旧版本
+func TestTagSetEvictedVersion(t *testing.T) {
+	ctx := context.Background()
+	for name, c := range map[string]RedisCacheInterface{
+		"memory": func() RedisCacheInterface {
+			c, err := NewMemoryCache(0, EvictionLRU)
+			require.NoError(t, err)
+			return c
+		}(),
+		"redis": NewRedisCache(miniredis.RunT(t).Addr(), "", 0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			tags := NewTagSet(c)
+			key := NewKey("user", 42, "profile").WithTags("user:42")
+
+			var old []string
+			for i := 0; i < 3; i++ {
+				resolved, err := tags.Resolve(ctx, key)
+				require.NoError(t, err)
+				require.NoError(t, c.Set(ctx, resolved, "stale", 0))
+				old = append(old, resolved)
+				require.NoError(t, tags.InvalidateTag(ctx, "user:42"))
+			}
+
+			for i := 0; i < 2; i++ {
+				require.NoError(t, c.Delete(ctx, tagVersionKey("user:42")))
+				if i == 1 {
+					// 淘汰后先失效再读取
+					require.NoError(t, tags.InvalidateTag(ctx, "user:42"))
+				}
+				resolved, err := tags.Resolve(ctx, key)
+				require.NoError(t, err)
+				assert.NotContains(t, old, resolved)
+				var v string
+				assert.ErrorIs(t, c.Get(ctx, resolved, &v), ErrMiss)
+			}
+		})
+	}
+}
+
+func TestMemorySetCounterNX(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemoryCache(0, EvictionLRU)
+	require.NoError(t, err)
+
+	ok, err := c.SetCounterNX(ctx, "k", 10)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = c.SetCounterNX(ctx, "k", 20)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	n, err := c.Incr(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+}
+
+func TestPrefixedCache(t *testing.T) {
+	ctx := context.Background()
+	inner, err := NewMemoryCache(0, EvictionLRU)
+	require.NoError(t, err)
+	c := NewPrefixedCache(inner, "app:prod:v2:")
+
+	require.NoError(t, c.Set(ctx, "user:1", "v", 0))
+	var v string
+	require.NoError(t, inner.Get(ctx, "app:prod:v2:user:1", &v))
+	require.NoError(t, inner.Set(ctx, "app:prod:v1:user:1", "old", 0))
+
+	n, err := c.DeleteByPrefix(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.NoError(t, inner.Get(ctx, "app:prod:v1:user:1", &v))
+}
+
+func TestRedisDeleteByPrefix(t *testing.T) {
+	s := miniredis.RunT(t)
+	ctx := context.Background()
+	c := NewRedisCache(s.Addr(), "", 0)
+
+	for i := 0; i < 2500; i++ {
+		require.NoError(t, c.Set(ctx, fmt.Sprintf("app:v1:user:%d", i), i, 0))
+	}
+	require.NoError(t, c.Set(ctx, "app:v2:user:1", 1, 0))
+	require.NoError(t, c.Set(ctx, "app:v1*", "glob", 0))
+
+	n, err := c.DeleteByPrefix(ctx, "app:v1:")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2500), n)
+	assert.True(t, s.Exists("app:v2:user:1"))
+
+	// prefix 中的 glob 字符按字面匹配
+	n, err = c.DeleteByPrefix(ctx, "app:v1*")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.True(t, s.Exists("app:v2:user:1"))
+}
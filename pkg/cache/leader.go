@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Elector 基于 Locker 实现简单的领导者选举：多个实例以相同的 name 调用 Run，
+// 同一时刻只有获取到锁的实例执行 fn，其余实例每隔 retryInterval 尝试接管
+type Elector struct {
+	locker        *Locker
+	name          string
+	ttl           time.Duration
+	retryInterval time.Duration
+	leader        atomic.Bool
+}
+
+// NewElector 创建选举器，ttl 为锁的过期时间，也是原领导者异常退出后其他实例接管的最长等待时间
+func NewElector(locker *Locker, name string, ttl time.Duration) *Elector {
+	return &Elector{
+		locker:        locker,
+		name:          name,
+		ttl:           ttl,
+		retryInterval: ttl / 2,
+	}
+}
+
+// IsLeader 返回当前实例是否为领导者
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run 阻塞直到 ctx 结束。成为领导者后调用 fn，失去领导权时 fn 的 ctx 被取消；
+// fn 返回后释放领导权，之后重新参与选举
+func (e *Elector) Run(ctx context.Context, fn func(ctx context.Context)) {
+	key := "leader:" + e.name
+	for {
+		lock, err := e.locker.TryObtain(ctx, key, e.ttl)
+		switch {
+		case err == nil:
+			e.lead(ctx, lock, fn)
+		case !errors.Is(err, ErrLockNotAcquired):
+			logger.Logger.Warn("leader election failed", zap.String("name", e.name), zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.retryInterval):
+		}
+	}
+}
+
+func (e *Elector) lead(ctx context.Context, lock *Lock, fn func(ctx context.Context)) {
+	logger.Logger.Info("became leader", zap.String("name", e.name))
+	e.leader.Store(true)
+	defer e.leader.Store(false)
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lock.Done():
+			cancel()
+		case <-leaderCtx.Done():
+		}
+	}()
+
+	fn(leaderCtx)
+
+	if err := lock.Release(context.WithoutCancel(ctx)); err != nil && !errors.Is(err, ErrLockNotHeld) {
+		logger.Logger.Warn("failed to release leadership", zap.String("name", e.name), zap.Error(err))
+	}
+	logger.Logger.Info("stepped down as leader", zap.String("name", e.name))
+}
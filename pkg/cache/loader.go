@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultLoadTimeout 是 LoaderOptions.LoadTimeout 为 0 时回源加载的时间上限
+const defaultLoadTimeout = 30 * time.Second
+
+// ErrNotFound 由 LoadFunc 返回表示数据不存在，Loader 会按 NegativeTTL 缓存该结果
+var ErrNotFound = errors.New("not found")
+
+// LoadFunc 在缓存未命中时加载数据
+type LoadFunc func(ctx context.Context) (interface{}, error)
+
+type LoaderOptions struct {
+	StaleTTL    time.Duration // 数据过期后仍可返回旧值并在后台刷新的时间窗口，0 表示不启用
+	Jitter      float64       // TTL 随机抖动比例，例如 0.1 表示 ±10%，避免大量 key 同时过期
+	NegativeTTL time.Duration // 缓存 "不存在" 结果的时间，0 表示不缓存
+	LoadTimeout time.Duration // 单次回源加载的时间上限，0 表示 30s。合并的加载不随调用方取消，超时后后续调用方才能重新加载
+	Metrics     *Metrics      // 记录回源加载的次数和耗时，nil 表示使用 DefaultMetrics
+}
+
+// Entry 是 Loader 写入缓存的数据格式，记录数据的新鲜期限以支持 stale-while-revalidate。
+// Value 使用与缓存相同的编码格式（带格式头，不压缩），以保留原值的类型信息
+type Entry struct {
+	Value      []byte    `json:"v,omitempty"`
+	NotFound   bool      `json:"nf,omitempty"`
+	FreshUntil time.Time `json:"f"`
+}
+
+// NewEntry 创建在 freshUntil 之前保持新鲜的缓存条目，Value 以 JSON 编码
+func NewEntry(value interface{}, freshUntil time.Time) (*Entry, error) {
+	return newEntry(defaultSerializer, value, freshUntil)
+}
+
+func newEntry(s *Serializer, value interface{}, freshUntil time.Time) (*Entry, error) {
+	data, err := s.withoutCompression().Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{Value: data, FreshUntil: freshUntil}, nil
+}
+
+// Decode 将缓存的值解码到 dest，"不存在" 条目返回 ErrNotFound
+func (e *Entry) Decode(dest interface{}) error {
+	if e.NotFound {
+		return ErrNotFound
+	}
+	return unmarshalValue(e.Value, dest)
+}
+
+// Loader 在缓存之上提供 GetOrLoad：同一 key 的并发加载合并为一次（singleflight），
+// 可选地在过期后先返回旧值并在后台刷新，并短暂缓存 "不存在" 的结果，防止缓存击穿
+type Loader struct {
+	cache RedisCacheInterface
+	opts  LoaderOptions
+	group singleflight.Group
+	now   func() time.Time
+}
+
+func NewLoader(cache RedisCacheInterface, opts LoaderOptions) *Loader {
+	if opts.Metrics == nil {
+		opts.Metrics = DefaultMetrics
+	}
+	if opts.LoadTimeout <= 0 {
+		opts.LoadTimeout = defaultLoadTimeout
+	}
+	return &Loader{
+		cache: cache,
+		opts:  opts,
+		now:   time.Now,
+	}
+}
+
+// GetOrLoad 从缓存读取 key 并解码到 dest，未命中时调用 load 加载并写入缓存
+func (l *Loader) GetOrLoad(ctx context.Context, key string, ttl time.Duration, dest interface{}, load LoadFunc) error {
+	var entry Entry
+	err := l.cache.Get(ctx, key, &entry)
+	if err == nil {
+		if l.now().Before(entry.FreshUntil) {
+			return entry.Decode(dest)
+		}
+		// 已过期但仍在 stale 窗口内（否则缓存已将其删除），先返回旧值
+		if l.opts.StaleTTL > 0 {
+			go l.refresh(context.WithoutCancel(ctx), key, ttl, load)
+			return entry.Decode(dest)
+		}
+	}
+
+	// 缓存不可用时直接回源，不再尝试写入
+	store := err == nil || errors.Is(err, ErrMiss)
+	if !store {
+		logger.Logger.Warn("cache unavailable, loading from source", zap.String("key", key), zap.Error(err))
+	}
+
+	// 合并后的加载由多个调用方共享，不能因第一个调用方取消而失败；
+	// 每个调用方只在自己的 ctx 结束时提前返回
+	ch := l.group.DoChan(key, func() (interface{}, error) {
+		return l.load(context.WithoutCancel(ctx), key, ttl, load, store)
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return res.Err
+		}
+		return res.Val.(*Entry).Decode(dest)
+	}
+}
+
+// Store 主动写入 key 的最新值（write-through），格式与 GetOrLoad 一致
+func (l *Loader) Store(ctx context.Context, key string, ttl time.Duration, value interface{}) error {
+	freshFor := l.jitter(ttl)
+	entry, err := newEntry(serializerOf(l.cache), value, l.now().Add(freshFor))
+	if err != nil {
+		return err
+	}
+	return l.cache.Set(ctx, key, entry, freshFor+l.opts.StaleTTL)
+}
+
+func (l *Loader) refresh(ctx context.Context, key string, ttl time.Duration, load LoadFunc) {
+	_, err, _ := l.group.Do(key, func() (interface{}, error) {
+		return l.load(ctx, key, ttl, load, true)
+	})
+	if err != nil {
+		logger.Logger.Warn("failed to refresh cache", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func (l *Loader) load(ctx context.Context, key string, ttl time.Duration, load LoadFunc, store bool) (interface{}, error) {
+	// ctx 不会被调用方取消，没有上限时一次挂起的查询会阻塞该 key 之后的所有调用方
+	ctx, cancel := context.WithTimeout(ctx, l.opts.LoadTimeout)
+	defer cancel()
+
+	start := time.Now()
+	value, err := load(ctx)
+	if errors.Is(err, ErrNotFound) {
+		l.opts.Metrics.Load(key, time.Since(start), nil)
+		entry := &Entry{NotFound: true, FreshUntil: l.now().Add(l.opts.NegativeTTL)}
+		if store && l.opts.NegativeTTL > 0 {
+			if err := l.cache.Set(ctx, key, entry, l.opts.NegativeTTL); err != nil {
+				logger.Logger.Warn("failed to set cache", zap.String("key", key), zap.Error(err))
+			}
+		}
+		return entry, nil
+	}
+	l.opts.Metrics.Load(key, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	freshFor := l.jitter(ttl)
+	entry, err := newEntry(serializerOf(l.cache), value, l.now().Add(freshFor))
+	if err != nil {
+		return nil, err
+	}
+
+	if !store {
+		return entry, nil
+	}
+	if err := l.cache.Set(ctx, key, entry, freshFor+l.opts.StaleTTL); err != nil {
+		logger.Logger.Warn("failed to set cache", zap.String("key", key), zap.Error(err))
+	}
+	return entry, nil
+}
+
+func (l *Loader) jitter(ttl time.Duration) time.Duration {
+	if l.opts.Jitter <= 0 {
+		return ttl
+	}
+	factor := 1 + (rand.Float64()*2-1)*l.opts.Jitter
+	return time.Duration(float64(ttl) * factor)
+}
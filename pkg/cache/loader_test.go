@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLoader(t *testing.T, opts LoaderOptions) (*Loader, *MemoryCache) {
+	c, err := NewMemoryCache(0, EvictionLRU)
+	require.NoError(t, err)
+	return NewLoader(c, opts), c
+}
+
+func TestLoaderCollapsesConcurrentLoads(t *testing.T) {
+	loader, _ := newTestLoader(t, LoaderOptions{})
+	ctx := context.Background()
+
+	var calls int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "value", nil
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	results := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, loader.GetOrLoad(ctx, "k", time.Minute, &results[i], load))
+		}(i)
+	}
+
+	// 等待所有请求进入 singleflight 后再放行加载
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, r := range results {
+		assert.Equal(t, "value", r)
+	}
+
+	// 之后的请求直接命中缓存
+	var v string
+	require.NoError(t, loader.GetOrLoad(ctx, "k", time.Minute, &v, load))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestLoaderCanceledCallerDoesNotFailSharedLoad(t *testing.T) {
+	loader, _ := newTestLoader(t, LoaderOptions{})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return "value", nil
+	}
+
+	// 第一个调用方发起加载后取消
+	firstCtx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		var v string
+		firstErr <- loader.GetOrLoad(firstCtx, "k", time.Minute, &v, load)
+	}()
+	<-started
+
+	secondErr := make(chan error, 1)
+	var second string
+	go func() {
+		secondErr <- loader.GetOrLoad(context.Background(), "k", time.Minute, &second, load)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+
+	close(release)
+	require.NoError(t, <-secondErr)
+	assert.Equal(t, "value", second)
+}
+
+func TestLoaderNegativeCaching(t *testing.T) {
+	loader, _ := newTestLoader(t, LoaderOptions{NegativeTTL: time.Minute})
+	ctx := context.Background()
+
+	var calls int
+	load := func(ctx context.Context) (interface{}, error) {
+		calls++
+		return nil, ErrNotFound
+	}
+
+	var v string
+	assert.ErrorIs(t, loader.GetOrLoad(ctx, "missing", time.Minute, &v, load), ErrNotFound)
+	assert.ErrorIs(t, loader.GetOrLoad(ctx, "missing", time.Minute, &v, load), ErrNotFound)
+	assert.Equal(t, 1, calls)
+}
+
+func TestLoaderDoesNotCacheErrors(t *testing.T) {
+	loader, _ := newTestLoader(t, LoaderOptions{NegativeTTL: time.Minute})
+	ctx := context.Background()
+
+	var calls int
+	load := func(ctx context.Context) (interface{}, error) {
+		calls++
+		return nil, errors.New("db down")
+	}
+
+	var v string
+	assert.EqualError(t, loader.GetOrLoad(ctx, "k", time.Minute, &v, load), "db down")
+	assert.EqualError(t, loader.GetOrLoad(ctx, "k", time.Minute, &v, load), "db down")
+	assert.Equal(t, 2, calls)
+}
+
+func TestLoaderStaleWhileRevalidate(t *testing.T) {
+	loader, c := newTestLoader(t, LoaderOptions{StaleTTL: time.Minute})
+	ctx := context.Background()
+
+	now := time.Now()
+	loader.now = func() time.Time { return now }
+	c.now = func() time.Time { return now }
+
+	refreshed := make(chan struct{})
+	version := "v1"
+	load := func(ctx context.Context) (interface{}, error) {
+		if version == "v2" {
+			defer close(refreshed)
+		}
+		return version, nil
+	}
+
+	var v string
+	require.NoError(t, loader.GetOrLoad(ctx, "k", time.Minute, &v, load))
+	assert.Equal(t, "v1", v)
+
+	// 过期但仍在 stale 窗口内：立即返回旧值，并在后台刷新
+	now = now.Add(90 * time.Second)
+	version = "v2"
+	require.NoError(t, loader.GetOrLoad(ctx, "k", time.Minute, &v, load))
+	assert.Equal(t, "v1", v)
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("stale entry was not refreshed")
+	}
+
+	assert.Eventually(t, func() bool {
+		var got string
+		return loader.GetOrLoad(ctx, "k", time.Minute, &got, load) == nil && got == "v2"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLoaderJitter(t *testing.T) {
+	loader, _ := newTestLoader(t, LoaderOptions{Jitter: 0.1})
+
+	for i := 0; i < 100; i++ {
+		ttl := loader.jitter(time.Minute)
+		assert.GreaterOrEqual(t, ttl, 54*time.Second)
+		assert.LessOrEqual(t, ttl, 66*time.Second)
+	}
+}
+
+// 挂起的加载超时后结束，之后的调用方可以重新加载
+func TestLoaderLoadTimeout(t *testing.T) {
+	loader, _ := newTestLoader(t, LoaderOptions{LoadTimeout: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	hang := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	var v string
+	err := loader.GetOrLoad(ctx, "k", time.Minute, &v, hang)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, loader.GetOrLoad(ctx, "k", time.Minute, &v, func(ctx context.Context) (interface{}, error) {
+		_, ok := ctx.Deadline()
+		assert.True(t, ok, "loads always have a deadline")
+		return "value", nil
+	}))
+	assert.Equal(t, "value", v)
+}
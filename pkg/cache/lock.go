@@ -0,0 +1,315 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrLockNotAcquired 表示锁已被其他持有者占用
+	ErrLockNotAcquired = errors.New("lock not acquired")
+	// ErrLockNotHeld 表示锁已过期或已被其他持有者获取
+	ErrLockNotHeld = errors.New("lock not held")
+	// ErrLockUnavailable 表示加锁时后端出错（例如 Redis 不可用或已熔断），无法判断锁是否被占用
+	ErrLockUnavailable = errors.New("lock backend unavailable")
+)
+
+const (
+	defaultLockRetryInterval = 50 * time.Millisecond
+	lockKeyPrefix            = "lock:"
+)
+
+// lockBackend 实现基于 token 的加锁、续期和释放，只有持有相同 token 时才能续期或释放
+type lockBackend interface {
+	acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	release(ctx context.Context, key, token string) (bool, error)
+}
+
+// Locker 提供跨实例的互斥锁。获取到的锁在持有期间每隔 ttl/3 自动续期，
+// 续期失败（锁已过期被他人获取）时 Lock.Done 被关闭，持有者应停止受保护的操作
+type Locker struct {
+	backend       lockBackend
+	prefix        string
+	retryInterval time.Duration
+}
+
+// NewRedisLocker 创建基于 Redis SET NX 的锁，prefix 会加在所有锁的 key 前面
+func NewRedisLocker(client redis.UniversalClient, prefix string) *Locker {
+	return &Locker{
+		backend:       &redisLockBackend{client: client},
+		prefix:        prefix,
+		retryInterval: defaultLockRetryInterval,
+	}
+}
+
+// NewMemoryLocker 创建进程内的锁，用于单实例部署和测试
+func NewMemoryLocker() *Locker {
+	return newMemoryLocker(newMemoryLockBackend(time.Now))
+}
+
+func newMemoryLocker(backend *memoryLockBackend) *Locker {
+	return &Locker{
+		backend:       backend,
+		retryInterval: defaultLockRetryInterval,
+	}
+}
+
+// TryObtain 尝试获取锁，已被占用时立即返回 ErrLockNotAcquired，后端出错时返回包装了 ErrLockUnavailable 的错误
+func (l *Locker) TryObtain(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	fullKey := l.prefix + lockKeyPrefix + key
+	ok, err := l.backend.acquire(ctx, fullKey, token, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLockUnavailable, err)
+	}
+	if !ok {
+		return nil, ErrLockNotAcquired
+	}
+
+	lock := &Lock{
+		locker: l,
+		key:    fullKey,
+		token:  token,
+		ttl:    ttl,
+		done:   make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	go lock.keepAlive()
+	return lock, nil
+}
+
+// Obtain 获取锁，被占用时每隔 retryInterval 重试，直到成功或 ctx 结束
+func (l *Locker) Obtain(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	ticker := time.NewTicker(l.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		lock, err := l.TryObtain(ctx, key, ttl)
+		if !errors.Is(err, ErrLockNotAcquired) {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WithLock 持有锁执行 fn，锁丢失时 fn 的 ctx 会被取消，fn 返回后释放锁
+func (l *Locker) WithLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	return l.WithLockWait(ctx, key, ttl, 0, fn)
+}
+
+// WithLockWait 与 WithLock 相同，但获取锁最多等待 wait（<= 0 表示不限制），等待时间不计入 fn 的执行时间
+func (l *Locker) WithLockWait(ctx context.Context, key string, ttl, wait time.Duration, fn func(ctx context.Context) error) error {
+	obtainCtx := ctx
+	if wait > 0 {
+		var cancel context.CancelFunc
+		obtainCtx, cancel = context.WithTimeout(ctx, wait)
+		defer cancel()
+	}
+	lock, err := l.Obtain(obtainCtx, key, ttl)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil && !errors.Is(err, ErrLockNotHeld) {
+			logger.Logger.Warn("failed to release lock", zap.String("key", lock.key), zap.Error(err))
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lock.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// Lock 是一次成功的加锁
+type Lock struct {
+	locker *Locker
+	key    string
+	token  string
+	ttl    time.Duration
+
+	done     chan struct{} // 释放或丢失锁时关闭
+	stop     chan struct{} // 通知续期 goroutine 退出
+	stopOnce sync.Once
+	doneOnce sync.Once
+}
+
+// Done 在锁被释放或丢失后关闭
+func (l *Lock) Done() <-chan struct{} {
+	return l.done
+}
+
+// Release 释放锁。锁已过期或被他人获取时返回 ErrLockNotHeld，不会删除他人的锁
+func (l *Lock) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	defer l.doneOnce.Do(func() { close(l.done) })
+
+	ok, err := l.locker.backend.release(ctx, l.key, l.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// keepAlive 每隔 ttl/3 续期一次；续期出错时重试，直到距上次成功续期超过 ttl 才认为锁已丢失
+func (l *Lock) keepAlive() {
+	interval := l.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastExtended := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		ok, err := l.locker.backend.extend(ctx, l.key, l.token, l.ttl)
+		cancel()
+
+		switch {
+		case err == nil && ok:
+			lastExtended = time.Now()
+			continue
+		case err != nil && time.Since(lastExtended) < l.ttl:
+			logger.Logger.Warn("failed to extend lock", zap.String("key", l.key), zap.Error(err))
+			continue
+		}
+
+		logger.Logger.Warn("lock lost", zap.String("key", l.key))
+		l.doneOnce.Do(func() { close(l.done) })
+		return
+	}
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+var (
+	extendScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+)
+
+type redisLockBackend struct {
+	client redis.UniversalClient
+}
+
+func (b *redisLockBackend) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return b.client.SetNX(ctx, key, token, ttl).Result()
+}
+
+func (b *redisLockBackend) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	n, err := extendScript.Run(ctx, b.client, []string{key}, token, ttl.Milliseconds()).Int64()
+	return n == 1, err
+}
+
+func (b *redisLockBackend) release(ctx context.Context, key, token string) (bool, error) {
+	n, err := releaseScript.Run(ctx, b.client, []string{key}, token).Int64()
+	return n == 1, err
+}
+
+// memoryLockBackend 使用可注入的时钟判断过期，测试中可以精确控制锁的过期时间
+type memoryLockBackend struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+	now   func() time.Time
+}
+
+type memoryLock struct {
+	token     string
+	expiresAt time.Time
+}
+
+func newMemoryLockBackend(now func() time.Time) *memoryLockBackend {
+	return &memoryLockBackend{locks: make(map[string]memoryLock), now: now}
+}
+
+func (b *memoryLockBackend) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.heldLocked(key); ok {
+		return false, nil
+	}
+	b.locks[key] = memoryLock{token: token, expiresAt: b.now().Add(ttl)}
+	return true, nil
+}
+
+func (b *memoryLockBackend) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if held, ok := b.heldLocked(key); !ok || held.token != token {
+		return false, nil
+	}
+	b.locks[key] = memoryLock{token: token, expiresAt: b.now().Add(ttl)}
+	return true, nil
+}
+
+func (b *memoryLockBackend) release(ctx context.Context, key, token string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if held, ok := b.heldLocked(key); !ok || held.token != token {
+		return false, nil
+	}
+	delete(b.locks, key)
+	return true, nil
+}
+
+func (b *memoryLockBackend) heldLocked(key string) (memoryLock, bool) {
+	held, ok := b.locks[key]
+	if !ok {
+		return memoryLock{}, false
+	}
+	if !b.now().Before(held.expiresAt) {
+		delete(b.locks, key)
+		return memoryLock{}, false
+	}
+	return held, true
+}
@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestMemoryLockExpiryAndSafeRelease(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	locker := newMemoryLocker(newMemoryLockBackend(clock.Now))
+
+	// ttl 足够长，续期 goroutine 在测试期间不会触发
+	first, err := locker.TryObtain(ctx, "job", time.Hour)
+	require.NoError(t, err)
+
+	_, err = locker.TryObtain(ctx, "job", time.Hour)
+	assert.ErrorIs(t, err, ErrLockNotAcquired)
+
+	// 过期后其他持有者可以获取，原持有者释放时不会删除新持有者的锁
+	clock.Advance(time.Hour)
+	second, err := locker.TryObtain(ctx, "job", time.Hour)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, first.Release(ctx), ErrLockNotHeld)
+	_, err = locker.TryObtain(ctx, "job", time.Hour)
+	assert.ErrorIs(t, err, ErrLockNotAcquired)
+
+	require.NoError(t, second.Release(ctx))
+	third, err := locker.TryObtain(ctx, "job", time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, third.Release(ctx))
+}
+
+func TestObtainRespectsContext(t *testing.T) {
+	locker := NewMemoryLocker()
+	lock, err := locker.TryObtain(context.Background(), "job", time.Hour)
+	require.NoError(t, err)
+	defer lock.Release(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = locker.Obtain(ctx, "job", time.Hour)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestObtainReturnsBackendErrors(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr(), MaxRetries: -1})
+	defer client.Close()
+	s.Close()
+	locker := NewRedisLocker(client, "")
+
+	start := time.Now()
+	_, err := locker.Obtain(context.Background(), "job", time.Hour)
+	assert.ErrorIs(t, err, ErrLockUnavailable)
+	assert.Less(t, time.Since(start), time.Second, "backend errors are not retried")
+}
+
+func TestWithLockIsMutuallyExclusive(t *testing.T) {
+	locker := NewMemoryLocker()
+	var running atomic.Int32
+	var overlapped atomic.Bool
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := locker.WithLock(context.Background(), "job", time.Second, func(ctx context.Context) error {
+				if running.Add(1) > 1 {
+					overlapped.Store(true)
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.False(t, overlapped.Load())
+}
+
+func TestRedisLockAutoExtendAndLoss(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+	locker := NewRedisLocker(client, "app:")
+	ctx := context.Background()
+
+	lock, err := locker.TryObtain(ctx, "job", 300*time.Millisecond)
+	require.NoError(t, err)
+	s.CheckGet(t, "app:lock:job", lock.token)
+
+	// miniredis 需要手动推进时间：推进后剩余 TTL 变短，续期后重新变为 300ms
+	s.FastForward(200 * time.Millisecond)
+	require.Eventually(t, func() bool {
+		return s.TTL("app:lock:job") == 300*time.Millisecond
+	}, time.Second, 10*time.Millisecond)
+
+	// 锁被他人覆盖后续期失败，Done 被关闭
+	s.Set("app:lock:job", "other")
+	select {
+	case <-lock.Done():
+	case <-time.After(time.Second):
+		t.Fatal("lock loss was not detected")
+	}
+	assert.ErrorIs(t, lock.Release(ctx), ErrLockNotHeld)
+	s.CheckGet(t, "app:lock:job", "other")
+}
+
+func TestElectorSingleLeader(t *testing.T) {
+	locker := NewMemoryLocker()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var leaders atomic.Int32
+	var overlapped atomic.Bool
+	var wg sync.WaitGroup
+	electors := make([]*Elector, 3)
+	for i := range electors {
+		electors[i] = NewElector(locker, "relay", 100*time.Millisecond)
+		wg.Add(1)
+		go func(e *Elector) {
+			defer wg.Done()
+			e.Run(ctx, func(ctx context.Context) {
+				if leaders.Add(1) > 1 {
+					overlapped.Store(true)
+				}
+				<-ctx.Done()
+				leaders.Add(-1)
+			})
+		}(electors[i])
+	}
+
+	require.Eventually(t, func() bool { return leaders.Load() == 1 }, time.Second, 10*time.Millisecond)
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	assert.False(t, overlapped.Load())
+	for _, e := range electors {
+		assert.False(t, e.IsLeader())
+	}
+}
@@ -0,0 +1,340 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EvictionLRU = "lru"
+	EvictionLFU = "lfu"
+)
+
+// MemoryCache 是进程内的 RedisCacheInterface 实现，按 LRU 或 LFU 策略淘汰，
+// 条目数量不超过 maxEntries。值以序列化后的字节保存（默认 JSON），与 RedisCache 的语义保持一致
+type MemoryCache struct {
+	mu         sync.Mutex
+	items      map[string]*memoryEntry
+	evictor    evictor
+	maxEntries int
+	serializer *Serializer
+	onEvict    func(key string)
+	now        func() time.Time
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time // 零值表示永不过期
+
+	// LRU
+	elem *list.Element
+	// LFU
+	freq     int
+	lastUsed uint64
+	index    int
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// NewMemoryCache 创建内存缓存，maxEntries <= 0 表示不限制条目数量
+func NewMemoryCache(maxEntries int, eviction string) (*MemoryCache, error) {
+	var ev evictor
+	switch eviction {
+	case "", EvictionLRU:
+		ev = &lruEvictor{ll: list.New()}
+	case EvictionLFU:
+		ev = &lfuEvictor{}
+	default:
+		return nil, fmt.Errorf("unknown cache eviction policy %q", eviction)
+	}
+
+	return &MemoryCache{
+		items:      make(map[string]*memoryEntry),
+		evictor:    ev,
+		maxEntries: maxEntries,
+		serializer: defaultSerializer,
+		now:        time.Now,
+	}, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := c.serializer.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setLocked(key, data, expiration)
+	return nil
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string, dest interface{}) error {
+	c.mu.Lock()
+	entry, ok := c.getLocked(key)
+	var data []byte
+	if ok {
+		data = entry.value
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return ErrMiss
+	}
+	return c.serializer.Unmarshal(data, dest)
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.items[key]; ok {
+		c.removeLocked(entry)
+	}
+	return nil
+}
+
+// Incr 与 Redis INCR 语义一致：key 不存在时从 0 开始，保留原有的过期时间
+func (c *MemoryCache) Incr(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int64
+	var ttl time.Duration
+	if entry, ok := c.getLocked(key); ok {
+		if err := c.serializer.Unmarshal(entry.value, &n); err != nil {
+			return 0, fmt.Errorf("value of %q is not an integer", key)
+		}
+		if !entry.expiresAt.IsZero() {
+			ttl = entry.expiresAt.Sub(c.now())
+		}
+	}
+
+	n++
+	c.setLocked(key, []byte(strconv.FormatInt(n, 10)), ttl)
+	return n, nil
+}
+
+// SetCounterNX 与 Redis SET NX 语义一致：只在 key 不存在时将其设为整数 value，返回是否写入
+func (c *MemoryCache) SetCounterNX(ctx context.Context, key string, value int64) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.getLocked(key); ok {
+		return false, nil
+	}
+	c.setLocked(key, []byte(strconv.FormatInt(value, 10)), 0)
+	return true, nil
+}
+
+// DeleteByPrefix 删除所有以 prefix 开头的 key，返回删除的数量
+func (c *MemoryCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var deleted int64
+	for key, entry := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeLocked(entry)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// SetSerializer 设置写入时使用的 Serializer，进程内缓存通常不需要压缩
+func (c *MemoryCache) SetSerializer(s *Serializer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serializer = s
+}
+
+// Serializer 返回写入时使用的 Serializer
+func (c *MemoryCache) Serializer() *Serializer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.serializer
+}
+
+// OnEvict 设置条目因容量不足被淘汰时的回调，回调在持有锁时执行，不能访问缓存
+func (c *MemoryCache) OnEvict(fn func(key string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = fn
+}
+
+// Inspect 实现 Inspector，不影响淘汰顺序
+func (c *MemoryCache) Inspect(ctx context.Context, key string) (*KeyInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.items[key]
+	now := c.now()
+	if !ok || entry.expired(now) {
+		return nil, ErrMiss
+	}
+
+	var ttl time.Duration
+	if !entry.expiresAt.IsZero() {
+		ttl = entry.expiresAt.Sub(now)
+	}
+	return newKeyInfo(key, entry.value, ttl), nil
+}
+
+// Len 返回当前的条目数量（包含尚未被清理的过期条目）
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Flush 清空所有条目
+func (c *MemoryCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, entry := range c.items {
+		c.removeLocked(entry)
+	}
+}
+
+func (c *MemoryCache) getLocked(key string) (*memoryEntry, bool) {
+	entry, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if entry.expired(c.now()) {
+		c.removeLocked(entry)
+		return nil, false
+	}
+	c.evictor.touch(entry)
+	return entry, true
+}
+
+func (c *MemoryCache) setLocked(key string, data []byte, expiration time.Duration) {
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = c.now().Add(expiration)
+	}
+
+	if entry, ok := c.items[key]; ok {
+		entry.value = data
+		entry.expiresAt = expiresAt
+		c.evictor.touch(entry)
+		return
+	}
+
+	for c.maxEntries > 0 && len(c.items) >= c.maxEntries {
+		victim := c.evictor.victim()
+		c.removeLocked(victim)
+		if c.onEvict != nil {
+			c.onEvict(victim.key)
+		}
+	}
+
+	entry := &memoryEntry{key: key, value: data, expiresAt: expiresAt}
+	c.items[key] = entry
+	c.evictor.add(entry)
+}
+
+func (c *MemoryCache) removeLocked(entry *memoryEntry) {
+	delete(c.items, entry.key)
+	c.evictor.remove(entry)
+}
+
+// evictor 维护淘汰顺序，所有方法都在 MemoryCache.mu 保护下调用
+type evictor interface {
+	add(e *memoryEntry)
+	touch(e *memoryEntry)
+	remove(e *memoryEntry)
+	victim() *memoryEntry
+}
+
+// lruEvictor 淘汰最久未被访问的条目
+type lruEvictor struct {
+	ll *list.List
+}
+
+func (l *lruEvictor) add(e *memoryEntry) {
+	e.elem = l.ll.PushFront(e)
+}
+
+func (l *lruEvictor) touch(e *memoryEntry) {
+	l.ll.MoveToFront(e.elem)
+}
+
+func (l *lruEvictor) remove(e *memoryEntry) {
+	l.ll.Remove(e.elem)
+}
+
+func (l *lruEvictor) victim() *memoryEntry {
+	return l.ll.Back().Value.(*memoryEntry)
+}
+
+// lfuEvictor 淘汰访问次数最少的条目，次数相同时淘汰最久未被访问的
+type lfuEvictor struct {
+	entries []*memoryEntry
+	clock   uint64
+}
+
+func (l *lfuEvictor) add(e *memoryEntry) {
+	l.clock++
+	e.freq = 1
+	e.lastUsed = l.clock
+	heap.Push(l, e)
+}
+
+func (l *lfuEvictor) touch(e *memoryEntry) {
+	l.clock++
+	e.freq++
+	e.lastUsed = l.clock
+	heap.Fix(l, e.index)
+}
+
+func (l *lfuEvictor) remove(e *memoryEntry) {
+	heap.Remove(l, e.index)
+}
+
+func (l *lfuEvictor) victim() *memoryEntry {
+	return l.entries[0]
+}
+
+// 以下方法实现 heap.Interface
+func (l *lfuEvictor) Len() int { return len(l.entries) }
+
+func (l *lfuEvictor) Less(i, j int) bool {
+	if l.entries[i].freq != l.entries[j].freq {
+		return l.entries[i].freq < l.entries[j].freq
+	}
+	return l.entries[i].lastUsed < l.entries[j].lastUsed
+}
+
+func (l *lfuEvictor) Swap(i, j int) {
+	l.entries[i], l.entries[j] = l.entries[j], l.entries[i]
+	l.entries[i].index = i
+	l.entries[j].index = j
+}
+
+func (l *lfuEvictor) Push(x interface{}) {
+	e := x.(*memoryEntry)
+	e.index = len(l.entries)
+	l.entries = append(l.entries, e)
+}
+
+func (l *lfuEvictor) Pop() interface{} {
+	n := len(l.entries)
+	e := l.entries[n-1]
+	l.entries[n-1] = nil
+	l.entries = l.entries[:n-1]
+	return e
+}
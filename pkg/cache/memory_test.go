@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCacheLRUEviction(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemoryCache(2, EvictionLRU)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Set(ctx, "a", 1, 0))
+	require.NoError(t, c.Set(ctx, "b", 2, 0))
+
+	// 访问 a 之后 b 成为最久未使用的条目
+	var v int
+	require.NoError(t, c.Get(ctx, "a", &v))
+	require.NoError(t, c.Set(ctx, "c", 3, 0))
+
+	assert.ErrorIs(t, c.Get(ctx, "b", &v), ErrMiss)
+	assert.NoError(t, c.Get(ctx, "a", &v))
+	assert.NoError(t, c.Get(ctx, "c", &v))
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestMemoryCacheLFUEviction(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemoryCache(2, EvictionLFU)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Set(ctx, "a", 1, 0))
+	require.NoError(t, c.Set(ctx, "b", 2, 0))
+
+	var v int
+	for i := 0; i < 3; i++ {
+		require.NoError(t, c.Get(ctx, "a", &v))
+	}
+	require.NoError(t, c.Get(ctx, "b", &v))
+
+	// b 的访问次数更少，即使最近刚被访问也会被淘汰
+	require.NoError(t, c.Set(ctx, "c", 3, 0))
+
+	assert.ErrorIs(t, c.Get(ctx, "b", &v), ErrMiss)
+	assert.NoError(t, c.Get(ctx, "a", &v))
+	assert.NoError(t, c.Get(ctx, "c", &v))
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemoryCache(0, EvictionLRU)
+	require.NoError(t, err)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	require.NoError(t, c.Set(ctx, "k", "v", time.Minute))
+
+	var v string
+	require.NoError(t, c.Get(ctx, "k", &v))
+	assert.Equal(t, "v", v)
+
+	now = now.Add(time.Minute)
+	assert.ErrorIs(t, c.Get(ctx, "k", &v), ErrMiss)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestMemoryCacheIncr(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemoryCache(0, EvictionLRU)
+	require.NoError(t, err)
+
+	n, err := c.Incr(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = c.Incr(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	var v int64
+	require.NoError(t, c.Get(ctx, "counter", &v))
+	assert.Equal(t, int64(2), v)
+
+	require.NoError(t, c.Set(ctx, "text", "abc", 0))
+	_, err = c.Incr(ctx, "text")
+	assert.Error(t, err)
+}
+
+func TestTieredCache(t *testing.T) {
+	ctx := context.Background()
+	l1, err := NewMemoryCache(10, EvictionLRU)
+	require.NoError(t, err)
+	l2, err := NewMemoryCache(10, EvictionLRU)
+	require.NoError(t, err)
+	c := NewTieredCache(l1, l2, time.Minute, nil)
+
+	require.NoError(t, c.Set(ctx, "k", "v1", time.Hour))
+	assert.Equal(t, 1, l1.Len())
+	assert.Equal(t, 1, l2.Len())
+
+	// L1 未命中时从 L2 读取并回填 L1
+	require.NoError(t, l1.Delete(ctx, "k"))
+	var v string
+	require.NoError(t, c.Get(ctx, "k", &v))
+	assert.Equal(t, "v1", v)
+	assert.Equal(t, 1, l1.Len())
+
+	require.NoError(t, c.Delete(ctx, "k"))
+	assert.ErrorIs(t, c.Get(ctx, "k", &v), ErrMiss)
+
+	n, err := c.Incr(ctx, "ver")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	var ver int64
+	require.NoError(t, l1.Get(ctx, "ver", &ver))
+	assert.Equal(t, int64(1), ver)
+}
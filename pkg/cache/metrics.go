@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMetrics 收集 NewCache 创建的缓存和所有 Loader 的统计数据，
+// 实现了 expvar.Var，可以通过 expvar.Publish 导出
+var DefaultMetrics = NewMetrics()
+
+// Metrics 按 key 的命名空间（第一个 ":" 之前的部分，例如 user、tag）统计缓存的使用情况
+type Metrics struct {
+	mu         sync.RWMutex
+	namespaces map[string]*namespaceStats
+}
+
+type namespaceStats struct {
+	hits       atomic.Int64
+	misses     atomic.Int64
+	errors     atomic.Int64
+	evictions  atomic.Int64
+	loads      atomic.Int64
+	loadErrors atomic.Int64
+	loadNanos  atomic.Int64
+}
+
+// NamespaceStats 是某个命名空间的统计快照
+type NamespaceStats struct {
+	Hits       int64   `json:"hits"`
+	Misses     int64   `json:"misses"`
+	Errors     int64   `json:"errors"`
+	Evictions  int64   `json:"evictions"`
+	Loads      int64   `json:"loads"`
+	LoadErrors int64   `json:"load_errors"`
+	LoadTimeMs float64 `json:"load_time_ms"` // 加载耗时总和
+	HitRatio   float64 `json:"hit_ratio"`
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{namespaces: make(map[string]*namespaceStats)}
+}
+
+// Namespace 返回 key 所属的命名空间
+func Namespace(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+func (m *Metrics) stats(key string) *namespaceStats {
+	ns := Namespace(key)
+
+	m.mu.RLock()
+	s, ok := m.namespaces[ns]
+	m.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok = m.namespaces[ns]; !ok {
+		s = &namespaceStats{}
+		m.namespaces[ns] = s
+	}
+	return s
+}
+
+func (m *Metrics) Hit(key string)   { m.stats(key).hits.Add(1) }
+func (m *Metrics) Miss(key string)  { m.stats(key).misses.Add(1) }
+func (m *Metrics) Error(key string) { m.stats(key).errors.Add(1) }
+func (m *Metrics) Evict(key string) { m.stats(key).evictions.Add(1) }
+
+// Load 记录一次回源加载及其耗时
+func (m *Metrics) Load(key string, d time.Duration, err error) {
+	s := m.stats(key)
+	s.loads.Add(1)
+	s.loadNanos.Add(int64(d))
+	if err != nil {
+		s.loadErrors.Add(1)
+	}
+}
+
+// Snapshot 返回各命名空间的统计快照
+func (m *Metrics) Snapshot() map[string]NamespaceStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := make(map[string]NamespaceStats, len(m.namespaces))
+	for ns, s := range m.namespaces {
+		stats := NamespaceStats{
+			Hits:       s.hits.Load(),
+			Misses:     s.misses.Load(),
+			Errors:     s.errors.Load(),
+			Evictions:  s.evictions.Load(),
+			Loads:      s.loads.Load(),
+			LoadErrors: s.loadErrors.Load(),
+			LoadTimeMs: float64(s.loadNanos.Load()) / float64(time.Millisecond),
+		}
+		if lookups := stats.Hits + stats.Misses; lookups > 0 {
+			stats.HitRatio = float64(stats.Hits) / float64(lookups)
+		}
+		snapshot[ns] = stats
+	}
+	return snapshot
+}
+
+// String 实现 expvar.Var
+func (m *Metrics) String() string {
+	data, _ := json.Marshal(m.Snapshot())
+	return string(data)
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedCacheSeparatesMissesFromErrors(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	metrics := NewMetrics()
+	c := NewInstrumentedCache(NewRedisCache(s.Addr(), "", 0), metrics)
+
+	var v string
+	require.NoError(t, c.Set(ctx, "user:1:profile", "alice", 0))
+	require.NoError(t, c.Get(ctx, "user:1:profile", &v))
+	assert.ErrorIs(t, c.Get(ctx, "user:2:profile", &v), ErrMiss)
+
+	s.SetError("connection refused")
+	err := c.Get(ctx, "user:1:profile", &v)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrMiss))
+	s.SetError("")
+
+	stats := metrics.Snapshot()["user"]
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, int64(1), stats.Errors)
+	assert.Equal(t, 0.5, stats.HitRatio)
+}
+
+func TestMetricsEvictionsAndLoads(t *testing.T) {
+	ctx := context.Background()
+	metrics := NewMetrics()
+	c, err := NewMemoryCache(1, EvictionLRU)
+	require.NoError(t, err)
+	c.OnEvict(metrics.Evict)
+
+	require.NoError(t, c.Set(ctx, "user:1", 1, 0))
+	require.NoError(t, c.Set(ctx, "user:2", 2, 0))
+
+	loader := NewLoader(c, LoaderOptions{Metrics: metrics})
+	var v int
+	require.NoError(t, loader.GetOrLoad(ctx, "user:3", time.Minute, &v, func(ctx context.Context) (interface{}, error) {
+		return 3, nil
+	}))
+	assert.Error(t, loader.GetOrLoad(ctx, "user:4", time.Minute, &v, func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("db down")
+	}))
+
+	stats := metrics.Snapshot()["user"]
+	assert.Equal(t, int64(2), stats.Evictions)
+	assert.Equal(t, int64(2), stats.Loads)
+	assert.Equal(t, int64(1), stats.LoadErrors)
+	assert.Contains(t, metrics.String(), `"evictions":2`)
+}
+
+func TestLoaderSkipsStoreWhenCacheUnavailable(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	loader := NewLoader(NewRedisCache(s.Addr(), "", 0), LoaderOptions{Metrics: NewMetrics()})
+
+	s.SetError("connection refused")
+	var v string
+	require.NoError(t, loader.GetOrLoad(ctx, "user:1", time.Minute, &v, func(ctx context.Context) (interface{}, error) {
+		return "alice", nil
+	}))
+	assert.Equal(t, "alice", v)
+	s.SetError("")
+	assert.False(t, s.Exists("user:1"))
+}
+
+func TestInspect(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	l1, err := NewMemoryCache(0, EvictionLRU)
+	require.NoError(t, err)
+	c := NewPrefixedCache(NewTieredCache(l1, NewRedisCache(s.Addr(), "", 0), time.Minute, nil), "app:")
+
+	loader := NewLoader(c, LoaderOptions{Metrics: NewMetrics()})
+	require.NoError(t, loader.Store(ctx, "user:1:profile", time.Hour, map[string]interface{}{"name": "alice"}))
+
+	info, err := Inspect(ctx, c, "user:1:profile")
+	require.NoError(t, err)
+	assert.Equal(t, "user:1:profile", info.Key)
+	assert.Equal(t, CodecJSON, info.Codec)
+	assert.Equal(t, CompressionNone, info.Compression)
+	assert.Greater(t, info.TTLMs, int64(0))
+	require.NotNil(t, info.InL1)
+	assert.True(t, *info.InL1)
+
+	value, err := info.Value()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "alice"}, value.(map[string]interface{})["value"])
+
+	_, err = Inspect(ctx, c, "user:2:profile")
+	assert.ErrorIs(t, err, ErrMiss)
+}
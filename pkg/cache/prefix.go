@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// PrefixedCache 为所有 key 加上全局前缀（例如 应用名:环境:版本），
+// 使不同环境或版本共用同一个 Redis 时互不干扰，发布新版本时可以按前缀清理旧数据
+type PrefixedCache struct {
+	cache  RedisCacheInterface
+	prefix string
+}
+
+func NewPrefixedCache(cache RedisCacheInterface, prefix string) *PrefixedCache {
+	return &PrefixedCache{cache: cache, prefix: prefix}
+}
+
+func (c *PrefixedCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.cache.Set(ctx, c.prefix+key, value, expiration)
+}
+
+func (c *PrefixedCache) Get(ctx context.Context, key string, dest interface{}) error {
+	return c.cache.Get(ctx, c.prefix+key, dest)
+}
+
+func (c *PrefixedCache) Delete(ctx context.Context, key string) error {
+	return c.cache.Delete(ctx, c.prefix+key)
+}
+
+func (c *PrefixedCache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.cache.Incr(ctx, c.prefix+key)
+}
+
+func (c *PrefixedCache) SetCounterNX(ctx context.Context, key string, value int64) (bool, error) {
+	return c.cache.SetCounterNX(ctx, c.prefix+key, value)
+}
+
+// DeleteByPrefix 只删除全局前缀下的 key，prefix 为空时清空整个命名空间
+func (c *PrefixedCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	return c.cache.DeleteByPrefix(ctx, c.prefix+prefix)
+}
+
+// Inspect 实现 Inspector，返回的 KeyInfo.Key 不包含全局前缀
+func (c *PrefixedCache) Inspect(ctx context.Context, key string) (*KeyInfo, error) {
+	info, err := Inspect(ctx, c.cache, c.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	info.Key = key
+	return info, nil
+}
+
+// Serializer 返回被包装的缓存写入时使用的 Serializer
+func (c *PrefixedCache) Serializer() *Serializer {
+	return serializerOf(c.cache)
+}
+
+// Close 关闭被包装的缓存
+func (c *PrefixedCache) Close() error {
+	if closer, ok := c.cache.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
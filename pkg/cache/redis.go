@@ -2,36 +2,52 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisCache struct {
-	client *redis.Client
+	client     redis.UniversalClient
+	serializer *Serializer
+	ownsClient bool // 由 NewRedisCache 创建的客户端在 Close 时关闭
 }
 
 type RedisCacheInterface interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	Get(ctx context.Context, key string, dest interface{}) error
 	Delete(ctx context.Context, key string) error
+	Incr(ctx context.Context, key string) (int64, error)
+	SetCounterNX(ctx context.Context, key string, value int64) (bool, error)
+	DeleteByPrefix(ctx context.Context, prefix string) (int64, error)
 }
 
+const scanBatchSize = 1000
+
+// NewRedisCache 使用默认选项连接单机 Redis，主要用于命令行工具和测试
 func NewRedisCache(addr, password string, db int) *RedisCache {
-	client := redis.NewClient(&redis.Options{
+	c := NewRedisCacheWithClient(redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       db,
-	})
+	}))
+	c.ownsClient = true
+	return c
+}
 
+// NewRedisCacheWithClient 使用已有的客户端（见 NewRedisClient），Close 不会关闭该客户端
+func NewRedisCacheWithClient(client redis.UniversalClient) *RedisCache {
 	return &RedisCache{
-		client: client,
+		client:     client,
+		serializer: defaultSerializer,
 	}
 }
 
 func (c *RedisCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	data, err := json.Marshal(value)
+	data, err := c.serializer.Marshal(value)
 	if err != nil {
 		return err
 	}
@@ -41,13 +57,147 @@ func (c *RedisCache) Set(ctx context.Context, key string, value interface{}, exp
 
 func (c *RedisCache) Get(ctx context.Context, key string, dest interface{}) error {
 	data, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return ErrMiss
+	}
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(data, dest)
+	return c.serializer.Unmarshal(data, dest)
 }
 
 func (c *RedisCache) Delete(ctx context.Context, key string) error {
 	return c.client.Del(ctx, key).Err()
 }
+
+// Incr 原子地将 key 的整数值加一并返回新值，key 不存在时从 0 开始
+func (c *RedisCache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.client.Incr(ctx, key).Result()
+}
+
+// SetCounterNX 只在 key 不存在时将其设为整数 value（不过期），返回是否写入，之后可以用 Incr 自增
+func (c *RedisCache) SetCounterNX(ctx context.Context, key string, value int64) (bool, error) {
+	return c.client.SetNX(ctx, key, value, 0).Result()
+}
+
+// DeleteByPrefix 删除所有以 prefix 开头的 key，返回删除的数量。
+// 使用 SCAN 分批遍历而不是 KEYS，避免阻塞 Redis；Cluster 模式下逐个遍历主节点
+func (c *RedisCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	pattern := escapeGlob(prefix) + "*"
+
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return deleteByPattern(ctx, c.client, pattern, true)
+	}
+
+	var deleted atomic.Int64
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		// 同一节点上的 key 可能属于不同的 slot，不能合并为一条 UNLINK
+		n, err := deleteByPattern(ctx, node, pattern, false)
+		deleted.Add(n)
+		return err
+	})
+	return deleted.Load(), err
+}
+
+// deleteByPattern 删除单个节点上匹配 pattern 的 key。边扫描边删除时部分实现的游标会跳过 key，
+// 因此重复扫描直到一轮没有可删除的 key
+func deleteByPattern(ctx context.Context, client redis.Cmdable, pattern string, multiKey bool) (int64, error) {
+	var deleted int64
+	for {
+		n, err := deletePass(ctx, client, pattern, multiKey)
+		deleted += n
+		if err != nil || n == 0 {
+			return deleted, err
+		}
+	}
+}
+
+func deletePass(ctx context.Context, client redis.Cmdable, pattern string, multiKey bool) (int64, error) {
+	var deleted int64
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, pattern, scanBatchSize).Result()
+		if err != nil {
+			return deleted, err
+		}
+		if len(keys) > 0 {
+			n, err := unlink(ctx, client, keys, multiKey)
+			deleted += n
+			if err != nil {
+				return deleted, err
+			}
+		}
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+func unlink(ctx context.Context, client redis.Cmdable, keys []string, multiKey bool) (int64, error) {
+	if multiKey {
+		return client.Unlink(ctx, keys...).Result()
+	}
+
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Unlink(ctx, key)
+		}
+		return nil
+	})
+	var deleted int64
+	for _, cmd := range cmds {
+		deleted += cmd.Val()
+	}
+	return deleted, err
+}
+
+// escapeGlob 转义 Redis glob 模式中的特殊字符
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// Inspect 实现 Inspector
+func (c *RedisCache) Inspect(ctx context.Context, key string) (*KeyInfo, error) {
+	pipe := c.client.Pipeline()
+	get := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	data, err := get.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newKeyInfo(key, data, ttl.Val()), nil
+}
+
+// SetSerializer 设置写入时使用的 Serializer，不影响已有数据的读取
+func (c *RedisCache) SetSerializer(s *Serializer) {
+	c.serializer = s
+}
+
+// Serializer 返回写入时使用的 Serializer
+func (c *RedisCache) Serializer() *Serializer {
+	return c.serializer
+}
+
+// Client 返回底层的 Redis 客户端
+func (c *RedisCache) Client() redis.UniversalClient {
+	return c.client
+}
+
+// Close 关闭由 NewRedisCache 创建的客户端
+func (c *RedisCache) Close() error {
+	if !c.ownsClient {
+		return nil
+	}
+	return c.client.Close()
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RedisModeSingle   = "single"
+	RedisModeSentinel = "sentinel"
+	RedisModeCluster  = "cluster"
+)
+
+// NewRedisClient 根据配置创建 Redis 客户端（单机、Sentinel 或 Cluster），
+// 并接入熔断器：Redis 不可用时命令直接返回 ErrCircuitOpen，而不是等待超时。
+// 客户端不会主动建立连接，可以使用 Ping 检查连通性
+func NewRedisClient(cfg config.RedisConfig) (redis.UniversalClient, *CircuitBreaker, error) {
+	tlsConfig, err := newRedisTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var client redis.UniversalClient
+	switch cfg.Mode {
+	case "", RedisModeSingle:
+		client = redis.NewClient(&redis.Options{
+			Addr:         cfg.Addr,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			DB:           cfg.DB,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			PoolTimeout:  cfg.PoolTimeout,
+			TLSConfig:    tlsConfig,
+		})
+	case RedisModeSentinel:
+		if cfg.MasterName == "" || len(cfg.Addrs) == 0 {
+			return nil, nil, fmt.Errorf("redis sentinel mode requires master_name and addrs")
+		}
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.Addrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			DialTimeout:      cfg.DialTimeout,
+			ReadTimeout:      cfg.ReadTimeout,
+			WriteTimeout:     cfg.WriteTimeout,
+			PoolSize:         cfg.PoolSize,
+			MinIdleConns:     cfg.MinIdleConns,
+			PoolTimeout:      cfg.PoolTimeout,
+			TLSConfig:        tlsConfig,
+		})
+	case RedisModeCluster:
+		if len(cfg.Addrs) == 0 {
+			return nil, nil, fmt.Errorf("redis cluster mode requires addrs")
+		}
+		if cfg.DB != 0 {
+			return nil, nil, fmt.Errorf("redis cluster mode does not support db %d", cfg.DB)
+		}
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.Addrs,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			PoolTimeout:  cfg.PoolTimeout,
+			TLSConfig:    tlsConfig,
+		})
+	default:
+		return nil, nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
+	}
+
+	breaker := NewCircuitBreaker(cfg.CircuitBreaker.FailureThreshold, cfg.CircuitBreaker.OpenTimeout)
+	client.AddHook(breakerHook{breaker: breaker})
+	return client, breaker, nil
+}
+
+// Ping 检查 Redis 连通性，失败时立即熔断，避免启动后的请求逐个等待超时
+func Ping(ctx context.Context, client redis.UniversalClient, breaker *CircuitBreaker) error {
+	if err := client.Ping(ctx).Err(); err != nil {
+		breaker.Trip()
+		return err
+	}
+	return nil
+}
+
+func newRedisTLSConfig(cfg config.RedisTLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read redis ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in redis ca file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// TieredCache 在共享缓存 (L2, 通常是 Redis) 前面加一层进程内缓存 (L1)。
+// 覆盖或删除 key 时通过 publisher 通知其他实例删除各自 L1 中的 key；
+// L1 的过期时间不超过 l1TTL，用于兜底失效消息丢失时读到旧数据的时间窗口
+type TieredCache struct {
+	l1        *MemoryCache
+	l2        RedisCacheInterface
+	l1TTL     time.Duration
+	publisher Publisher
+	stop      context.CancelFunc
+
+	// generation 在每次收到失效消息时自增，用于避免把失效前从 L2 读到的旧值回填到 L1
+	generation atomic.Uint64
+}
+
+// NewTieredCache 创建两级缓存，publisher 为 nil 时不广播失效消息（单实例部署）
+func NewTieredCache(l1 *MemoryCache, l2 RedisCacheInterface, l1TTL time.Duration, publisher Publisher) *TieredCache {
+	return &TieredCache{
+		l1:        l1,
+		l2:        l2,
+		l1TTL:     l1TTL,
+		publisher: publisher,
+	}
+}
+
+// StartInvalidation 在后台订阅其他实例发布的失效消息，Close 时停止
+func (c *TieredCache) StartInvalidation(inv *Invalidator) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stop = cancel
+	go inv.Run(ctx, c)
+}
+
+// Evict 实现 InvalidationTarget，删除 L1 中的 keys
+func (c *TieredCache) Evict(ctx context.Context, keys ...string) {
+	c.generation.Add(1)
+	for _, key := range keys {
+		c.l1.Delete(ctx, key)
+	}
+}
+
+// EvictPrefix 实现 InvalidationTarget，删除 L1 中以 prefix 开头的 key
+func (c *TieredCache) EvictPrefix(ctx context.Context, prefix string) {
+	c.generation.Add(1)
+	c.l1.DeleteByPrefix(ctx, prefix)
+}
+
+// EvictAll 实现 InvalidationTarget，清空 L1
+func (c *TieredCache) EvictAll() {
+	c.generation.Add(1)
+	c.l1.Flush()
+}
+
+// Inspect 实现 Inspector，返回 L2 中的数据并标明 L1 中是否有该 key
+func (c *TieredCache) Inspect(ctx context.Context, key string) (*KeyInfo, error) {
+	info, err := Inspect(ctx, c.l2, key)
+	if err != nil {
+		return nil, err
+	}
+	_, l1Err := c.l1.Inspect(ctx, key)
+	inL1 := l1Err == nil
+	info.InL1 = &inL1
+	return info, nil
+}
+
+// Serializer 返回 L2 写入时使用的 Serializer
+func (c *TieredCache) Serializer() *Serializer {
+	return serializerOf(c.l2)
+}
+
+// Close 停止订阅失效消息
+func (c *TieredCache) Close() error {
+	if c.stop != nil {
+		c.stop()
+	}
+	return nil
+}
+
+func (c *TieredCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if err := c.l2.Set(ctx, key, value, expiration); err != nil {
+		// L2 写入失败时 L1 中可能残留旧值
+		c.l1.Delete(ctx, key)
+		return err
+	}
+	c.publish(ctx, key)
+	return c.l1.Set(ctx, key, value, c.l1Expiration(expiration))
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string, dest interface{}) error {
+	if err := c.l1.Get(ctx, key, dest); err == nil {
+		return nil
+	}
+
+	generation := c.generation.Load()
+	if err := c.l2.Get(ctx, key, dest); err != nil {
+		return err
+	}
+
+	// 读取 L2 期间收到过失效消息时不回填，dest 是指针，序列化结果与原值一致
+	if c.generation.Load() != generation {
+		return nil
+	}
+	return c.l1.Set(ctx, key, dest, c.l1TTL)
+}
+
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	c.l1.Delete(ctx, key)
+	if err := c.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	c.publish(ctx, key)
+	return nil
+}
+
+func (c *TieredCache) Incr(ctx context.Context, key string) (int64, error) {
+	n, err := c.l2.Incr(ctx, key)
+	if err != nil {
+		c.l1.Delete(ctx, key)
+		return 0, err
+	}
+	c.publish(ctx, key)
+	return n, c.l1.Set(ctx, key, n, c.l1TTL)
+}
+
+func (c *TieredCache) SetCounterNX(ctx context.Context, key string, value int64) (bool, error) {
+	ok, err := c.l2.SetCounterNX(ctx, key, value)
+	if err != nil || !ok {
+		// 未写入时以 L2 中已有的值为准
+		c.l1.Delete(ctx, key)
+		return false, err
+	}
+	c.publish(ctx, key)
+	return true, c.l1.Set(ctx, key, value, c.l1TTL)
+}
+
+func (c *TieredCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	c.l1.DeleteByPrefix(ctx, prefix)
+	n, err := c.l2.DeleteByPrefix(ctx, prefix)
+	if err != nil {
+		return n, err
+	}
+	if c.publisher != nil {
+		if err := c.publisher.PublishPrefix(ctx, prefix); err != nil {
+			logger.Logger.Warn("failed to publish cache invalidation", zap.String("prefix", prefix), zap.Error(err))
+		}
+	}
+	return n, nil
+}
+
+func (c *TieredCache) publish(ctx context.Context, key string) {
+	if c.publisher == nil {
+		return
+	}
+	if err := c.publisher.Publish(ctx, key); err != nil {
+		logger.Logger.Warn("failed to publish cache invalidation", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func (c *TieredCache) l1Expiration(expiration time.Duration) time.Duration {
+	if expiration <= 0 || expiration > c.l1TTL {
+		return c.l1TTL
+	}
+	return expiration
+}
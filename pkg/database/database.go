@@ -1,38 +1,229 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
-	"log"
+	"strings"
+	"time"
 
 	"github.com/jtsang4/go-stater/config"
-	"gorm.io/driver/mysql"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
-func InitDB(cfg config.DatabaseConfig) *gorm.DB {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local",
-		cfg.Username,
-		cfg.Password,
-		cfg.Host,
-		cfg.Port,
-		cfg.DBName,
-		cfg.Charset,
-	)
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+const (
+	defaultConnectBackoff = 500 * time.Millisecond
+	maxConnectBackoff     = 10 * time.Second
+)
+
+// InitDB 连接数据库并配置连接池。连接失败时按指数退避重试，直到超过 cfg.ConnectTimeout，
+// 避免数据库比应用晚几秒启动时启动失败；ConnectTimeout 为 0 时只尝试一次
+func InitDB(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	if err := validatePool(cfg); err != nil {
+		return nil, err
+	}
+	dialector, err := Dialector(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("configure database: %w", err)
+	}
+
+	ctx, cancel := connectContext(cfg)
+	defer cancel()
+
+	// TranslateError 将各数据库的唯一键冲突等错误转换为 gorm.ErrDuplicatedKey 等通用错误
+	db, err := openWithRetry(ctx, cfg, "primary", func() (*gorm.DB, error) {
+		return gorm.Open(dialector, &gorm.Config{TranslateError: true, Logger: NewGormLogger(cfg.Log)})
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return nil, err
 	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("Failed to get database instance: %v", err)
+		return nil, err
 	}
+	configurePool(sqlDB, cfg)
 
-	// Set connection pool settings
+	// SQLite 内存数据库的每个连接都是独立的数据库，只能使用一个连接，且连接关闭后数据丢失
+	if isSQLiteMemory(cfg) {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+
+	if len(cfg.Replicas.Nodes) > 0 {
+		resolver, err := newResolver(ctx, cfg)
+		if err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("connect to database replicas: %w", err)
+		}
+		if err := db.Use(resolver); err != nil {
+			resolver.Close()
+			sqlDB.Close()
+			return nil, fmt.Errorf("register database resolver: %w", err)
+		}
+	}
+
+	return db, nil
+}
+
+func configurePool(sqlDB *sql.DB, cfg config.DatabaseConfig) {
 	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
 	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
 	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
 
-	return db
+// validatePool 拒绝没有单位的时长：YAML 中的 3600 会被解析为 3600ns，连接几乎立即过期
+func validatePool(cfg config.DatabaseConfig) error {
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"conn_max_lifetime", cfg.ConnMaxLifetime},
+		{"conn_max_idle_time", cfg.ConnMaxIdleTime},
+	}
+	for _, d := range durations {
+		if d.value > 0 && d.value < time.Second {
+			return fmt.Errorf("database.%s is %s, use a duration with a unit such as 1h or 30m", d.name, d.value)
+		}
+	}
+	return nil
+}
+
+func connectContext(cfg config.DatabaseConfig) (context.Context, context.CancelFunc) {
+	if cfg.ConnectTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+}
+
+// openWithRetry 调用 open 直到成功或 ctx 结束，每次失败后等待的时间翻倍，最长 maxConnectBackoff。
+// ctx 没有截止时间时只尝试一次
+func openWithRetry(ctx context.Context, cfg config.DatabaseConfig, name string, open func() (*gorm.DB, error)) (*gorm.DB, error) {
+	backoff := cfg.ConnectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		db, err := open()
+		if err == nil {
+			return db, nil
+		}
+		// 连接失败时 gorm.Open 仍可能已创建连接池
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				sqlDB.Close()
+			}
+		}
+
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) < backoff {
+			return nil, fmt.Errorf("connect to %s database after %d attempt(s): %w", name, attempt, err)
+		}
+		logger.Logger.Warn("Failed to connect to database, retrying",
+			zap.String("database", name),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("connect to %s database after %d attempt(s): %w", name, attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// Close 关闭主库和从库连接
+func Close(db *gorm.DB) error {
+	if plugin, ok := db.Config.Plugins[resolverName]; ok {
+		plugin.(*Resolver).Close()
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+func newResolver(ctx context.Context, cfg config.DatabaseConfig) (*Resolver, error) {
+	policy, err := NewPolicy(cfg.Replicas.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]*Replica, 0, len(cfg.Replicas.Nodes))
+	for _, node := range cfg.Replicas.Nodes {
+		replicaCfg := replicaConfig(cfg, node)
+		dialector, err := Dialector(replicaCfg)
+		if err != nil {
+			return nil, err
+		}
+		name := replicaName(replicaCfg)
+		db, err := openWithRetry(ctx, cfg, name, func() (*gorm.DB, error) {
+			return gorm.Open(dialector, &gorm.Config{})
+		})
+		if err != nil {
+			closeReplicas(replicas)
+			return nil, err
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			closeReplicas(replicas)
+			return nil, err
+		}
+		configurePool(sqlDB, cfg)
+		replicas = append(replicas, NewReplica(name, sqlDB))
+	}
+
+	resolver := NewResolver(replicas, policy, cfg.Replicas.StickyWindow)
+	if cfg.Replicas.HealthCheckInterval > 0 {
+		resolver.StartHealthCheck(cfg.Replicas.HealthCheckInterval)
+	}
+	return resolver, nil
+}
+
+func closeReplicas(replicas []*Replica) {
+	for _, replica := range replicas {
+		replica.DB.Close()
+	}
+}
+
+// replicaConfig 用从库节点的配置覆盖主库配置
+func replicaConfig(cfg config.DatabaseConfig, node config.ReplicaNodeConfig) config.DatabaseConfig {
+	if node.Host != "" {
+		cfg.Host = node.Host
+	}
+	if node.Port != 0 {
+		cfg.Port = node.Port
+	}
+	if node.Username != "" {
+		cfg.Username = node.Username
+	}
+	if node.Password != "" {
+		cfg.Password = node.Password
+	}
+	if node.Path != "" {
+		cfg.SQLite.Path = node.Path
+	}
+	return cfg
+}
+
+func replicaName(cfg config.DatabaseConfig) string {
+	if NormalizeDriver(cfg.Driver) == DriverSQLite {
+		return cfg.SQLite.Path
+	}
+	return fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+}
+
+func isSQLiteMemory(cfg config.DatabaseConfig) bool {
+	if NormalizeDriver(cfg.Driver) != DriverSQLite {
+		return false
+	}
+	return cfg.SQLite.Path == "" || strings.Contains(cfg.SQLite.Path, ":memory:") || strings.Contains(cfg.SQLite.Path, "mode=memory")
 }
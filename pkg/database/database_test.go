@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestOpenWithRetry(t *testing.T) {
+	cfg := config.DatabaseConfig{Driver: DriverSQLite, ConnectBackoff: time.Millisecond}
+	refused := errors.New("connection refused")
+
+	attempts := 0
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	db, err := openWithRetry(ctx, cfg, "primary", func() (*gorm.DB, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, refused
+		}
+		return &gorm.DB{}, nil
+	})
+	require.NoError(t, err)
+	assert.NotNil(t, db)
+	assert.Equal(t, 3, attempts)
+
+	// 超过截止时间后返回最后一次的错误
+	attempts = 0
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = openWithRetry(ctx, cfg, "primary", func() (*gorm.DB, error) {
+		attempts++
+		return nil, refused
+	})
+	assert.ErrorIs(t, err, refused)
+	assert.Greater(t, attempts, 1)
+
+	// 没有截止时间时只尝试一次
+	attempts = 0
+	_, err = openWithRetry(context.Background(), cfg, "primary", func() (*gorm.DB, error) {
+		attempts++
+		return nil, refused
+	})
+	assert.ErrorIs(t, err, refused)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestInitDBRejectsDurationsWithoutUnit(t *testing.T) {
+	// YAML 中的 conn_max_lifetime: 3600 被解析为 3600ns
+	_, err := InitDB(config.DatabaseConfig{Driver: DriverSQLite, ConnMaxLifetime: 3600})
+	assert.ErrorContains(t, err, "conn_max_lifetime")
+
+	_, err = InitDB(config.DatabaseConfig{Driver: DriverSQLite, ConnMaxIdleTime: 600})
+	assert.ErrorContains(t, err, "conn_max_idle_time")
+
+	db, err := InitDB(config.DatabaseConfig{Driver: DriverSQLite, ConnMaxLifetime: time.Hour, ConnMaxIdleTime: 10 * time.Minute})
+	require.NoError(t, err)
+	Close(db)
+}
+
+func TestStatsExporter(t *testing.T) {
+	db, _ := newReplicatedDB(t)
+	exporter := NewStatsExporter(db)
+	exporter.Start(time.Hour)
+	defer exporter.Close()
+
+	snapshot := exporter.Snapshot()
+	require.Contains(t, snapshot, "primary")
+	assert.Len(t, snapshot, 2, "primary and replica")
+	assert.Contains(t, exporter.String(), `"in_use":`)
+
+	conn, err := db.DB()
+	require.NoError(t, err)
+	c, err := conn.Conn(context.Background())
+	require.NoError(t, err)
+	exporter.Collect()
+	assert.Equal(t, 1, exporter.Snapshot()["primary"].InUse)
+	c.Close()
+}
@@ -0,0 +1,62 @@
+// Package dbtest 为测试创建临时的 SQLite 文件数据库，无需 MySQL 或 PostgreSQL 即可运行
+package dbtest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// MigrateFunc 执行迁移，签名与 migrations.Run 相同
+type MigrateFunc func(ctx context.Context, db *gorm.DB, cfg config.DatabaseConfig) error
+
+// Config 返回位于 t.TempDir() 的 SQLite 数据库配置，开启 WAL 和外键约束
+func Config(t testing.TB) config.DatabaseConfig {
+	t.Helper()
+	return config.DatabaseConfig{
+		Driver: database.DriverSQLite,
+		SQLite: config.SQLiteConfig{
+			Path:        filepath.Join(t.TempDir(), "test.db"),
+			WAL:         true,
+			BusyTimeout: time.Second,
+			ForeignKeys: true,
+		},
+	}
+}
+
+// Open 按 cfg 打开数据库，测试结束时关闭
+func Open(t testing.TB, cfg config.DatabaseConfig) *gorm.DB {
+	t.Helper()
+	db, err := database.InitDB(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db
+}
+
+// New 创建临时数据库，并按 models 自动建表
+func New(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db := Open(t, Config(t))
+	if len(models) > 0 {
+		require.NoError(t, db.AutoMigrate(models...))
+	}
+	return db
+}
+
+// NewMigrated 创建临时数据库并执行 run，通常传入 migrations.Run
+func NewMigrated(t testing.TB, run MigrateFunc) *gorm.DB {
+	t.Helper()
+	cfg := Config(t)
+	db := Open(t, cfg)
+	require.NoError(t, run(context.Background(), db, cfg))
+	return db
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"github.com/jtsang4/go-stater/config"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// NormalizeDriver 将驱动别名转换为 DriverMySQL、DriverPostgres 或 DriverSQLite，空值视为 MySQL
+func NormalizeDriver(driver string) string {
+	switch driver {
+	case "":
+		return DriverMySQL
+	case "postgresql":
+		return DriverPostgres
+	case "sqlite3":
+		return DriverSQLite
+	default:
+		return driver
+	}
+}
+
+// Dialector 根据 cfg.Driver 返回对应的 GORM dialector
+func Dialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch NormalizeDriver(cfg.Driver) {
+	case DriverMySQL:
+		return mysql.Open(MySQLDSN(cfg)), nil
+	case DriverPostgres:
+		return postgres.Open(PostgresDSN(cfg)), nil
+	case DriverSQLite:
+		dsn, err := SQLiteDSN(cfg.SQLite)
+		if err != nil {
+			return nil, err
+		}
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+}
+
+func MySQLDSN(cfg config.DatabaseConfig) string {
+	charset := cfg.Charset
+	if charset == "" {
+		charset = "utf8mb4"
+	}
+	// clientFoundRows 使 UPDATE 的影响行数为匹配的行数（与其他数据库一致），
+	// 否则值未变化的更新会被误判为记录不存在或版本冲突
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local&clientFoundRows=true",
+		cfg.Username,
+		cfg.Password,
+		cfg.Host,
+		cfg.Port,
+		cfg.DBName,
+		charset,
+	)
+}
+
+// PostgresDSN 生成 key=value 格式的连接串，值中的空格和引号会被转义
+func PostgresDSN(cfg config.DatabaseConfig) string {
+	params := []struct{ key, value string }{
+		{"host", cfg.Host},
+		{"user", cfg.Username},
+		{"password", cfg.Password},
+		{"dbname", cfg.DBName},
+		{"sslmode", cfg.Postgres.SSLMode},
+		{"search_path", cfg.Postgres.SearchPath},
+		{"TimeZone", cfg.Postgres.TimeZone},
+	}
+	if cfg.Port != 0 {
+		params = append(params, struct{ key, value string }{"port", fmt.Sprint(cfg.Port)})
+	}
+
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		if p.value == "" {
+			continue
+		}
+		parts = append(parts, p.key+"="+quotePostgresValue(p.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+func quotePostgresValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// SQLiteDSN 生成 SQLite 连接串，通过 _pragma 参数设置 WAL、busy_timeout 和外键约束，
+// 并在需要时创建数据库文件所在的目录
+func SQLiteDSN(cfg config.SQLiteConfig) (string, error) {
+	path := cfg.Path
+	if path == "" {
+		path = ":memory:"
+	}
+
+	if path != ":memory:" && !strings.HasPrefix(path, "file:") {
+		if dir := filepath.Dir(path); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return "", fmt.Errorf("create sqlite directory: %w", err)
+			}
+		}
+	}
+
+	query := url.Values{}
+	if cfg.WAL {
+		query.Add("_pragma", "journal_mode(WAL)")
+	}
+	if cfg.BusyTimeout > 0 {
+		query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", cfg.BusyTimeout.Milliseconds()))
+	}
+	if cfg.ForeignKeys {
+		query.Add("_pragma", "foreign_keys(1)")
+	}
+
+	if len(query) == 0 {
+		return path, nil
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + query.Encode(), nil
+}
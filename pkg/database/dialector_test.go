@@ -0,0 +1,50 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	dsn := PostgresDSN(config.DatabaseConfig{
+		Host:     "localhost",
+		Port:     5432,
+		Username: "app",
+		Password: "it's secret",
+		DBName:   "app",
+		Postgres: config.PostgresConfig{SSLMode: "require", SearchPath: "app,public", TimeZone: "UTC"},
+	})
+	assert.Equal(t, `host=localhost user=app password='it\'s secret' dbname=app sslmode=require search_path=app,public TimeZone=UTC port=5432`, dsn)
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	dsn, err := SQLiteDSN(config.SQLiteConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, ":memory:", dsn)
+
+	path := filepath.Join(t.TempDir(), "data", "app.db")
+	dsn, err = SQLiteDSN(config.SQLiteConfig{Path: path, WAL: true, BusyTimeout: 5 * time.Second, ForeignKeys: true})
+	require.NoError(t, err)
+	assert.Equal(t, path+"?_pragma=journal_mode%28WAL%29&_pragma=busy_timeout%285000%29&_pragma=foreign_keys%281%29", dsn)
+
+	info, err := os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestDialector(t *testing.T) {
+	for _, driver := range []string{"", "mysql", "postgres", "postgresql", "sqlite", "sqlite3"} {
+		d, err := Dialector(config.DatabaseConfig{Driver: driver})
+		require.NoError(t, err, driver)
+		assert.NotNil(t, d)
+	}
+
+	_, err := Dialector(config.DatabaseConfig{Driver: "oracle"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const defaultSlowThreshold = 200 * time.Millisecond
+
+// callerSkipPrefixes 是查找调用位置时跳过的函数前缀：GORM 本身和 store 包的通用仓储，
+// 这样 caller 指向仓储或业务代码中发起查询的位置
+var callerSkipPrefixes = []string{
+	"gorm.io/",
+	path.Join(path.Dir(reflect.TypeOf(GormLogger{}).PkgPath()), "store") + ".",
+}
+
+// GormLogger 将 GORM 的日志写入 logger.Logger。
+// 查询出错时记录 Error，超过慢查询阈值时记录 Warn，级别为 info 时记录所有查询；
+// 日志包含调用位置、影响行数和 ctx 中的请求 ID。默认不记录参数值，SQL 中保留占位符
+type GormLogger struct {
+	level                gormlogger.LogLevel
+	slowThreshold        time.Duration
+	logParams            bool
+	ignoreRecordNotFound bool
+}
+
+// NewGormLogger 根据配置创建 GORM 日志，Level 为空时为 warn
+func NewGormLogger(cfg config.DBLogConfig) *GormLogger {
+	slow := cfg.SlowThreshold
+	if slow == 0 {
+		slow = defaultSlowThreshold
+	}
+	return &GormLogger{
+		level:                parseLogLevel(cfg.Level),
+		slowThreshold:        slow,
+		logParams:            cfg.LogParams,
+		ignoreRecordNotFound: !cfg.LogRecordNotFound,
+	}
+}
+
+func parseLogLevel(level string) gormlogger.LogLevel {
+	switch level {
+	case "silent":
+		return gormlogger.Silent
+	case "error":
+		return gormlogger.Error
+	case "info":
+		return gormlogger.Info
+	default:
+		return gormlogger.Warn
+	}
+}
+
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.logger(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.logger(ctx).Warn(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.logger(ctx).Error(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	failed := err != nil && !(l.ignoreRecordNotFound && errors.Is(err, gorm.ErrRecordNotFound))
+	slow := l.slowThreshold > 0 && elapsed > l.slowThreshold
+	switch {
+	case failed && l.level >= gormlogger.Error:
+	case slow && l.level >= gormlogger.Warn:
+	case l.level >= gormlogger.Info:
+	default:
+		return
+	}
+
+	sql, rows := fc()
+	fields := []zap.Field{
+		zap.String("sql", sql),
+		zap.Int64("rows", rows),
+		zap.Duration("elapsed", elapsed),
+		zap.String("caller", caller()),
+	}
+	log := l.logger(ctx)
+	switch {
+	case failed:
+		log.Error("database query failed", append(fields, zap.Error(err))...)
+	case slow:
+		log.Warn("slow query", append(fields, zap.Duration("threshold", l.slowThreshold))...)
+	default:
+		log.Info("database query", fields...)
+	}
+}
+
+// ParamsFilter 在未开启 LogParams 时去掉参数值，GORM 生成日志中的 SQL 前调用
+func (l *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.logParams {
+		return sql, params
+	}
+	return sql, nil
+}
+
+// logger 返回带请求 ID 的日志，调用位置由 caller 字段给出
+func (l *GormLogger) logger(ctx context.Context) *zap.Logger {
+	return logger.FromContext(ctx).WithOptions(zap.WithCaller(false))
+}
+
+// caller 返回第一个不属于 GORM 和通用数据访问封装的调用位置
+func caller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !skipCaller(frame.Function) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func skipCaller(function string) bool {
+	for _, prefix := range callerSkipPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
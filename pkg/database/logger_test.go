@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// observeLogs 将 logger.Logger 替换为记录日志的 Logger，测试结束后恢复
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zapcore.DebugLevel)
+	original := logger.Logger
+	logger.Logger = zap.New(core)
+	t.Cleanup(func() { logger.Logger = original })
+	return logs
+}
+
+func TestGormLogger(t *testing.T) {
+	logs := observeLogs(t)
+	db, err := InitDB(config.DatabaseConfig{Driver: DriverSQLite, Log: config.DBLogConfig{Level: "info"}})
+	require.NoError(t, err)
+	defer Close(db)
+	require.NoError(t, db.AutoMigrate(&item{}))
+	logs.TakeAll()
+
+	ctx := logger.WithRequestID(context.Background(), "req-1")
+	require.NoError(t, db.WithContext(ctx).Create(&item{Name: "secret-value"}).Error)
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	assert.Equal(t, "req-1", fields["request_id"])
+	assert.Equal(t, int64(1), fields["rows"])
+	assert.Contains(t, fields["caller"], "logger_test.go:")
+	assert.Contains(t, fields["sql"], "INSERT INTO")
+	assert.NotContains(t, fields["sql"], "secret-value", "parameters are redacted by default")
+
+	// ErrRecordNotFound 默认不作为错误
+	assert.ErrorIs(t, db.First(&item{}, 100).Error, gorm.ErrRecordNotFound)
+	assert.Empty(t, logs.FilterLevelExact(zapcore.ErrorLevel).All())
+
+	assert.Error(t, db.Exec("SELECT * FROM missing_table").Error)
+	failed := logs.FilterLevelExact(zapcore.ErrorLevel).All()
+	require.Len(t, failed, 1)
+	assert.Equal(t, "database query failed", failed[0].Message)
+}
+
+func TestGormLoggerLevels(t *testing.T) {
+	logs := observeLogs(t)
+	ctx := context.Background()
+	begin := time.Now().Add(-time.Second)
+	sql := func() (string, int64) { return "SELECT 1", 1 }
+
+	// warn 级别只记录慢查询和错误
+	l := NewGormLogger(config.DBLogConfig{SlowThreshold: 500 * time.Millisecond})
+	l.Trace(ctx, time.Now(), sql, nil)
+	assert.Zero(t, logs.Len())
+	l.Trace(ctx, begin, sql, nil)
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "slow query", logs.TakeAll()[0].Message)
+
+	// error 级别不记录慢查询
+	l = NewGormLogger(config.DBLogConfig{Level: "error", SlowThreshold: 500 * time.Millisecond})
+	l.Trace(ctx, begin, sql, nil)
+	assert.Zero(t, logs.Len())
+
+	// silent 级别什么都不记录
+	l.LogMode(gormlogger.Silent).(*GormLogger).Trace(ctx, begin, sql, assert.AnError)
+	assert.Zero(t, logs.Len())
+
+	// 开启 LogParams 时保留参数
+	_, params := NewGormLogger(config.DBLogConfig{LogParams: true}).ParamsFilter(ctx, "SELECT ?", 1)
+	assert.Equal(t, []interface{}{1}, params)
+	_, params = l.ParamsFilter(ctx, "SELECT ?", 1)
+	assert.Nil(t, params)
+}
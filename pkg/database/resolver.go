@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	PolicyRandom     = "random"
+	PolicyRoundRobin = "round_robin"
+	PolicyLeastConn  = "least_conn"
+)
+
+const resolverName = "database:resolver"
+
+// Replica 是一个只读从库
+type Replica struct {
+	Name    string
+	DB      *sql.DB
+	healthy atomic.Bool
+}
+
+func NewReplica(name string, db *sql.DB) *Replica {
+	r := &Replica{Name: name, DB: db}
+	r.healthy.Store(true)
+	return r
+}
+
+func (r *Replica) Healthy() bool {
+	return r.healthy.Load()
+}
+
+// Policy 从健康的从库中选择一个，replicas 不为空
+type Policy interface {
+	Pick(replicas []*Replica) *Replica
+}
+
+type PolicyFunc func(replicas []*Replica) *Replica
+
+func (f PolicyFunc) Pick(replicas []*Replica) *Replica {
+	return f(replicas)
+}
+
+// NewPolicy 根据名称创建负载均衡策略，空值为 round_robin
+func NewPolicy(name string) (Policy, error) {
+	switch name {
+	case "", PolicyRoundRobin:
+		var next atomic.Uint64
+		return PolicyFunc(func(replicas []*Replica) *Replica {
+			return replicas[(next.Add(1)-1)%uint64(len(replicas))]
+		}), nil
+	case PolicyRandom:
+		return PolicyFunc(func(replicas []*Replica) *Replica {
+			return replicas[rand.Intn(len(replicas))]
+		}), nil
+	case PolicyLeastConn:
+		return PolicyFunc(func(replicas []*Replica) *Replica {
+			best, bestInUse := replicas[0], replicas[0].DB.Stats().InUse
+			for _, r := range replicas[1:] {
+				if inUse := r.DB.Stats().InUse; inUse < bestInUse {
+					best, bestInUse = r, inUse
+				}
+			}
+			return best
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown replica policy %q", name)
+	}
+}
+
+// Resolver 是 GORM 插件，将读请求路由到健康的从库，写请求、事务和加锁读使用主库。
+// 没有健康的从库时读请求回退到主库
+type Resolver struct {
+	replicas     []*Replica
+	policy       Policy
+	stickyWindow time.Duration
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewResolver(replicas []*Replica, policy Policy, stickyWindow time.Duration) *Resolver {
+	return &Resolver{
+		replicas:     replicas,
+		policy:       policy,
+		stickyWindow: stickyWindow,
+		stop:         make(chan struct{}),
+	}
+}
+
+func (r *Resolver) Name() string {
+	return resolverName
+}
+
+// Initialize 实现 gorm.Plugin
+func (r *Resolver) Initialize(db *gorm.DB) error {
+	callbacks := []error{
+		db.Callback().Query().Before("gorm:query").Register("database:route_read", r.routeRead),
+		db.Callback().Row().Before("gorm:row").Register("database:route_read", r.routeRead),
+		db.Callback().Raw().Before("gorm:raw").Register("database:route_read", r.routeRead),
+		db.Callback().Create().After("gorm:create").Register("database:mark_write", r.markWrite),
+		db.Callback().Update().After("gorm:update").Register("database:mark_write", r.markWrite),
+		db.Callback().Delete().After("gorm:delete").Register("database:mark_write", r.markWrite),
+		db.Callback().Raw().After("gorm:raw").Register("database:mark_write", r.markWrite),
+	}
+	for _, err := range callbacks {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replicas 返回所有从库
+func (r *Resolver) Replicas() []*Replica {
+	return r.replicas
+}
+
+func (r *Resolver) routeRead(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || isTransaction(stmt.ConnPool) || !isRead(stmt) || r.usePrimary(stmt.Context) {
+		return
+	}
+	if replica := r.pick(); replica != nil {
+		stmt.ConnPool = replica.DB
+	}
+}
+
+func (r *Resolver) markWrite(db *gorm.DB) {
+	if db.Error != nil || isRead(db.Statement) {
+		return
+	}
+	if s := sessionFrom(db.Statement.Context); s != nil {
+		s.markWrite(time.Now())
+	}
+}
+
+func (r *Resolver) pick() *Replica {
+	healthy := make([]*Replica, 0, len(r.replicas))
+	for _, replica := range r.replicas {
+		if replica.Healthy() {
+			healthy = append(healthy, replica)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	return r.policy.Pick(healthy)
+}
+
+// usePrimary 判断读请求是否需要使用主库：显式要求主库，或同一会话最近有写入
+func (r *Resolver) usePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if PrimaryRequested(ctx) {
+		return true
+	}
+	s := sessionFrom(ctx)
+	if s == nil {
+		return false
+	}
+	lastWrite := s.LastWrite()
+	// 会话中的写入时间可能来自客户端 cookie，不能晚于当前时间
+	if now := time.Now(); lastWrite.After(now) {
+		lastWrite = now
+	}
+	return time.Since(lastWrite) < r.stickyWindow
+}
+
+// StartHealthCheck 每隔 interval ping 一次从库，失败的从库不再接收读请求，恢复后重新加入
+func (r *Resolver) StartHealthCheck(interval time.Duration) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				r.CheckHealth(ctx)
+				cancel()
+			}
+		}
+	}()
+}
+
+// CheckHealth 检查所有从库并更新健康状态
+func (r *Resolver) CheckHealth(ctx context.Context) {
+	for _, replica := range r.replicas {
+		err := replica.DB.PingContext(ctx)
+		healthy := err == nil
+		if replica.healthy.Swap(healthy) == healthy {
+			continue
+		}
+		if healthy {
+			logger.Logger.Info("Database replica recovered", zap.String("replica", replica.Name))
+		} else {
+			logger.Logger.Warn("Database replica is unhealthy, removed from rotation", zap.String("replica", replica.Name), zap.Error(err))
+		}
+	}
+}
+
+// Close 停止健康检查并关闭从库连接
+func (r *Resolver) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		r.wg.Wait()
+		for _, replica := range r.replicas {
+			if closeErr := replica.DB.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	})
+	return err
+}
+
+func isTransaction(connPool gorm.ConnPool) bool {
+	_, ok := connPool.(gorm.TxCommitter)
+	return ok
+}
+
+// isRead 判断语句是否可以在从库执行：原生 SQL 只有 SELECT 可以，加锁读（FOR UPDATE 等）必须使用主库
+func isRead(stmt *gorm.Statement) bool {
+	if _, locking := stmt.Clauses["FOR"]; locking {
+		return false
+	}
+	sql := strings.TrimSpace(stmt.SQL.String())
+	if sql == "" {
+		// Query 和 Row 在生成 SQL 之前路由，此时只会是 SELECT
+		return true
+	}
+	lower := strings.ToLower(sql)
+	return strings.HasPrefix(lower, "select") && !strings.Contains(lower, " for update") && !strings.Contains(lower, " for share")
+}
+
+type primaryKey struct{}
+
+// WithPrimary 返回的 ctx 中所有读请求都使用主库，用于必须读到最新数据的场景
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// PrimaryRequested 报告 ctx 是否由 WithPrimary 标记为使用主库
+func PrimaryRequested(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+type sessionKey struct{}
+
+// session 记录同一请求或会话中最近一次写入的时间
+type session struct {
+	mu        sync.Mutex
+	lastWrite time.Time
+	onWrite   func(lastWrite time.Time)
+}
+
+// WithSession 返回带有读写会话的 ctx：会话中发生写入后，sticky_window 内的读请求使用主库。
+// lastWrite 用于延续之前请求中的写入，onWrite 在每次写入后调用，可以为 nil
+func WithSession(ctx context.Context, lastWrite time.Time, onWrite func(lastWrite time.Time)) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{lastWrite: lastWrite, onWrite: onWrite})
+}
+
+func sessionFrom(ctx context.Context) *session {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(sessionKey{}).(*session)
+	return s
+}
+
+func (s *session) LastWrite() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastWrite
+}
+
+func (s *session) markWrite(at time.Time) {
+	s.mu.Lock()
+	s.lastWrite = at
+	onWrite := s.onWrite
+	s.mu.Unlock()
+	if onWrite != nil {
+		onWrite(at)
+	}
+}
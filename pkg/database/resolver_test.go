@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID   uint
+	Name string
+}
+
+// newReplicatedDB 使用两个独立的 SQLite 文件模拟主库和从库，主库中的数据不会同步到从库
+func newReplicatedDB(t *testing.T) (*gorm.DB, *Resolver) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := InitDB(config.DatabaseConfig{
+		Driver: DriverSQLite,
+		SQLite: config.SQLiteConfig{Path: filepath.Join(dir, "primary.db")},
+		Replicas: config.ReplicasConfig{
+			Nodes:        []config.ReplicaNodeConfig{{Path: filepath.Join(dir, "replica.db")}},
+			StickyWindow: time.Minute,
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { Close(db) })
+	resolver := db.Config.Plugins[resolverName].(*Resolver)
+
+	require.NoError(t, db.AutoMigrate(&item{}))
+	require.NoError(t, db.Create(&item{ID: 1, Name: "primary"}).Error)
+	for _, stmt := range []string{"CREATE TABLE items (id integer PRIMARY KEY, name text)", "INSERT INTO items VALUES (1, 'replica')"} {
+		_, err := resolver.Replicas()[0].DB.Exec(stmt)
+		require.NoError(t, err)
+	}
+	return db, resolver
+}
+
+func readName(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+	var it item
+	require.NoError(t, db.First(&it, 1).Error)
+	return it.Name
+}
+
+func TestResolverRoutesReadsToReplicas(t *testing.T) {
+	ctx := context.Background()
+	db, _ := newReplicatedDB(t)
+
+	assert.Equal(t, "replica", readName(t, db))
+	assert.Equal(t, "primary", readName(t, db.WithContext(WithPrimary(ctx))))
+
+	var name string
+	require.NoError(t, db.Raw("SELECT name FROM items WHERE id = ?", 1).Scan(&name).Error)
+	assert.Equal(t, "replica", name)
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		assert.Equal(t, "primary", readName(t, tx), "reads inside a transaction use the primary")
+		return nil
+	}))
+}
+
+func TestResolverReadYourWrites(t *testing.T) {
+	db, _ := newReplicatedDB(t)
+
+	var written time.Time
+	ctx := WithSession(context.Background(), time.Time{}, func(at time.Time) { written = at })
+	assert.Equal(t, "replica", readName(t, db.WithContext(ctx)))
+
+	require.NoError(t, db.WithContext(ctx).Model(&item{ID: 1}).Update("name", "updated").Error)
+	assert.False(t, written.IsZero())
+	assert.Equal(t, "updated", readName(t, db.WithContext(ctx)))
+	assert.Equal(t, "replica", readName(t, db), "other sessions still read from replicas")
+
+	// 写入时间来自之前的请求，超过窗口后恢复读从库；客户端伪造的未来时间最多只生效一个窗口
+	assert.Equal(t, "updated", readName(t, db.WithContext(WithSession(context.Background(), time.Now().Add(-time.Second), nil))))
+	assert.Equal(t, "replica", readName(t, db.WithContext(WithSession(context.Background(), time.Now().Add(-time.Hour), nil))))
+	assert.Equal(t, "updated", readName(t, db.WithContext(WithSession(context.Background(), time.Now().Add(time.Hour), nil))))
+}
+
+func TestResolverDropsUnhealthyReplicas(t *testing.T) {
+	ctx := context.Background()
+	db, resolver := newReplicatedDB(t)
+	replica := resolver.Replicas()[0]
+
+	require.NoError(t, replica.DB.Close())
+	resolver.CheckHealth(ctx)
+	assert.False(t, replica.Healthy())
+	assert.Equal(t, "primary", readName(t, db), "falls back to the primary")
+}
+
+func TestPolicies(t *testing.T) {
+	replicas := []*Replica{NewReplica("a", &sql.DB{}), NewReplica("b", &sql.DB{})}
+
+	roundRobin, err := NewPolicy(PolicyRoundRobin)
+	require.NoError(t, err)
+	var picked []string
+	for i := 0; i < 4; i++ {
+		picked = append(picked, roundRobin.Pick(replicas).Name)
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, picked)
+
+	_, err = NewPolicy("fastest")
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const primaryPoolName = "primary"
+
+// PoolStats 是一个连接池的统计快照
+type PoolStats struct {
+	MaxOpen           int     `json:"max_open"`
+	Open              int     `json:"open"`
+	InUse             int     `json:"in_use"`
+	Idle              int     `json:"idle"`
+	WaitCount         int64   `json:"wait_count"`       // 累计等待空闲连接的次数
+	WaitDurationMs    float64 `json:"wait_duration_ms"` // 累计等待时间
+	MaxIdleClosed     int64   `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64   `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64   `json:"max_lifetime_closed"`
+}
+
+func newPoolStats(s sql.DBStats) PoolStats {
+	return PoolStats{
+		MaxOpen:           s.MaxOpenConnections,
+		Open:              s.OpenConnections,
+		InUse:             s.InUse,
+		Idle:              s.Idle,
+		WaitCount:         s.WaitCount,
+		WaitDurationMs:    float64(s.WaitDuration) / float64(time.Millisecond),
+		MaxIdleClosed:     s.MaxIdleClosed,
+		MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+		MaxLifetimeClosed: s.MaxLifetimeClosed,
+	}
+}
+
+// StatsExporter 定期采集主库和从库连接池的 sql.DBStats，实现了 expvar.Var，
+// 可以通过 expvar.Publish 导出。两次采集之间有请求等待空闲连接时记录警告，提示连接池过小
+type StatsExporter struct {
+	db *gorm.DB
+
+	mu       sync.RWMutex
+	snapshot map[string]PoolStats
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewStatsExporter(db *gorm.DB) *StatsExporter {
+	return &StatsExporter{db: db, snapshot: make(map[string]PoolStats), stop: make(chan struct{})}
+}
+
+// Start 立即采集一次，之后每隔 interval 采集，直到 Close
+func (e *StatsExporter) Start(interval time.Duration) {
+	e.Collect()
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				e.Collect()
+			}
+		}
+	}()
+}
+
+// Collect 采集一次统计数据，key 为 primary 或从库名称
+func (e *StatsExporter) Collect() {
+	pools := make(map[string]*sql.DB)
+	if sqlDB, err := e.db.DB(); err == nil {
+		pools[primaryPoolName] = sqlDB
+	}
+	if plugin, ok := e.db.Config.Plugins[resolverName]; ok {
+		for _, replica := range plugin.(*Resolver).Replicas() {
+			pools[replica.Name] = replica.DB
+		}
+	}
+
+	snapshot := make(map[string]PoolStats, len(pools))
+	for name, pool := range pools {
+		snapshot[name] = newPoolStats(pool.Stats())
+	}
+
+	e.mu.Lock()
+	previous := e.snapshot
+	e.snapshot = snapshot
+	e.mu.Unlock()
+
+	for name, stats := range snapshot {
+		if waits := stats.WaitCount - previous[name].WaitCount; waits > 0 && len(previous) > 0 {
+			logger.Logger.Warn("Database connection pool is saturated",
+				zap.String("pool", name),
+				zap.Int64("waits", waits),
+				zap.Float64("wait_ms", stats.WaitDurationMs-previous[name].WaitDurationMs),
+				zap.Int("in_use", stats.InUse),
+				zap.Int("max_open", stats.MaxOpen))
+		}
+	}
+}
+
+// Snapshot 返回最近一次采集的结果
+func (e *StatsExporter) Snapshot() map[string]PoolStats {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	snapshot := make(map[string]PoolStats, len(e.snapshot))
+	for name, stats := range e.snapshot {
+		snapshot[name] = stats
+	}
+	return snapshot
+}
+
+// String 实现 expvar.Var
+func (e *StatsExporter) String() string {
+	data, _ := json.Marshal(e.Snapshot())
+	return string(data)
+}
+
+// Close 停止定期采集
+func (e *StatsExporter) Close() {
+	e.closeOnce.Do(func() {
+		close(e.stop)
+		e.wg.Wait()
+	})
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTxRetries = 3
+	txRetryBackoff   = 10 * time.Millisecond
+)
+
+type txKey struct{}
+
+// TxManagerInterface 在事务中执行 fn，fn 中的仓储通过 ctx 使用同一事务
+type TxManagerInterface interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TxManager 基于 GORM 的事务管理。嵌套调用 WithinTx 时使用 savepoint，
+// 内层返回错误只回滚到 savepoint；最外层事务遇到死锁或序列化冲突时整体重试
+type TxManager struct {
+	db         *gorm.DB
+	MaxRetries int // 死锁或序列化冲突时的最大重试次数
+}
+
+func NewTxManager(db *gorm.DB) *TxManager {
+	return &TxManager{db: db, MaxRetries: defaultTxRetries}
+}
+
+// WithinTx 在事务中执行 fn，fn 返回错误或 panic 时回滚。
+// fn 可能因重试被执行多次，不要在其中做无法回滚的操作（例如发送消息、清除缓存），应在 WithinTx 返回后执行
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		// 外层事务已因死锁等错误中止，savepoint 无法恢复，由最外层重试
+		return tx.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, tx))
+		})
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, tx))
+		})
+		if err == nil || attempt >= m.MaxRetries || !IsRetryable(m.db.Dialector.Name(), err) {
+			return err
+		}
+
+		backoff := txRetryBackoff << attempt
+		backoff += time.Duration(rand.Int63n(int64(backoff)))
+		logger.Logger.Warn("Retrying transaction", zap.Int("attempt", attempt+1), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// FromContext 返回 ctx 中的事务，不在事务中时返回 db。返回值已绑定 ctx
+func FromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// IsRetryable 判断 dialect（Dialector.Name()）数据库返回的错误是否是死锁、序列化冲突或锁等待超时，
+// 这类错误重试整个事务通常可以成功。只识别对应驱动的错误类型，避免把其他实现了 Code() 的错误当作冲突
+func IsRetryable(dialect string, err error) bool {
+	switch dialect {
+	case DriverMySQL:
+		var mysqlErr *mysql.MySQLError
+		// 1213: deadlock, 1205: lock wait timeout
+		return errors.As(err, &mysqlErr) && (mysqlErr.Number == 1213 || mysqlErr.Number == 1205)
+	case DriverPostgres:
+		var pgErr *pgconn.PgError
+		// 40001: serialization_failure, 40P01: deadlock_detected
+		return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+	case DriverSQLite:
+		var sqliteErr *sqlite.Error
+		if !errors.As(err, &sqliteErr) {
+			return false
+		}
+		// SQLITE_BUSY 和 SQLITE_LOCKED，扩展错误码的低 8 位为主错误码
+		code := sqliteErr.Code() & 0xff
+		return code == 5 || code == 6
+	default:
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jtsang4/go-stater/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// codeError 实现了 Code()，但不是 SQLite 驱动的错误
+type codeError struct{}
+
+func (codeError) Error() string { return "database is locked" }
+func (codeError) Code() int     { return 5 }
+
+// sqliteBusy 让两个连接同时开启写事务，返回驱动产生的 SQLITE_BUSY
+func sqliteBusy(t *testing.T) error {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "busy.db")
+	open := func() *sql.DB {
+		db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(0)")
+		require.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	first, second := open(), open()
+	_, err := first.Exec("BEGIN IMMEDIATE")
+	require.NoError(t, err)
+	_, err = second.Exec("BEGIN IMMEDIATE")
+	require.Error(t, err)
+	return err
+}
+
+func TestWithinTxRetriesRetryableErrors(t *testing.T) {
+	ctx := context.Background()
+	busy := sqliteBusy(t)
+	db, err := InitDB(config.DatabaseConfig{Driver: DriverSQLite})
+	require.NoError(t, err)
+	defer Close(db)
+	require.NoError(t, db.AutoMigrate(&item{}))
+	txm := NewTxManager(db)
+	txm.MaxRetries = 2
+
+	attempts := 0
+	err = txm.WithinTx(ctx, func(ctx context.Context) error {
+		attempts++
+		if err := FromContext(ctx, db).Create(&item{Name: fmt.Sprint("attempt ", attempts)}).Error; err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return busy
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	var names []string
+	require.NoError(t, db.Model(&item{}).Pluck("name", &names).Error)
+	assert.Equal(t, []string{"attempt 3"}, names, "failed attempts are rolled back")
+
+	attempts = 0
+	err = txm.WithinTx(ctx, func(ctx context.Context) error {
+		attempts++
+		return busy
+	})
+	assert.ErrorIs(t, err, busy)
+	assert.Equal(t, 3, attempts, "gives up after MaxRetries")
+
+	attempts = 0
+	assert.Error(t, txm.WithinTx(ctx, func(ctx context.Context) error {
+		attempts++
+		return errors.New("validation failed")
+	}))
+	assert.Equal(t, 1, attempts, "other errors are not retried")
+}
+
+func TestWithinTxNestedDoesNotRetry(t *testing.T) {
+	ctx := context.Background()
+	busy := sqliteBusy(t)
+	db, err := InitDB(config.DatabaseConfig{Driver: DriverSQLite})
+	require.NoError(t, err)
+	defer Close(db)
+	txm := NewTxManager(db)
+	txm.MaxRetries = 0
+
+	inner := 0
+	err = txm.WithinTx(ctx, func(ctx context.Context) error {
+		return txm.WithinTx(ctx, func(ctx context.Context) error {
+			inner++
+			return busy
+		})
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, inner)
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(DriverMySQL, fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1213})))
+	assert.False(t, IsRetryable(DriverMySQL, &mysql.MySQLError{Number: 1062}))
+	assert.True(t, IsRetryable(DriverPostgres, &pgconn.PgError{Code: "40001"}))
+	assert.True(t, IsRetryable(DriverPostgres, &pgconn.PgError{Code: "40P01"}))
+	assert.False(t, IsRetryable(DriverPostgres, &pgconn.PgError{Code: "23505"}))
+	assert.False(t, IsRetryable(DriverPostgres, &mysql.MySQLError{Number: 1213}))
+
+	busy := sqliteBusy(t)
+	assert.True(t, IsRetryable(DriverSQLite, fmt.Errorf("wrapped: %w", busy)))
+	assert.False(t, IsRetryable(DriverMySQL, busy))
+	assert.False(t, IsRetryable(DriverSQLite, codeError{}))
+	assert.False(t, IsRetryable(DriverSQLite, errors.New("boom")))
+}
@@ -0,0 +1,312 @@
+// Package encryption 提供字段级加密。值使用信封加密：每个值生成随机的数据密钥（DEK），用 AES-256-GCM
+// 加密数据，DEK 再用 Keyring 的主密钥（KEK）加密后与密文保存在一起，密文中记录了 KEK 的 ID。
+// 轮换密钥时把新密钥设为主密钥，旧密钥保留用于解密，再运行 cmd/encrypt 用新密钥重新加密已有数据。
+//
+// 加密使用随机 nonce，相同的值每次加密的结果不同，因此不能按密文查询。需要精确查询或唯一约束的字段
+// 另外保存 BlindIndex 计算的 HMAC（盲索引）
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jtsang4/go-stater/config"
+)
+
+// KeySize 是密钥的字节数（AES-256）
+const KeySize = 32
+
+// prefix 标记加密后的值，没有该前缀的值视为加密之前写入的明文
+const prefix = "enc:v1:"
+
+// 开发密钥写在 .env.example 中，已经公开，只能用于本地开发
+const (
+	DevKeyID         = "dev-1"
+	devKey           = "Bi4+JS2rsgce2XoffOtmNk6Yz/qucHzr9ZB8YUr30YM="
+	devBlindIndexKey = "NOvmokLQfbe4nW7A8wWHXXl16NvgN+FizKCx7ALDRFA="
+)
+
+// releaseMode 与 gin.ReleaseMode 相同
+const releaseMode = "release"
+
+var (
+	ErrNoKeyring  = errors.New("encryption: keyring is not configured")
+	ErrDevKey     = errors.New("encryption: development keys must not be used in release mode, generate new ones with cmd/encrypt -genkey")
+	ErrUnknownKey = errors.New("encryption: unknown key")
+	ErrMalformed  = errors.New("encryption: malformed ciphertext")
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+var encoding = base64.RawURLEncoding
+
+// Keyring 保存加密密钥和盲索引密钥。用主密钥加密，用密文中记录的密钥解密
+type Keyring struct {
+	primary  string
+	keys     map[string]cipher.AEAD
+	blindKey []byte
+	dev      bool // 主密钥或盲索引密钥是公开的开发密钥
+}
+
+// InitKeyring 按配置创建 Keyring 并设为默认。server.mode 为 release 时拒绝使用开发密钥
+func InitKeyring(cfg *config.Config) (*Keyring, error) {
+	k, err := NewKeyring(cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Server.Mode == releaseMode && k.UsesDevKeys() {
+		return nil, ErrDevKey
+	}
+	SetDefault(k)
+	return k, nil
+}
+
+// NewKeyring 按配置创建 Keyring，密钥可以直接写在配置中，也可以从文件读取，均为 base64 编码
+func NewKeyring(cfg config.EncryptionConfig) (*Keyring, error) {
+	if cfg.PrimaryKey == "" {
+		return nil, errors.New("encryption: primary_key is not set, copy .env.example to .env for development keys")
+	}
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for _, key := range cfg.Keys {
+		if _, ok := keys[key.ID]; ok {
+			return nil, fmt.Errorf("encryption: duplicate key %q", key.ID)
+		}
+		raw, err := loadKey(key.Key, key.File)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: key %q: %w", key.ID, err)
+		}
+		keys[key.ID] = raw
+	}
+	blindKey, err := loadKey(cfg.BlindIndexKey, cfg.BlindIndexKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: blind index key: %w", err)
+	}
+	return NewKeyringFrom(cfg.PrimaryKey, keys, blindKey)
+}
+
+// NewKeyringFrom 使用给定的密钥创建 Keyring，primary 是加密新数据使用的密钥 ID
+func NewKeyringFrom(primary string, keys map[string][]byte, blindKey []byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("encryption: primary key %q is not configured", primary)
+	}
+	if len(blindKey) < KeySize {
+		return nil, fmt.Errorf("encryption: blind index key must be at least %d bytes", KeySize)
+	}
+	k := &Keyring{
+		primary:  primary,
+		keys:     make(map[string]cipher.AEAD, len(keys)),
+		blindKey: blindKey,
+		dev:      primary == DevKeyID || isDevKey(keys[primary], devKey) || isDevKey(blindKey, devBlindIndexKey),
+	}
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("encryption: invalid key id %q, use up to 32 letters, digits, '-' or '_'", id)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("encryption: key %q must be %d bytes", id, KeySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// GenerateKey 返回 base64 编码的随机密钥，可以用作加密密钥或盲索引密钥
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// UsesDevKeys 报告主密钥或盲索引密钥是否为 .env.example 中公开的开发密钥
+func (k *Keyring) UsesDevKeys() bool {
+	return k.dev
+}
+
+// Encrypt 用主密钥加密 plaintext。aad 是附加认证数据（例如列名），解密时必须相同，
+// 防止密文被复制到其他列
+//
+// 结果格式为 enc:v1:<key id>:<nonce + 加密后的 DEK>:<nonce + 密文>，各部分为 base64
+func (k *Keyring) Encrypt(plaintext, aad string) (string, error) {
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.primary], dek, []byte(k.primary))
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(data, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return prefix + k.primary + ":" + encoding.EncodeToString(wrapped) + ":" + encoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密 Encrypt 的结果，value 不是密文时原样返回
+func (k *Keyring) Decrypt(value, aad string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	kek, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, parts[0])
+	}
+	wrapped, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	sealed, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	dek, err := open(kek, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(data, sealed, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation 报告 value 是否需要用主密钥重新加密，未加密的值也需要
+func (k *Keyring) NeedsRotation(value string) bool {
+	id, ok := KeyID(value)
+	return !ok || id != k.primary
+}
+
+// BlindIndex 返回 value 的 HMAC-SHA256（十六进制），相同的 name 和 value 总是得到相同的结果。
+// name 区分不同的字段，相同的值在不同字段中的盲索引不同。需要忽略大小写等差异时由调用方先规范化 value
+func (k *Keyring) BlindIndex(name, value string) string {
+	mac := hmac.New(sha256.New, k.blindKey)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted 报告 value 是否为 Encrypt 的结果
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID 返回加密 value 使用的密钥 ID，value 不是密文时 ok 为 false
+func KeyID(value string) (id string, ok bool) {
+	if !IsEncrypted(value) {
+		return "", false
+	}
+	id, _, ok = strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return id, ok
+}
+
+var defaultKeyring atomic.Pointer[Keyring]
+
+// SetDefault 设置 GORM 序列化器和 BlindIndex 使用的 Keyring，应在访问数据库之前调用
+func SetDefault(k *Keyring) {
+	defaultKeyring.Store(k)
+}
+
+// Default 返回 SetDefault 设置的 Keyring
+func Default() (*Keyring, error) {
+	k := defaultKeyring.Load()
+	if k == nil {
+		return nil, ErrNoKeyring
+	}
+	return k, nil
+}
+
+// BlindIndex 使用默认的 Keyring 计算盲索引
+func BlindIndex(name, value string) (string, error) {
+	k, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return k.BlindIndex(name, value), nil
+}
+
+func isDevKey(key []byte, dev string) bool {
+	raw, _ := base64.StdEncoding.DecodeString(dev)
+	return hmac.Equal(key, raw)
+}
+
+// loadKey 解码 base64 编码的密钥，value 为空时从 file 读取
+func loadKey(value, file string) ([]byte, error) {
+	if value == "" && file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		value = strings.TrimSpace(string(content))
+	}
+	if value == "" {
+		return nil, errors.New("not configured")
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 返回 nonce + 密文
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: decrypt: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,235 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/pkg/database/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func testKey(b byte) []byte {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = b
+	}
+	return key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k, err := NewKeyringFrom("k1", map[string][]byte{"k1": testKey(1)}, testKey(9))
+	require.NoError(t, err)
+
+	a, err := k.Encrypt("alice@example.com", "email")
+	require.NoError(t, err)
+	b, err := k.Encrypt("alice@example.com", "email")
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b, "nonce 随机，每次加密的结果不同")
+	assert.True(t, IsEncrypted(a))
+	id, ok := KeyID(a)
+	assert.True(t, ok)
+	assert.Equal(t, "k1", id)
+
+	plaintext, err := k.Decrypt(a, "email")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", plaintext)
+
+	// 附加认证数据不同（密文被复制到其他列）或密文被篡改时解密失败
+	_, err = k.Decrypt(a, "username")
+	assert.Error(t, err)
+	tampered := a[:len(a)-2] + "AA"
+	if tampered == a {
+		tampered = a[:len(a)-2] + "BB"
+	}
+	_, err = k.Decrypt(tampered, "email")
+	assert.Error(t, err)
+
+	// 没有前缀的值是加密之前写入的明文
+	plaintext, err = k.Decrypt("bob@example.com", "email")
+	require.NoError(t, err)
+	assert.Equal(t, "bob@example.com", plaintext)
+	assert.True(t, k.NeedsRotation("bob@example.com"))
+	assert.False(t, k.NeedsRotation(a))
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, err := NewKeyringFrom("k1", map[string][]byte{"k1": testKey(1)}, testKey(9))
+	require.NoError(t, err)
+	value, err := old.Encrypt("secret", "email")
+	require.NoError(t, err)
+
+	rotated, err := NewKeyringFrom("k2", map[string][]byte{"k1": testKey(1), "k2": testKey(2)}, testKey(9))
+	require.NoError(t, err)
+	plaintext, err := rotated.Decrypt(value, "email")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", plaintext)
+	assert.True(t, rotated.NeedsRotation(value))
+	assert.Equal(t, old.BlindIndex("email", "secret"), rotated.BlindIndex("email", "secret"))
+
+	// 移除旧密钥后无法解密
+	removed, err := NewKeyringFrom("k2", map[string][]byte{"k2": testKey(2)}, testKey(9))
+	require.NoError(t, err)
+	_, err = removed.Decrypt(value, "email")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestBlindIndex(t *testing.T) {
+	k, err := NewKeyringFrom("k1", map[string][]byte{"k1": testKey(1)}, testKey(9))
+	require.NoError(t, err)
+	assert.Equal(t, k.BlindIndex("email", "a@b.c"), k.BlindIndex("email", "a@b.c"))
+	assert.NotEqual(t, k.BlindIndex("email", "a@b.c"), k.BlindIndex("phone", "a@b.c"))
+	assert.Len(t, k.BlindIndex("email", "a@b.c"), 64)
+
+	other, err := NewKeyringFrom("k1", map[string][]byte{"k1": testKey(1)}, testKey(8))
+	require.NoError(t, err)
+	assert.NotEqual(t, k.BlindIndex("email", "a@b.c"), other.BlindIndex("email", "a@b.c"))
+}
+
+func TestNewKeyring(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "k2.key")
+	require.NoError(t, os.WriteFile(file, []byte(base64.StdEncoding.EncodeToString(testKey(2))+"\n"), 0o600))
+	cfg := config.EncryptionConfig{
+		PrimaryKey: "k2",
+		Keys: []config.EncryptionKeyConfig{
+			{ID: "k1", Key: base64.StdEncoding.EncodeToString(testKey(1))},
+			{ID: "k2", File: file},
+		},
+		BlindIndexKey: base64.StdEncoding.EncodeToString(testKey(9)),
+	}
+	k, err := NewKeyring(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", k.PrimaryKeyID())
+
+	for name, mutate := range map[string]func(c *config.EncryptionConfig){
+		"unknown primary":  func(c *config.EncryptionConfig) { c.PrimaryKey = "k3" },
+		"short key":        func(c *config.EncryptionConfig) { c.Keys[0].Key = base64.StdEncoding.EncodeToString([]byte("short")) },
+		"invalid id":       func(c *config.EncryptionConfig) { c.Keys[0].ID = "k:1" },
+		"duplicate id":     func(c *config.EncryptionConfig) { c.Keys[0].ID = "k2" },
+		"no blind key":     func(c *config.EncryptionConfig) { c.BlindIndexKey = "" },
+		"missing key file": func(c *config.EncryptionConfig) { c.Keys[1].File = filepath.Join(t.TempDir(), "missing") },
+	} {
+		c := cfg
+		c.Keys = append([]config.EncryptionKeyConfig(nil), cfg.Keys...)
+		mutate(&c)
+		_, err := NewKeyring(c)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestInitKeyringRejectsDevKeys(t *testing.T) {
+	if k, err := Default(); err == nil {
+		defer SetDefault(k)
+	}
+	dev := config.EncryptionConfig{
+		PrimaryKey:    DevKeyID,
+		Keys:          []config.EncryptionKeyConfig{{ID: DevKeyID, Key: devKey}},
+		BlindIndexKey: devBlindIndexKey,
+	}
+	cfg := &config.Config{Server: config.ServerConfig{Mode: "debug"}, Encryption: dev}
+	k, err := InitKeyring(cfg)
+	require.NoError(t, err, "development keys are allowed outside release mode")
+	assert.True(t, k.UsesDevKeys())
+
+	cfg.Server.Mode = "release"
+	_, err = InitKeyring(cfg)
+	assert.ErrorIs(t, err, ErrDevKey)
+
+	// 只改 ID 或只换加密密钥都不够
+	cfg.Encryption.Keys = []config.EncryptionKeyConfig{{ID: "prod-1", Key: devKey}}
+	cfg.Encryption.PrimaryKey = "prod-1"
+	_, err = InitKeyring(cfg)
+	assert.ErrorIs(t, err, ErrDevKey)
+	cfg.Encryption.Keys[0].Key = base64.StdEncoding.EncodeToString(testKey(1))
+	_, err = InitKeyring(cfg)
+	assert.ErrorIs(t, err, ErrDevKey)
+
+	cfg.Encryption.BlindIndexKey = base64.StdEncoding.EncodeToString(testKey(9))
+	k, err = InitKeyring(cfg)
+	require.NoError(t, err)
+	assert.False(t, k.UsesDevKeys())
+	def, err := Default()
+	require.NoError(t, err)
+	assert.Same(t, k, def)
+}
+
+type contact struct {
+	ID        uint
+	Name      string
+	Phone     string `gorm:"serializer:encrypted"`
+	PhoneHash string
+	DeletedAt gorm.DeletedAt
+}
+
+func (c *contact) BeforeSave(tx *gorm.DB) (err error) {
+	c.PhoneHash, err = BlindIndex("phone", c.Phone)
+	return err
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	return dbtest.New(t, &contact{})
+}
+
+func TestSerializerAndRotate(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	old, err := NewKeyringFrom("k1", map[string][]byte{"k1": testKey(1)}, testKey(9))
+	require.NoError(t, err)
+	SetDefault(old)
+	t.Cleanup(func() { SetDefault(nil) })
+
+	contacts := []*contact{{Name: "alice", Phone: "555-0100"}, {Name: "bob", Phone: "555-0101"}}
+	require.NoError(t, db.Create(&contacts).Error)
+	require.NoError(t, db.Delete(contacts[1]).Error)
+	require.NoError(t, db.Exec("INSERT INTO contacts (name, phone) VALUES ('carol', '555-0102')").Error)
+
+	raw := func() map[string]string {
+		var rows []struct{ Name, Phone string }
+		require.NoError(t, db.Table("contacts").Select("name, phone").Find(&rows).Error)
+		result := make(map[string]string, len(rows))
+		for _, row := range rows {
+			result[row.Name] = row.Phone
+		}
+		return result
+	}
+	assert.True(t, strings.HasPrefix(raw()["alice"], "enc:v1:k1:"))
+	assert.Equal(t, "555-0102", raw()["carol"])
+
+	var loaded []contact
+	require.NoError(t, db.Unscoped().Order("id").Find(&loaded).Error)
+	assert.Equal(t, []string{"555-0100", "555-0101", "555-0102"}, []string{loaded[0].Phone, loaded[1].Phone, loaded[2].Phone})
+
+	// 轮换到 k2：只有 carol（明文）需要加密
+	stats, err := Rotate[contact](ctx, db, RotateOptions{BatchSize: 2, Columns: []string{"phone_hash"}})
+	require.NoError(t, err)
+	assert.Equal(t, RotateStats{Scanned: 3, Pending: 1, Updated: 1}, stats)
+	assert.True(t, strings.HasPrefix(raw()["carol"], "enc:v1:k1:"))
+
+	rotated, err := NewKeyringFrom("k2", map[string][]byte{"k1": testKey(1), "k2": testKey(2)}, testKey(9))
+	require.NoError(t, err)
+	SetDefault(rotated)
+	stats, err = Rotate[contact](ctx, db, RotateOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, RotateStats{Scanned: 3, Pending: 3}, stats)
+
+	stats, err = Rotate[contact](ctx, db, RotateOptions{BatchSize: 2, Columns: []string{"phone_hash"}})
+	require.NoError(t, err)
+	assert.Equal(t, RotateStats{Scanned: 3, Pending: 3, Updated: 3}, stats)
+	for name, value := range raw() {
+		assert.True(t, strings.HasPrefix(value, "enc:v1:k2:"), name)
+	}
+
+	var carol contact
+	require.NoError(t, db.Where("phone_hash = ?", rotated.BlindIndex("phone", "555-0102")).First(&carol).Error)
+	assert.Equal(t, "carol", carol.Name)
+
+	stats, err = Rotate[contact](ctx, db, RotateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, RotateStats{Scanned: 3}, stats)
+}
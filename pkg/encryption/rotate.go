@@ -0,0 +1,130 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// RotateOptions 配置 Rotate
+type RotateOptions struct {
+	BatchSize int      // 每批读取的行数，0 表示 500
+	All       bool     // 更新所有行，例如更换盲索引密钥之后；否则只更新未加密或不是用主密钥加密的行
+	Columns   []string // 模型钩子维护的其他列（例如盲索引），与加密列一起更新
+	DryRun    bool     // 只统计需要更新的行
+}
+
+// RotateStats 是 Rotate 的结果
+type RotateStats struct {
+	Scanned int // 读取的行数，包括软删除的行
+	Pending int // 需要更新的行数
+	Updated int // 实际更新的行数，读取之后被其他请求修改的行已由应用重新加密，不再更新
+}
+
+// Rotate 用默认 Keyring 的主密钥重新加密模型 T 的加密列（serializer:encrypted）。按主键分批读取原始值，
+// 找出需要更新的行后通过 GORM 读取（解密）再按结构体写回（加密），因此模型的 BeforeSave 等钩子会重新计算盲索引。
+// 写回时以读取到的原始密文为条件，不会覆盖并发的修改。可以重复运行，中断后重新运行即可
+func Rotate[T any](ctx context.Context, db *gorm.DB, opts RotateOptions) (RotateStats, error) {
+	var stats RotateStats
+	k, err := Default()
+	if err != nil {
+		return stats, err
+	}
+	s, err := schema.Parse(new(T), &sync.Map{}, db.NamingStrategy)
+	if err != nil {
+		return stats, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return stats, fmt.Errorf("encryption: %s has no primary key", s.Table)
+	}
+	pk := s.PrioritizedPrimaryField.DBName
+	var columns []string
+	for _, field := range s.Fields {
+		if strings.EqualFold(field.TagSettings["SERIALIZER"], SerializerName) && field.DBName != "" {
+			columns = append(columns, field.DBName)
+		}
+	}
+	if len(columns) == 0 {
+		return stats, fmt.Errorf("encryption: %s has no encrypted columns", s.Table)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	selected := append(append([]string{}, columns...), opts.Columns...)
+
+	db = db.WithContext(ctx)
+	var last interface{}
+	for {
+		// 按表名读取，不经过序列化器，也包括软删除的行
+		q := db.Table(s.Table).Select(append([]string{pk}, columns...)).Order(pk).Limit(opts.BatchSize)
+		if last != nil {
+			q = q.Where(clause.Gt{Column: clause.Column{Name: pk}, Value: last})
+		}
+		var rows []map[string]interface{}
+		if err := q.Find(&rows).Error; err != nil {
+			return stats, err
+		}
+		if len(rows) == 0 {
+			return stats, nil
+		}
+		last = rows[len(rows)-1][pk]
+		stats.Scanned += len(rows)
+
+		pending := make(map[string]map[string]interface{})
+		ids := make([]interface{}, 0, len(rows))
+		for _, row := range rows {
+			if opts.All || needsRotation(k, row, columns) {
+				pending[fmt.Sprint(row[pk])] = row
+				ids = append(ids, row[pk])
+			}
+		}
+		stats.Pending += len(ids)
+		if len(ids) == 0 || opts.DryRun {
+			continue
+		}
+
+		var entities []*T
+		if err := db.Unscoped().Where(clause.IN{Column: clause.Column{Name: pk}, Values: ids}).Find(&entities).Error; err != nil {
+			return stats, err
+		}
+		for _, entity := range entities {
+			id, _ := s.PrioritizedPrimaryField.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+			row := pending[fmt.Sprint(id)]
+			update := db.Unscoped().Model(entity).Select(selected)
+			for _, column := range columns {
+				if row[column] == nil {
+					update = update.Where(clause.Eq{Column: clause.Column{Name: column}, Value: nil})
+				} else {
+					update = update.Where(clause.Eq{Column: clause.Column{Name: column}, Value: toString(row[column])})
+				}
+			}
+			result := update.Updates(entity)
+			if result.Error != nil {
+				return stats, result.Error
+			}
+			stats.Updated += int(result.RowsAffected)
+		}
+	}
+}
+
+func needsRotation(k *Keyring, row map[string]interface{}, columns []string) bool {
+	for _, column := range columns {
+		if row[column] != nil && k.NeedsRotation(toString(row[column])) {
+			return true
+		}
+	}
+	return false
+}
+
+func toString(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,75 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// SerializerName 是加密序列化器的名称，字段使用 `gorm:"serializer:encrypted"` 时写入前用默认 Keyring
+// 的主密钥加密，读取时解密。只支持 string 和 *string 字段。
+//
+// 列名作为附加认证数据，密文不能复制到其他列。加密后的值比原值长很多（128 个字符的值约 330 个字符），
+// 列宽需要相应增加。加密之前写入的明文可以正常读取，运行 cmd/encrypt 后才会加密。
+// 按 map 更新（UpdateColumns）时 GORM 不调用序列化器，加密字段只能按结构体更新
+const SerializerName = "encrypted"
+
+func init() {
+	schema.RegisterSerializer(SerializerName, Serializer{})
+}
+
+// Serializer 实现 schema.SerializerInterface
+type Serializer struct{}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	value := reflect.New(field.FieldType).Elem()
+	if dbValue != nil {
+		var s string
+		switch v := dbValue.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		default:
+			return fmt.Errorf("encryption: unsupported value type %T for %s", dbValue, field.Name)
+		}
+		k, err := Default()
+		if err != nil {
+			return err
+		}
+		plaintext, err := k.Decrypt(s, field.DBName)
+		if err != nil {
+			return fmt.Errorf("%w (column %s)", err, field.DBName)
+		}
+		switch field.FieldType.Kind() {
+		case reflect.String:
+			value.SetString(plaintext)
+		case reflect.Ptr:
+			value = reflect.ValueOf(&plaintext)
+		}
+	}
+	field.ReflectValueOf(ctx, dst).Set(value)
+	return nil
+}
+
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	var plaintext string
+	switch v := fieldValue.(type) {
+	case string:
+		plaintext = v
+	case *string:
+		if v == nil {
+			return nil, nil
+		}
+		plaintext = *v
+	default:
+		return nil, fmt.Errorf("encryption: unsupported field type %T for %s", fieldValue, field.Name)
+	}
+	k, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return k.Encrypt(plaintext, field.DBName)
+}
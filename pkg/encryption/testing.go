@@ -0,0 +1,14 @@
+package encryption
+
+// NewTestKeyring 返回使用固定密钥的 Keyring，只能用于测试
+func NewTestKeyring() *Keyring {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	k, err := NewKeyringFrom("test", map[string][]byte{"test": key}, key)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
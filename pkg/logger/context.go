@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID 将请求 ID 放入 ctx，FromContext 返回的日志会带上该 ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID 返回 ctx 中的请求 ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext 返回带有 ctx 中请求 ID 的 Logger，用于将同一请求的日志关联起来
+func FromContext(ctx context.Context) *zap.Logger {
+	if id := RequestID(ctx); id != "" {
+		return Logger.With(zap.String("request_id", id))
+	}
+	return Logger
+}
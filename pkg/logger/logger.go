@@ -7,7 +7,8 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var Logger *zap.Logger
+// Logger 在 InitLogger 之前为 no-op，避免测试等场景下未初始化导致 panic
+var Logger = zap.NewNop()
 
 func InitLogger(cfg config.LoggerConfig) {
 	writeSyncer := getLogWriter(cfg)
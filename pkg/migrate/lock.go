@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sqliteMu 串行化同一进程内的迁移。SQLite 没有 advisory lock，
+// 多个进程同时迁移时由 schema_migrations 主键冲突保证同一迁移只会成功提交一次
+var sqliteMu sync.Mutex
+
+// acquireLock 获取名为 name 的数据库级互斥锁，返回释放函数。
+// MySQL 使用 GET_LOCK，PostgreSQL 使用 pg_advisory_lock，二者都是会话级的锁，
+// 因此需要独占一个连接直到释放
+func acquireLock(ctx context.Context, db *gorm.DB, name string, timeout time.Duration) (func(), error) {
+	switch db.Dialector.Name() {
+	case "mysql", "postgres":
+	default:
+		sqliteMu.Lock()
+		return sqliteMu.Unlock, nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get connection for migration lock: %w", err)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var unlockQuery string
+	var arg interface{}
+	switch db.Dialector.Name() {
+	case "mysql":
+		// GET_LOCK 超时返回 0，出错返回 NULL
+		var ok sql.NullInt64
+		seconds := int64(math.Ceil(timeout.Seconds()))
+		if timeout <= 0 {
+			seconds = -1
+		}
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, seconds).Scan(&ok)
+		if err == nil && ok.Int64 != 1 {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		unlockQuery, arg = "SELECT RELEASE_LOCK(?)", name
+	case "postgres":
+		key := lockKey(name)
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key)
+		unlockQuery, arg = "SELECT pg_advisory_unlock($1)", key
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("acquire migration lock %q: %w", name, err)
+	}
+
+	return func() {
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(unlockCtx, unlockQuery, arg); err != nil {
+			// 连接归还连接池后锁仍被持有，释放失败时丢弃该连接，数据库会在会话结束时释放锁
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
+
+// lockKey 将锁名转换为 pg_advisory_lock 使用的 bigint
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
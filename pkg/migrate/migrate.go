@@ -0,0 +1,269 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 启动时的迁移模式，对应 database.migrate 配置
+const (
+	ModeAuto  = "auto"  // 执行未执行的迁移
+	ModeCheck = "check" // 数据库落后时拒绝启动，迁移由部署流程中的 migrate up 执行
+	ModeOff   = "off"
+)
+
+// ErrSchemaBehind 表示数据库中还有未执行的迁移
+var ErrSchemaBehind = errors.New("database schema is behind, run migrations first")
+
+// Migration 是一个有序的数据库变更，Version 越大越晚执行。
+// Up 和 Down 在事务中执行，并与 schema_migrations 记录一起提交
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // 为 nil 时不可回滚
+}
+
+// Status 描述一个迁移的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Missing   bool // 数据库中有记录但当前程序中不存在，通常来自更新版本的部署
+}
+
+// schemaMigration 是 schema_migrations 表中的一行
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator 执行迁移，Up 和 Down 会先获取数据库的 advisory lock，
+// 多个实例同时启动时只有一个会执行迁移，其余实例等待后发现已无待执行的迁移
+type Migrator struct {
+	db          *gorm.DB
+	migrations  []Migration
+	LockName    string
+	LockTimeout time.Duration
+}
+
+// NewMigrator 按版本号排序迁移，版本号重复时返回错误
+func NewMigrator(db *gorm.DB, migrations []Migration) (*Migrator, error) {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, m := range sorted {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up step", m.Version, m.Name)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d (%s, %s)", m.Version, sorted[i-1].Name, m.Name)
+		}
+	}
+
+	return &Migrator{
+		db:          db,
+		migrations:  sorted,
+		LockName:    "schema_migrations",
+		LockTimeout: time.Minute,
+	}, nil
+}
+
+// Up 执行所有未执行的迁移，返回本次执行的迁移
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(db, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按执行顺序倒序回滚最近的 steps 个迁移，返回本次回滚的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if len(done) >= steps {
+				break
+			}
+			migration, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("migration %d is applied but unknown to this build", version)
+			}
+			if err := m.revert(db, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 返回所有迁移的执行状态，按版本号排序
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(m.db.WithContext(database.WithPrimary(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name, Applied: true, AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending 返回未执行的迁移
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(m.db.WithContext(database.WithPrimary(ctx)))
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Check 在有未执行的迁移时返回 ErrSchemaBehind。
+// 数据库中存在未知的迁移（更新版本已部署）不视为错误，滚动发布时新旧版本会同时运行
+func (m *Migrator) Check(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d pending, first is %d_%s", ErrSchemaBehind, len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+func (m *Migrator) apply(db *gorm.DB, migration Migration) error {
+	start := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("apply migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	logger.Logger.Info("Applied migration",
+		zap.Int64("version", migration.Version),
+		zap.String("name", migration.Name),
+		zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+func (m *Migrator) revert(db *gorm.DB, migration Migration) error {
+	if migration.Down == nil {
+		return fmt.Errorf("migration %d_%s is irreversible", migration.Version, migration.Name)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("revert migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	logger.Logger.Info("Reverted migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+	return nil
+}
+
+// applied 读取已执行的迁移，schema_migrations 表不存在时视为没有执行过任何迁移
+func (m *Migrator) applied(db *gorm.DB) (map[int64]schemaMigration, error) {
+	applied := make(map[int64]schemaMigration)
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return applied, nil
+	}
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// withLock 获取 advisory lock 并确保 schema_migrations 表存在
+func (m *Migrator) withLock(ctx context.Context, fn func(db *gorm.DB) error) error {
+	unlock, err := acquireLock(ctx, m.db, m.LockName, m.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// 配置了从库时，已执行的迁移必须从主库读取
+	db := m.db.WithContext(database.WithPrimary(ctx))
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return fn(db)
+}
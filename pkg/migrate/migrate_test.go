@@ -0,0 +1,165 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/database/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func execStep(sql string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error { return tx.Exec(sql).Error }
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	migrations := []Migration{
+		{Version: 2, Name: "add_items_price", Up: execStep("ALTER TABLE items ADD COLUMN price integer"), Down: execStep("ALTER TABLE items DROP COLUMN price")},
+		{Version: 1, Name: "create_items", Up: execStep("CREATE TABLE items (id integer PRIMARY KEY)"), Down: execStep("DROP TABLE items")},
+	}
+	m, err := NewMigrator(db, migrations)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, m.Check(ctx), ErrSchemaBehind)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 2)
+	assert.Equal(t, int64(1), applied[0].Version)
+	assert.True(t, db.Migrator().HasColumn("items", "price"))
+	assert.NoError(t, m.Check(ctx))
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	reverted, err := m.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, int64(2), reverted[0].Version)
+	assert.False(t, db.Migrator().HasColumn("items", "price"))
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.False(t, statuses[1].Applied)
+}
+
+func TestMigratorRollsBackFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	m, err := NewMigrator(db, []Migration{
+		{Version: 1, Name: "create_items", Up: execStep("CREATE TABLE items (id integer PRIMARY KEY)")},
+		{Version: 2, Name: "broken", Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("INSERT INTO items (id) VALUES (1)").Error; err != nil {
+				return err
+			}
+			return errors.New("backfill failed")
+		}},
+	})
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx)
+	assert.ErrorContains(t, err, "2_broken")
+	assert.Len(t, applied, 1)
+
+	var count int64
+	require.NoError(t, db.Table("items").Count(&count).Error)
+	assert.Zero(t, count, "the failed migration's writes are rolled back")
+	pending, err := m.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, int64(2), pending[0].Version)
+
+	_, err = m.Down(ctx, 1)
+	assert.ErrorContains(t, err, "irreversible")
+}
+
+func TestCheckIgnoresMigrationsFromNewerBuilds(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	newer, err := NewMigrator(db, []Migration{
+		{Version: 1, Name: "one", Up: execStep("SELECT 1")},
+		{Version: 2, Name: "two", Up: execStep("SELECT 1")},
+	})
+	require.NoError(t, err)
+	_, err = newer.Up(ctx)
+	require.NoError(t, err)
+
+	older, err := NewMigrator(db, []Migration{{Version: 1, Name: "one", Up: execStep("SELECT 1")}})
+	require.NoError(t, err)
+	assert.NoError(t, older.Check(ctx))
+
+	statuses, err := older.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[1].Missing)
+}
+
+func TestNewMigratorRejectsDuplicateVersions(t *testing.T) {
+	_, err := NewMigrator(nil, []Migration{
+		{Version: 1, Name: "a", Up: execStep("SELECT 1")},
+		{Version: 1, Name: "b", Up: execStep("SELECT 1")},
+	})
+	assert.Error(t, err)
+}
+
+func TestLoadSQL(t *testing.T) {
+	migrations, err := LoadSQL(fstest.MapFS{
+		"20250102000000_add_price.up.sql":      {Data: []byte("ALTER TABLE items ADD COLUMN price integer;")},
+		"20250101000000_create_items.up.sql":   {Data: []byte("CREATE TABLE items (id integer PRIMARY KEY);\nINSERT INTO items VALUES (1);")},
+		"20250101000000_create_items.down.sql": {Data: []byte("DROP TABLE items;")},
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	m, err := NewMigrator(dbtest.New(t), migrations)
+	require.NoError(t, err)
+	applied, err := m.Up(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "create_items", applied[0].Name)
+	assert.Nil(t, applied[1].Down)
+
+	_, err = LoadSQL(fstest.MapFS{"create_items.sql": {Data: []byte("SELECT 1")}})
+	assert.Error(t, err)
+	_, err = LoadSQL(fstest.MapFS{"1_create_items.down.sql": {Data: []byte("SELECT 1")}})
+	assert.Error(t, err)
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- leading comment; ignored
+CREATE TABLE a (v text DEFAULT 'x;y');
+/* block; comment */ INSERT INTO a VALUES ('it''s; fine');
+CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN RETURN NEW; END; $$ LANGUAGE plpgsql;
+CREATE TRIGGER a_ai AFTER INSERT ON a BEGIN INSERT INTO b VALUES (new.v); DELETE FROM c; END;
+`
+	assert.Equal(t, []string{
+		"CREATE TABLE a (v text DEFAULT 'x;y')",
+		"INSERT INTO a VALUES ('it''s; fine')",
+		"CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN RETURN NEW; END; $$ LANGUAGE plpgsql",
+		"CREATE TRIGGER a_ai AFTER INSERT ON a BEGIN INSERT INTO b VALUES (new.v); DELETE FROM c; END",
+	}, splitStatements(script))
+}
+
+func TestCreate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mysql")
+	now := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	up, down, err := Create(dir, "Add user avatar", now)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "20250304050607_add_user_avatar.up.sql"), up)
+	assert.FileExists(t, down)
+
+	_, _, err = Create(dir, "Add user avatar", now)
+	assert.ErrorIs(t, err, os.ErrExist)
+}
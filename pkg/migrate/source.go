@@ -0,0 +1,193 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// VersionLayout 是 Create 生成的版本号格式
+const VersionLayout = "20060102150405"
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var (
+	triggerBodyPattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:TEMP\w*\s+)?TRIGGER\b.*\bBEGIN\b`)
+	triggerEndPattern  = regexp.MustCompile(`(?i)\bEND\s*$`)
+)
+
+// LoadSQL 读取 fsys 根目录下形如 <version>_<name>.up.sql 和 <version>_<name>.down.sql 的文件，
+// 每个 up 文件对应一个迁移，down 文件可选
+func LoadSQL(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	var order []int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q, expected <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+			order = append(order, version)
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, m.Name, match[2])
+		}
+
+		step := sqlStep(splitStatements(string(content)))
+		if match[3] == "up" {
+			m.Up = step
+		} else {
+			m.Down = step
+		}
+	}
+
+	migrations := make([]Migration, 0, len(order))
+	for _, version := range order {
+		m := byVersion[version]
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d_%s has a down file but no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	return migrations, nil
+}
+
+// Create 在 dir 下生成一对空的 up/down 文件，版本号为当前 UTC 时间
+func Create(dir, name string, now time.Time) (up, down string, err error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", fmt.Errorf("migration name is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+
+	base := filepath.Join(dir, now.UTC().Format(VersionLayout)+"_"+name)
+	up, down = base+".up.sql", base+".down.sql"
+	for _, path := range []string{up, down} {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", err
+		}
+		f.Close()
+	}
+	return up, down, nil
+}
+
+func sqlStep(statements []string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// splitStatements 按分号拆分 SQL，忽略字符串、引号标识符、注释、PostgreSQL $$ 代码块
+// 和 CREATE TRIGGER ... BEGIN ... END 触发器体中的分号。
+// 大多数驱动默认不允许一次执行多条语句，因此逐条执行
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+			current.WriteByte('\n')
+			continue
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+			current.WriteByte(' ')
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			end := closingQuote(script, i+1, c)
+			current.WriteString(script[i:end])
+			i = end - 1
+			continue
+		case c == '$' && strings.HasPrefix(script[i:], "$$"):
+			end := strings.Index(script[i+2:], "$$")
+			if end < 0 {
+				end = len(script)
+			} else {
+				end += i + 4
+			}
+			current.WriteString(script[i:end])
+			i = end - 1
+			continue
+		case c == ';':
+			// 触发器体中的语句以分号结尾，直到 END 才是整个触发器的结尾
+			if body := current.String(); triggerBodyPattern.MatchString(body) && !triggerEndPattern.MatchString(body) {
+				break
+			}
+			flush()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	flush()
+	return statements
+}
+
+// closingQuote 返回从 start 开始第一个未转义的 quote 之后的位置，两个连续的 quote 视为转义
+func closingQuote(script string, start int, quote byte) int {
+	for i := start; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if quote == '\'' {
+				i++
+			}
+		case quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(script)
+}
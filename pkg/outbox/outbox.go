@@ -0,0 +1,89 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"gorm.io/gorm"
+)
+
+// Event 是待投递的领域事件。与业务数据在同一事务中写入，由 Relay 异步投递，
+// 同一聚合（AggregateType + AggregateID）的事件按写入顺序投递。
+// 投递语义为至少一次，消费者应使用 EventID 去重
+type Event struct {
+	ID            uint       `gorm:"primarykey" json:"-"`
+	EventID       string     `gorm:"size:36;uniqueIndex;not null" json:"id"`
+	AggregateType string     `gorm:"size:64;not null" json:"aggregate_type"`
+	AggregateID   string     `gorm:"size:64;not null" json:"aggregate_id"`
+	Type          string     `gorm:"size:128;not null" json:"type"`
+	Payload       string     `gorm:"type:text;not null" json:"-"` // JSON
+	Attempts      int        `gorm:"not null;default:0" json:"-"`
+	NextAttemptAt *time.Time `json:"-"`                 // 上次投递失败后，早于该时间不再重试
+	LastError     string     `gorm:"size:512" json:"-"` // 最近一次投递失败的原因
+	PublishedAt   *time.Time `gorm:"index" json:"-"`    // 投递成功的时间
+	FailedAt      *time.Time `json:"-"`                 // 超过最大重试次数后放弃投递的时间
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// BeforeCreate 为没有 EventID 的事件生成 UUID
+func (e *Event) BeforeCreate(tx *gorm.DB) error {
+	if e.EventID == "" {
+		e.EventID = uuid.NewString()
+	}
+	return nil
+}
+
+// MarshalJSON 输出投递给消费者的消息，Payload 作为 JSON 对象而不是字符串
+func (e *Event) MarshalJSON() ([]byte, error) {
+	type message Event
+	return json.Marshal(struct {
+		*message
+		Payload json.RawMessage `json:"payload"`
+	}{(*message)(e), json.RawMessage(e.Payload)})
+}
+
+// NewEvent 创建事件，payload 序列化为 JSON，aggregateID 使用 fmt.Sprint 转换为字符串
+func NewEvent(aggregateType string, aggregateID interface{}, eventType string, payload interface{}) (*Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s payload: %w", eventType, err)
+	}
+	return &Event{
+		EventID:       uuid.NewString(),
+		AggregateType: aggregateType,
+		AggregateID:   fmt.Sprint(aggregateID),
+		Type:          eventType,
+		Payload:       string(data),
+	}, nil
+}
+
+// OutboxInterface 记录待投递的事件。ctx 中有事务时事件写入该事务，
+// 与业务数据一起提交或回滚
+type OutboxInterface interface {
+	Add(ctx context.Context, events ...*Event) error
+}
+
+type Outbox struct {
+	events store.RepositoryInterface[Event]
+}
+
+func NewOutbox(db *gorm.DB) *Outbox {
+	return NewOutboxFrom(store.NewRepository[Event](db))
+}
+
+// NewOutboxFrom 使用指定的存储创建 Outbox，例如测试中使用 store.MemoryRepository
+func NewOutboxFrom(events store.RepositoryInterface[Event]) *Outbox {
+	return &Outbox{events: events}
+}
+
+func (o *Outbox) Add(ctx context.Context, events ...*Event) error {
+	return o.events.CreateInBatches(ctx, events, len(events))
+}
@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/database/dbtest"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	return dbtest.New(t, &Event{})
+}
+
+func TestAddJoinsTransaction(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	tx := database.NewTxManager(db)
+	o := NewOutbox(db)
+	events := store.NewRepository[Event](db)
+
+	rollback := errors.New("rollback")
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		event, err := NewEvent("user", 1, "user.registered", map[string]string{"name": "a"})
+		require.NoError(t, err)
+		require.NoError(t, o.Add(ctx, event))
+		return rollback
+	})
+	require.ErrorIs(t, err, rollback)
+	count, err := events.Count(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count, "events are rolled back with the transaction")
+
+	err = tx.WithinTx(ctx, func(ctx context.Context) error {
+		return o.Add(ctx, &Event{AggregateType: "user", AggregateID: "1", Type: "user.registered", Payload: "{}"})
+	})
+	require.NoError(t, err)
+	stored, err := events.First(ctx)
+	require.NoError(t, err)
+	assert.Len(t, stored.EventID, 36, "event id is generated")
+	assert.Nil(t, stored.PublishedAt)
+
+	publisher := &recordingPublisher{}
+	published, err := NewRelay(events, publisher, config.OutboxConfig{}).RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	stored, err = events.First(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, stored.PublishedAt)
+}
+
+func TestEventMarshalJSON(t *testing.T) {
+	event, err := NewEvent("user", uint(7), "user.email_changed", map[string]string{"email": "a@example.com"})
+	require.NoError(t, err)
+
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+	var message map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &message))
+	assert.Equal(t, event.EventID, message["id"])
+	assert.Equal(t, "7", message["aggregate_id"])
+	assert.Equal(t, "user.email_changed", message["type"])
+	assert.Equal(t, map[string]interface{}{"email": "a@example.com"}, message["payload"])
+	assert.NotContains(t, message, "attempts")
+}
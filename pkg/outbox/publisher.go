@@ -0,0 +1,160 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	PublisherLog     = "log"
+	PublisherRedis   = "redis"
+	PublisherWebhook = "webhook"
+)
+
+const (
+	defaultStream         = "outbox:events"
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// Publisher 将事件投递到下游。返回错误时 Relay 会稍后重试，
+// 同一事件可能被投递多次，实现不需要自己去重
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// NewPublisher 根据 cfg.Publisher 创建 Publisher，client 只在 redis 模式下使用
+func NewPublisher(cfg config.OutboxConfig, client redis.UniversalClient) (Publisher, error) {
+	switch cfg.Publisher {
+	case "", PublisherLog:
+		return NewLogPublisher(), nil
+	case PublisherRedis:
+		return NewRedisStreamPublisher(client, cfg.Redis.Stream, cfg.Redis.MaxLen), nil
+	case PublisherWebhook:
+		if cfg.Webhook.URL == "" {
+			return nil, errors.New("outbox: webhook url is required")
+		}
+		return NewWebhookPublisher(cfg.Webhook), nil
+	default:
+		return nil, fmt.Errorf("outbox: unknown publisher %q", cfg.Publisher)
+	}
+}
+
+// LogPublisher 只将事件写入日志，用于开发环境和还没有消费者的部署。
+// 不记录事件内容，避免日志中出现敏感数据
+type LogPublisher struct{}
+
+func NewLogPublisher() *LogPublisher {
+	return &LogPublisher{}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event *Event) error {
+	logger.Logger.Info("outbox event",
+		zap.String("event_id", event.EventID),
+		zap.String("type", event.Type),
+		zap.String("aggregate_type", event.AggregateType),
+		zap.String("aggregate_id", event.AggregateID))
+	return nil
+}
+
+// RedisStreamPublisher 使用 XADD 将事件追加到 Redis Stream，消费者可以使用消费组读取
+type RedisStreamPublisher struct {
+	client redis.UniversalClient
+	stream string
+	maxLen int64
+}
+
+// NewRedisStreamPublisher 创建 Redis Stream 投递器，stream 为空时使用 outbox:events，
+// maxLen > 0 时近似裁剪到该长度
+func NewRedisStreamPublisher(client redis.UniversalClient, stream string, maxLen int64) *RedisStreamPublisher {
+	if stream == "" {
+		stream = defaultStream
+	}
+	return &RedisStreamPublisher{client: client, stream: stream, maxLen: maxLen}
+}
+
+func (p *RedisStreamPublisher) Publish(ctx context.Context, event *Event) error {
+	args := &redis.XAddArgs{
+		Stream: p.stream,
+		Values: map[string]interface{}{
+			"event_id":       event.EventID,
+			"type":           event.Type,
+			"aggregate_type": event.AggregateType,
+			"aggregate_id":   event.AggregateID,
+			"payload":        event.Payload,
+			"created_at":     event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		},
+	}
+	if p.maxLen > 0 {
+		args.MaxLen = p.maxLen
+		args.Approx = true
+	}
+	return p.client.XAdd(ctx, args).Err()
+}
+
+// WebhookPublisher 以 JSON POST 投递事件，2xx 响应视为成功。
+// 请求头 Idempotency-Key 为 EventID；配置了 Secret 时，
+// X-Signature-256 为请求体的 HMAC-SHA256 签名（sha256=<hex>）
+type WebhookPublisher struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+func NewWebhookPublisher(cfg config.OutboxWebhookConfig) *WebhookPublisher {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &WebhookPublisher{
+		url:    cfg.URL,
+		secret: []byte(cfg.Secret),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.EventID)
+	req.Header.Set("X-Event-Type", event.Type)
+	if len(p.secret) > 0 {
+		mac := hmac.New(sha256.New, p.secret)
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 读完响应体以便复用连接
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
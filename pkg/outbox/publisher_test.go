@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jtsang4/go-stater/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookPublisher(t *testing.T) {
+	event, err := NewEvent("user", 1, "user.registered", map[string]int{"user_id": 1})
+	require.NoError(t, err)
+
+	status := http.StatusAccepted
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	publisher := NewWebhookPublisher(config.OutboxWebhookConfig{URL: srv.URL, Secret: "s3cret"})
+	require.NoError(t, publisher.Publish(context.Background(), event))
+	assert.Equal(t, event.EventID, header.Get("Idempotency-Key"))
+	assert.Equal(t, "user.registered", header.Get("X-Event-Type"))
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), header.Get("X-Signature-256"))
+	assert.Contains(t, string(body), `"payload":{"user_id":1}`)
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, publisher.Publish(context.Background(), event))
+}
+
+func TestRedisStreamPublisher(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+
+	publisher, err := NewPublisher(config.OutboxConfig{Publisher: PublisherRedis}, client)
+	require.NoError(t, err)
+	event, err := NewEvent("user", 1, "user.registered", map[string]int{"user_id": 1})
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(context.Background(), event))
+
+	messages, err := client.XRange(context.Background(), "outbox:events", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, event.EventID, messages[0].Values["event_id"])
+	assert.Equal(t, "user.registered", messages[0].Values["type"])
+	assert.Equal(t, `{"user_id":1}`, messages[0].Values["payload"])
+}
+
+func TestNewPublisher(t *testing.T) {
+	publisher, err := NewPublisher(config.OutboxConfig{}, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &LogPublisher{}, publisher)
+
+	_, err = NewPublisher(config.OutboxConfig{Publisher: PublisherWebhook}, nil)
+	assert.Error(t, err, "webhook url is required")
+
+	_, err = NewPublisher(config.OutboxConfig{Publisher: "kafka"}, nil)
+	assert.Error(t, err)
+}
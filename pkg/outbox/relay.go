@@ -0,0 +1,200 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/pkg/cache"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/logger"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	defaultRetryBackoff = time.Second
+	defaultMaxBackoff   = 5 * time.Minute
+	defaultLeaderTTL    = 15 * time.Second
+	purgeInterval       = time.Hour
+	maxLastErrorLength  = 512
+)
+
+// Relay 轮询未投递的事件并交给 Publisher。
+// 同一聚合的事件按 ID 顺序投递，某个事件投递失败后，该聚合之后的事件等它重试成功后才会投递，
+// 其他聚合不受影响。重试间隔从 RetryBackoff 开始每次翻倍，最长 MaxBackoff。
+// 设置了 MaxAttempts 时，超过次数的事件被标记为失败并跳过，此后该聚合的顺序不再保证。
+// Relay 假设同一时刻只有一个实例在运行，多实例部署时使用 RunAsLeader
+type Relay struct {
+	events    store.RepositoryInterface[Event]
+	publisher Publisher
+	cfg       config.OutboxConfig
+	now       func() time.Time
+}
+
+func NewRelay(events store.RepositoryInterface[Event], publisher Publisher, cfg config.OutboxConfig) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	return &Relay{events: events, publisher: publisher, cfg: cfg, now: time.Now}
+}
+
+// Run 每隔 PollInterval 投递一批事件，直到 ctx 结束。一批已满时立即处理下一批
+func (r *Relay) Run(ctx context.Context) {
+	var lastPurge time.Time
+	for {
+		published, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Logger.Warn("outbox relay failed", zap.Error(err))
+		}
+
+		if r.cfg.Retention > 0 && r.now().Sub(lastPurge) >= purgeInterval {
+			lastPurge = r.now()
+			if _, err := r.Purge(ctx); err != nil && ctx.Err() == nil {
+				logger.Logger.Warn("failed to purge outbox", zap.Error(err))
+			}
+		}
+
+		wait := r.cfg.PollInterval
+		if err == nil && published >= r.cfg.BatchSize {
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// RelayOnce 投递最早的一批未投递事件，最多尝试投递 BatchSize 个，返回投递成功的数量。
+// 正在等待重试的聚合的事件被跳过并继续向后翻页，不会占满一批而阻塞其他聚合。
+// 单个事件投递失败只记录在事件上，不作为错误返回
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	// 从库落后时会读到已投递的事件和旧的重试状态，导致重复投递
+	ctx = database.WithPrimary(ctx)
+
+	now := r.now()
+	published, attempted := 0, 0
+	blocked := make(map[string]bool)
+	var after uint
+	for attempted < r.cfg.BatchSize {
+		events, err := r.events.Find(ctx,
+			store.Eq("published_at", nil),
+			store.Eq("failed_at", nil),
+			store.Gt("id", after),
+			store.OrderBy("id"),
+			store.Limit(r.cfg.BatchSize, 0),
+		)
+		if err != nil {
+			return published, err
+		}
+
+		for _, event := range events {
+			if attempted >= r.cfg.BatchSize {
+				break
+			}
+			after = event.ID
+			aggregate := event.AggregateType + ":" + event.AggregateID
+			if blocked[aggregate] {
+				continue
+			}
+			if event.NextAttemptAt != nil && event.NextAttemptAt.After(now) {
+				blocked[aggregate] = true
+				continue
+			}
+
+			attempted++
+			if err := r.publisher.Publish(ctx, event); err != nil {
+				if ctx.Err() != nil {
+					return published, ctx.Err()
+				}
+				if r.fail(ctx, event, err, now) {
+					blocked[aggregate] = true
+				}
+				continue
+			}
+
+			// 事件已投递但状态更新失败时会被再次投递，由消费者按 EventID 去重
+			if err := r.events.UpdateColumns(ctx, event, map[string]interface{}{"published_at": r.now()}); err != nil {
+				return published, err
+			}
+			published++
+		}
+		if len(events) < r.cfg.BatchSize {
+			break
+		}
+	}
+	return published, nil
+}
+
+// fail 记录投递失败并安排重试，返回该聚合之后的事件是否需要等待
+func (r *Relay) fail(ctx context.Context, event *Event, cause error, now time.Time) bool {
+	attempts := event.Attempts + 1
+	message := cause.Error()
+	if len(message) > maxLastErrorLength {
+		message = message[:maxLastErrorLength]
+	}
+	values := map[string]interface{}{"attempts": attempts, "last_error": message}
+
+	giveUp := r.cfg.MaxAttempts > 0 && attempts >= r.cfg.MaxAttempts
+	if giveUp {
+		values["failed_at"] = now
+		logger.Logger.Error("giving up outbox event",
+			zap.String("event_id", event.EventID),
+			zap.String("type", event.Type),
+			zap.Int("attempts", attempts),
+			zap.Error(cause))
+	} else {
+		next := now.Add(r.backoff(attempts))
+		values["next_attempt_at"] = next
+		logger.Logger.Warn("failed to publish outbox event",
+			zap.String("event_id", event.EventID),
+			zap.String("type", event.Type),
+			zap.Int("attempts", attempts),
+			zap.Time("next_attempt_at", next),
+			zap.Error(cause))
+	}
+
+	if err := r.events.UpdateColumns(ctx, event, values); err != nil {
+		logger.Logger.Warn("failed to record outbox failure", zap.String("event_id", event.EventID), zap.Error(err))
+	}
+	return !giveUp
+}
+
+// backoff 返回第 attempts 次失败后的重试间隔
+func (r *Relay) backoff(attempts int) time.Duration {
+	backoff := r.cfg.RetryBackoff
+	for i := 1; i < attempts && backoff < r.cfg.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, r.cfg.MaxBackoff)
+}
+
+// Purge 删除投递成功超过 Retention 的事件，返回删除的数量
+func (r *Relay) Purge(ctx context.Context) (int64, error) {
+	if r.cfg.Retention <= 0 {
+		return 0, nil
+	}
+	return r.events.DeleteWhere(ctx, store.Lt("published_at", r.now().Add(-r.cfg.Retention)))
+}
+
+// RunAsLeader 与 Run 相同，但多个实例中同一时刻只有选举出的领导者在投递
+func (r *Relay) RunAsLeader(ctx context.Context, locker *cache.Locker) {
+	ttl := r.cfg.LeaderTTL
+	if ttl <= 0 {
+		ttl = defaultLeaderTTL
+	}
+	cache.NewElector(locker, "outbox-relay", ttl).Run(ctx, r.Run)
+}
@@ -0,0 +1,199 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jtsang4/go-stater/config"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher 记录投递成功的事件，fail 返回非 nil 时该事件投递失败
+type recordingPublisher struct {
+	mu        sync.Mutex
+	published []string
+	fail      func(event *Event) error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event *Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail != nil {
+		if err := p.fail(event); err != nil {
+			return err
+		}
+	}
+	p.published = append(p.published, event.Type)
+	return nil
+}
+
+func addEvents(t *testing.T, o *Outbox, events ...[2]string) {
+	t.Helper()
+	for _, e := range events {
+		event, err := NewEvent("user", e[0], e[1], nil)
+		require.NoError(t, err)
+		require.NoError(t, o.Add(context.Background(), event))
+	}
+}
+
+func newTestRelay(publisher Publisher, cfg config.OutboxConfig) (*Relay, *Outbox, *time.Time) {
+	events := store.NewMemoryRepository[Event]()
+	relay := NewRelay(events, publisher, cfg)
+	now := time.Now()
+	relay.now = func() time.Time { return now }
+	return relay, NewOutboxFrom(events), &now
+}
+
+// primaryRecorder 记录 Find 是否要求读主库
+type primaryRecorder struct {
+	store.RepositoryInterface[Event]
+	primary []bool
+}
+
+func (r *primaryRecorder) Find(ctx context.Context, specs ...store.Spec) ([]*Event, error) {
+	r.primary = append(r.primary, database.PrimaryRequested(ctx))
+	return r.RepositoryInterface.Find(ctx, specs...)
+}
+
+func TestRelayReadsPrimary(t *testing.T) {
+	events := &primaryRecorder{RepositoryInterface: store.NewMemoryRepository[Event]()}
+	relay := NewRelay(events, &recordingPublisher{}, config.OutboxConfig{})
+	addEvents(t, NewOutboxFrom(events), [2]string{"1", "1a"})
+
+	published, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []bool{true}, events.primary)
+}
+
+func TestRelayOrdersPerAggregate(t *testing.T) {
+	ctx := context.Background()
+	failing := true
+	publisher := &recordingPublisher{fail: func(event *Event) error {
+		if failing && event.Type == "1a" {
+			return errors.New("unavailable")
+		}
+		return nil
+	}}
+	relay, o, now := newTestRelay(publisher, config.OutboxConfig{RetryBackoff: time.Second, MaxBackoff: 3 * time.Second})
+	addEvents(t, o, [2]string{"1", "1a"}, [2]string{"2", "2a"}, [2]string{"1", "1b"}, [2]string{"2", "2b"})
+
+	// 1a 失败后 1b 等待，聚合 2 不受影响
+	published, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{"2a", "2b"}, publisher.published)
+
+	failed, err := relay.events.First(ctx, store.Eq("type", "1a"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Equal(t, "unavailable", failed.LastError)
+	assert.WithinDuration(t, now.Add(time.Second), *failed.NextAttemptAt, 0)
+
+	// 重试时间未到
+	failing = false
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, published)
+
+	*now = now.Add(time.Second)
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{"2a", "2b", "1a", "1b"}, publisher.published)
+
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, published, "published events are not delivered again")
+}
+
+// 一个聚合等待重试的事件占满一批时，其他聚合的事件仍会被投递
+func TestRelaySkipsBackedOffAggregate(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{fail: func(event *Event) error {
+		if event.Type == "poison" {
+			return errors.New("rejected")
+		}
+		return nil
+	}}
+	relay, o, now := newTestRelay(publisher, config.OutboxConfig{BatchSize: 3, RetryBackoff: time.Minute})
+	addEvents(t, o, [2]string{"1", "poison"}, [2]string{"1", "1b"}, [2]string{"1", "1c"}, [2]string{"1", "1d"},
+		[2]string{"2", "2a"})
+
+	published, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []string{"2a"}, publisher.published)
+
+	// 重试时间未到，等待中的事件不计入一批
+	*now = now.Add(time.Second)
+	addEvents(t, o, [2]string{"2", "2b"}, [2]string{"3", "3a"})
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{"2a", "2b", "3a"}, publisher.published)
+
+	poison, err := relay.events.First(ctx, store.Eq("type", "poison"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, poison.Attempts)
+}
+
+func TestRelayGivesUpAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	publisher := &recordingPublisher{fail: func(event *Event) error {
+		if event.Type == "bad" {
+			return errors.New("rejected")
+		}
+		return nil
+	}}
+	relay, o, now := newTestRelay(publisher, config.OutboxConfig{MaxAttempts: 2, RetryBackoff: time.Second})
+	addEvents(t, o, [2]string{"1", "bad"}, [2]string{"1", "next"})
+
+	_, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, publisher.published)
+
+	*now = now.Add(time.Second)
+	_, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"next"}, publisher.published)
+
+	bad, err := relay.events.First(ctx, store.Eq("type", "bad"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, bad.Attempts)
+	assert.NotNil(t, bad.FailedAt)
+	assert.Nil(t, bad.PublishedAt)
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := NewRelay(nil, nil, config.OutboxConfig{RetryBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	assert.Equal(t, time.Second, relay.backoff(1))
+	assert.Equal(t, 2*time.Second, relay.backoff(2))
+	assert.Equal(t, 8*time.Second, relay.backoff(4))
+	assert.Equal(t, 10*time.Second, relay.backoff(5))
+	assert.Equal(t, 10*time.Second, relay.backoff(100))
+}
+
+func TestRelayPurge(t *testing.T) {
+	ctx := context.Background()
+	relay, o, now := newTestRelay(&recordingPublisher{}, config.OutboxConfig{Retention: time.Hour})
+	addEvents(t, o, [2]string{"1", "a"}, [2]string{"1", "b"})
+	_, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	addEvents(t, o, [2]string{"1", "pending"})
+
+	*now = now.Add(2 * time.Hour)
+	deleted, err := relay.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	remaining, err := relay.events.Find(ctx)
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "pending", remaining[0].Type)
+}
@@ -0,0 +1,266 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/jtsang4/go-stater/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	minCandidates = 100
+	maxCandidates = 4 * MaxWindow
+)
+
+// DBIndex 使用数据库的全文索引查找候选记录。索引由迁移创建，由数据库在写入时维护：
+//   - MySQL：字段上的 FULLTEXT 索引（ngram 解析器），有拼写错误的词仍与原词共享部分二元组
+//   - PostgreSQL：simple 配置的 tsvector 表达式索引和 pg_trgm 的 GIN 索引，
+//     容错程度由 pg_trgm.word_similarity_threshold 决定
+//   - SQLite：FTS5 trigram 表 <table>_fts，由触发器同步。按查询词及其删去一个字符后的三元组查找
+//
+// 候选记录按数据库的相关度最多取 4 倍于 Offset + Limit 条（至少 100 条），再按与 MemoryIndex
+// 相同的规则打分和高亮，因此 Total 是候选记录中匹配的数量
+type DBIndex struct {
+	db      *gorm.DB
+	model   interface{}
+	table   string
+	primary string
+	fields  []Field
+}
+
+// NewDBIndex 创建模型 T 的索引，软删除的记录不会出现在结果中
+func NewDBIndex[T any](db *gorm.DB, fields ...Field) (*DBIndex, error) {
+	switch db.Dialector.Name() {
+	case database.DriverMySQL, database.DriverPostgres, database.DriverSQLite:
+	default:
+		return nil, fmt.Errorf("search: unsupported database %s", db.Dialector.Name())
+	}
+	s, err := schema.Parse(new(T), &sync.Map{}, db.NamingStrategy)
+	if err != nil {
+		return nil, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("search: %s has no primary key", s.Table)
+	}
+	for _, field := range fields {
+		if s.LookUpField(field.Name) == nil {
+			return nil, fmt.Errorf("search: %s has no column %q", s.Table, field.Name)
+		}
+	}
+	return &DBIndex{db: db, model: new(T), table: s.Table, primary: s.PrioritizedPrimaryField.DBName, fields: fields}, nil
+}
+
+// Index 不做任何事，索引由数据库维护
+func (d *DBIndex) Index(ctx context.Context, docs ...Document) error {
+	return nil
+}
+
+// Delete 不做任何事，索引由数据库维护
+func (d *DBIndex) Delete(ctx context.Context, ids ...uint) error {
+	return nil
+}
+
+func (d *DBIndex) Search(ctx context.Context, q Query) (*Result, error) {
+	q, err := q.normalize()
+	if err != nil {
+		return nil, err
+	}
+	queryTerms := terms(q.Text)
+	if len(queryTerms) == 0 {
+		return page(nil, q), nil
+	}
+
+	columns := []string{d.column(d.primary)}
+	for _, field := range d.fields {
+		columns = append(columns, d.column(field.Name))
+	}
+	tx := database.FromContext(ctx, d.db).Model(d.model).Select(columns)
+	switch d.db.Dialector.Name() {
+	case database.DriverMySQL:
+		tx = d.mysql(tx, queryTerms)
+	case database.DriverPostgres:
+		tx = d.postgres(tx, queryTerms)
+	case database.DriverSQLite:
+		tx = d.sqlite(tx, queryTerms)
+	}
+
+	limit := (q.Offset + q.Limit) * 4
+	if limit < minCandidates {
+		limit = minCandidates
+	}
+	if limit > maxCandidates {
+		limit = maxCandidates
+	}
+	var rows []map[string]interface{}
+	if err := tx.Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	docs := make([]Document, 0, len(rows))
+	for _, row := range rows {
+		id, err := strconv.ParseUint(toString(row[d.primary]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("search: invalid id %v: %w", row[d.primary], err)
+		}
+		doc := Document{ID: uint(id), Fields: make(map[string]string, len(d.fields))}
+		for _, field := range d.fields {
+			doc.Fields[field.Name] = toString(row[field.Name])
+		}
+		docs = append(docs, doc)
+	}
+	return page(rank(d.fields, queryTerms, docs), q), nil
+}
+
+// mysql 使用 FULLTEXT 索引，少于 ngram_token_size（默认 2）个字符的词用 LIKE 匹配
+func (d *DBIndex) mysql(tx *gorm.DB, queryTerms []string) *gorm.DB {
+	names := make([]string, len(d.fields))
+	for i, field := range d.fields {
+		names[i] = d.column(field.Name)
+	}
+	match := fmt.Sprintf("MATCH(%s) AGAINST (? IN NATURAL LANGUAGE MODE)", strings.Join(names, ", "))
+	against := strings.Join(queryTerms, " ")
+
+	conds := []string{match}
+	args := []interface{}{against}
+	for _, term := range queryTerms {
+		if utf8.RuneCountInString(term) < 2 {
+			c, a := d.like("LIKE", term)
+			conds, args = append(conds, c...), append(args, a...)
+		}
+	}
+	return tx.Where("("+strings.Join(conds, " OR ")+")", args...).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: match + " DESC", Vars: []interface{}{against}}})
+}
+
+// postgres 使用 tsvector 前缀匹配、pg_trgm 的 word_similarity（<%）和 ILIKE，三者都可以使用迁移中创建的索引
+func (d *DBIndex) postgres(tx *gorm.DB, queryTerms []string) *gorm.DB {
+	names := make([]string, len(d.fields))
+	for i, field := range d.fields {
+		names[i] = d.column(field.Name)
+	}
+	vector := fmt.Sprintf("to_tsvector('simple', %s)", strings.Join(names, " || ' ' || "))
+	prefixes := make([]string, len(queryTerms))
+	for i, term := range queryTerms {
+		prefixes[i] = term + ":*"
+	}
+
+	conds := []string{vector + " @@ to_tsquery('simple', ?)"}
+	args := []interface{}{strings.Join(prefixes, " | ")}
+	for _, term := range queryTerms {
+		if utf8.RuneCountInString(term) < 3 {
+			c, a := d.like("ILIKE", term)
+			conds, args = append(conds, c...), append(args, a...)
+			continue
+		}
+		for _, name := range names {
+			conds = append(conds, "? <% "+name, name+" ILIKE ?")
+			args = append(args, term, "%"+term+"%")
+		}
+	}
+
+	text := strings.Join(queryTerms, " ")
+	similarity := make([]string, len(names))
+	vars := make([]interface{}, len(names))
+	for i, name := range names {
+		similarity[i] = "word_similarity(?, " + name + ")"
+		vars[i] = text
+	}
+	order := "GREATEST(" + strings.Join(similarity, ", ") + ")"
+	if len(names) == 1 {
+		order = similarity[0]
+	}
+	return tx.Where("("+strings.Join(conds, " OR ")+")", args...).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: order + " DESC", Vars: vars}})
+}
+
+// sqlite 使用 FTS5 trigram 表。trigram 表按子串匹配，拼写错误的词与原词可能没有共同的三元组，
+// 因此还查找删去一个字符后的三元组，例如 alcie 删去 c 后为 alie，与 alice 共享 ali；
+// 较短的词中间有错字时（smoth）没有共同的三元组，再用前两个字符做 LIKE 匹配，这部分不使用索引
+func (d *DBIndex) sqlite(tx *gorm.DB, queryTerms []string) *gorm.DB {
+	var conds []string
+	var args []interface{}
+	seen := make(map[string]bool)
+	var phrases []string
+	for _, term := range queryTerms {
+		if utf8.RuneCountInString(term) < 3 {
+			c, a := d.like("LIKE", term)
+			conds, args = append(conds, c...), append(args, a...)
+			continue
+		}
+		if maxTypos(term) > 0 {
+			c, a := d.like("LIKE", runePrefix(term, 2))
+			conds, args = append(conds, c...), append(args, a...)
+		}
+		for _, gram := range fuzzyGrams(term) {
+			if !seen[gram] {
+				seen[gram] = true
+				phrases = append(phrases, `"`+gram+`"`)
+			}
+		}
+	}
+
+	if len(phrases) > 0 {
+		fts := d.table + "_fts"
+		tx = tx.Joins(fmt.Sprintf("LEFT JOIN (SELECT rowid, bm25(%[1]s) AS fts_rank FROM %[1]s WHERE %[1]s MATCH ?) fts ON fts.rowid = %[2]s",
+			fts, d.column(d.primary)), strings.Join(phrases, " OR "))
+		conds = append(conds, "fts.rowid IS NOT NULL")
+		tx = tx.Order("fts.fts_rank IS NULL, fts.fts_rank")
+	}
+	return tx.Where("("+strings.Join(conds, " OR ")+")", args...)
+}
+
+// like 返回各字段包含 term 的条件，用于索引无法处理的短词。term 只包含字母和数字，不需要转义
+func (d *DBIndex) like(op, term string) ([]string, []interface{}) {
+	conds := make([]string, len(d.fields))
+	args := make([]interface{}, len(d.fields))
+	for i, field := range d.fields {
+		conds[i] = d.column(field.Name) + " " + op + " ?"
+		args[i] = "%" + term + "%"
+	}
+	return conds, args
+}
+
+// column 返回带表名的列名。列名来自代码中的 Field，不是用户输入
+func (d *DBIndex) column(name string) string {
+	return d.table + "." + name
+}
+
+// fuzzyGrams 返回 term 及其删去一个字符后的所有三元组
+func fuzzyGrams(term string) []string {
+	runes := []rune(term)
+	variants := []string{term}
+	if maxTypos(term) > 0 {
+		for i := range runes {
+			variants = append(variants, string(runes[:i])+string(runes[i+1:]))
+		}
+	}
+
+	var result []string
+	for _, v := range variants {
+		r := []rune(v)
+		for i := 0; i+3 <= len(r); i++ {
+			result = append(result, string(r[i:i+3]))
+		}
+	}
+	return result
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
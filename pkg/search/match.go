@@ -0,0 +1,258 @@
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 各种匹配方式的得分，乘以字段权重后累加
+const (
+	scoreExact     = 1.0 // 整个字段值相同
+	scoreToken     = 0.9 // 字段中的某个词相同
+	scorePrefix    = 0.8 // 某个词以查询词开头
+	scoreSubstring = 0.6 // 字段值包含查询词
+	scoreFuzzy     = 0.5 // 编辑距离为 1，每多一次编辑减 0.1
+)
+
+// token 是字段值中的一个词，start 和 end 为字节位置
+type token struct {
+	text       string
+	start, end int
+}
+
+type span struct {
+	start, end int
+}
+
+// tokenize 将小写后的 value 按字母和数字以外的字符拆分，例如 alice.smith@example.com 拆为 alice、smith、example、com
+func tokenize(value string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range value {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{text: value[start:i], start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: value[start:], start: start, end: len(value)})
+	}
+	return tokens
+}
+
+// terms 返回查询中去重后的小写词
+func terms(text string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, tok := range tokenize(strings.ToLower(text)) {
+		if !seen[tok.text] {
+			seen[tok.text] = true
+			result = append(result, tok.text)
+		}
+	}
+	return result
+}
+
+// maxTypos 返回查询词允许的编辑距离，短词不容错，避免匹配过多
+func maxTypos(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// matchTerm 返回 term 与小写字段值 value 的匹配得分和位置，得分为 0 表示不匹配
+func matchTerm(term, value string, tokens []token) (float64, span) {
+	if value == term {
+		return scoreExact, span{0, len(value)}
+	}
+
+	best, where := 0.0, span{}
+	for _, tok := range tokens {
+		switch {
+		case tok.text == term && scoreToken > best:
+			best, where = scoreToken, span{tok.start, tok.end}
+		case strings.HasPrefix(tok.text, term) && scorePrefix > best:
+			best, where = scorePrefix, span{tok.start, tok.start + len(term)}
+		}
+	}
+	if best > 0 {
+		return best, where
+	}
+
+	if i := strings.Index(value, term); i >= 0 {
+		return scoreSubstring, span{i, i + len(term)}
+	}
+
+	limit := maxTypos(term)
+	if limit == 0 {
+		return 0, span{}
+	}
+	distance := limit + 1
+	n := utf8.RuneCountInString(term)
+	for _, tok := range tokens {
+		if d := editDistance(term, tok.text); d < distance {
+			distance, where = d, span{tok.start, tok.end}
+		}
+		// 与等长的前缀比较，输入未完成时也能容错
+		if prefix := runePrefix(tok.text, n); prefix != tok.text {
+			if d := editDistance(term, prefix); d < distance {
+				distance, where = d, span{tok.start, tok.start + len(prefix)}
+			}
+		}
+	}
+	if distance > limit {
+		return 0, span{}
+	}
+	return scoreFuzzy - 0.1*float64(distance-1), where
+}
+
+// rank 对候选记录打分，丢弃有查询词未匹配任何字段的记录，按得分从高到低、ID 从小到大排序
+func rank(fields []Field, queryTerms []string, docs []Document) []Hit {
+	hits := make([]Hit, 0, len(docs))
+	for _, doc := range docs {
+		values := make(map[string]string, len(fields))
+		tokens := make(map[string][]token, len(fields))
+		for _, field := range fields {
+			values[field.Name] = strings.ToLower(doc.Fields[field.Name])
+			tokens[field.Name] = tokenize(values[field.Name])
+		}
+
+		total := 0.0
+		spans := make(map[string][]span)
+		matched := true
+		for _, term := range queryTerms {
+			best, bestField, bestSpan := 0.0, "", span{}
+			for _, field := range fields {
+				score, where := matchTerm(term, values[field.Name], tokens[field.Name])
+				if score *= weight(field); score > best {
+					best, bestField, bestSpan = score, field.Name, where
+				}
+			}
+			if best == 0 {
+				matched = false
+				break
+			}
+			total += best
+			spans[bestField] = append(spans[bestField], bestSpan)
+		}
+		if !matched {
+			continue
+		}
+
+		highlights := make(map[string]string, len(spans))
+		for name, s := range spans {
+			highlights[name] = highlight(doc.Fields[name], values[name], s)
+		}
+		hits = append(hits, Hit{ID: doc.ID, Score: math.Round(total*1000) / 1000, Highlights: highlights})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
+
+// page 返回 hits 中 q 指定的一页
+func page(hits []Hit, q Query) *Result {
+	result := &Result{Hits: []Hit{}, Total: len(hits)}
+	if q.Offset < len(hits) {
+		end := q.Offset + q.Limit
+		if end > len(hits) {
+			end = len(hits)
+		}
+		result.Hits = hits[q.Offset:end]
+	}
+	return result
+}
+
+func weight(field Field) float64 {
+	if field.Weight <= 0 {
+		return 1
+	}
+	return field.Weight
+}
+
+// highlight 用 <em> 标记 spans 中的部分，其余部分做 HTML 转义。
+// spans 是小写值 lower 中的位置，小写改变了字节长度时（少数 Unicode 字符）在小写值上标记
+func highlight(value, lower string, spans []span) string {
+	if len(value) != len(lower) {
+		value = lower
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	pos := 0
+	for i := 0; i < len(spans); i++ {
+		s := spans[i]
+		// 合并重叠的位置
+		for i+1 < len(spans) && spans[i+1].start <= s.end {
+			if spans[i+1].end > s.end {
+				s.end = spans[i+1].end
+			}
+			i++
+		}
+		if s.start < pos {
+			s.start = pos
+		}
+		b.WriteString(html.EscapeString(value[pos:s.start]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(value[s.start:s.end]))
+		b.WriteString("</em>")
+		pos = s.end
+	}
+	b.WriteString(html.EscapeString(value[pos:]))
+	return b.String()
+}
+
+// editDistance 返回 a 和 b 按字符计算的编辑距离，相邻两个字符交换算一次编辑（OSA 距离）
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
+
+// runePrefix 返回 s 的前 n 个字符
+func runePrefix(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
@@ -0,0 +1,157 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// MemoryIndex 是进程内的倒排索引，索引项为词的三元组（trigram）。有拼写错误的词仍与原词共享
+// 大部分三元组，因此先按三元组取候选记录，再由 rank 精确打分。
+// 索引只保存在当前进程中，多实例部署时其他实例的修改需要定期重建索引才能看到
+type MemoryIndex struct {
+	fields []Field
+
+	mu    sync.RWMutex
+	docs  map[uint]Document
+	grams map[string]map[uint]struct{}
+}
+
+func NewMemoryIndex(fields ...Field) *MemoryIndex {
+	return &MemoryIndex{
+		fields: fields,
+		docs:   make(map[uint]Document),
+		grams:  make(map[string]map[uint]struct{}),
+	}
+}
+
+// Index 添加或替换记录
+func (m *MemoryIndex) Index(ctx context.Context, docs ...Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, doc := range docs {
+		m.remove(doc.ID)
+		// 只保存需要搜索的字段，调用方之后修改 doc.Fields 不影响索引
+		fields := make(map[string]string, len(m.fields))
+		for _, field := range m.fields {
+			fields[field.Name] = doc.Fields[field.Name]
+		}
+		m.docs[doc.ID] = Document{ID: doc.ID, Fields: fields}
+		for _, gram := range m.docGrams(fields) {
+			ids, ok := m.grams[gram]
+			if !ok {
+				ids = make(map[uint]struct{})
+				m.grams[gram] = ids
+			}
+			ids[doc.ID] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (m *MemoryIndex) Delete(ctx context.Context, ids ...uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		m.remove(id)
+	}
+	return nil
+}
+
+// Reset 用 docs 替换索引中的全部记录
+func (m *MemoryIndex) Reset(ctx context.Context, docs ...Document) error {
+	m.mu.Lock()
+	m.docs = make(map[uint]Document)
+	m.grams = make(map[string]map[uint]struct{})
+	m.mu.Unlock()
+	return m.Index(ctx, docs...)
+}
+
+// Len 返回索引中的记录数
+func (m *MemoryIndex) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.docs)
+}
+
+func (m *MemoryIndex) Search(ctx context.Context, q Query) (*Result, error) {
+	q, err := q.normalize()
+	if err != nil {
+		return nil, err
+	}
+	queryTerms := terms(q.Text)
+	if len(queryTerms) == 0 {
+		return page(nil, q), nil
+	}
+
+	m.mu.RLock()
+	docs := m.candidates(queryTerms)
+	m.mu.RUnlock()
+	return page(rank(m.fields, queryTerms, docs), q), nil
+}
+
+// candidates 返回与每个查询词都至少共享一个三元组的记录。少于 3 个字符的词不参与过滤
+func (m *MemoryIndex) candidates(queryTerms []string) []Document {
+	var matched map[uint]struct{}
+	for _, term := range queryTerms {
+		if utf8.RuneCountInString(term) < 3 {
+			continue
+		}
+		ids := make(map[uint]struct{})
+		for _, gram := range grams(term) {
+			for id := range m.grams[gram] {
+				if _, ok := matched[id]; ok || matched == nil {
+					ids[id] = struct{}{}
+				}
+			}
+		}
+		matched = ids
+	}
+
+	docs := make([]Document, 0, len(matched))
+	if matched == nil {
+		for _, doc := range m.docs {
+			docs = append(docs, doc)
+		}
+		return docs
+	}
+	for id := range matched {
+		docs = append(docs, m.docs[id])
+	}
+	return docs
+}
+
+func (m *MemoryIndex) remove(id uint) {
+	doc, ok := m.docs[id]
+	if !ok {
+		return
+	}
+	for _, gram := range m.docGrams(doc.Fields) {
+		delete(m.grams[gram], id)
+		if len(m.grams[gram]) == 0 {
+			delete(m.grams, gram)
+		}
+	}
+	delete(m.docs, id)
+}
+
+func (m *MemoryIndex) docGrams(fields map[string]string) []string {
+	var result []string
+	for _, field := range m.fields {
+		for _, tok := range tokenize(strings.ToLower(fields[field.Name])) {
+			result = append(result, grams(tok.text)...)
+		}
+	}
+	return result
+}
+
+// grams 返回词加上首尾标记后的三元组，例如 bob 为 ^bo、bob、ob$
+func grams(word string) []string {
+	runes := []rune("^" + word + "$")
+	result := make([]string, 0, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		result = append(result, string(runes[i:i+3]))
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+// Package search 提供带容错的全文搜索。DBIndex 使用数据库的全文索引（MySQL FULLTEXT、
+// PostgreSQL tsvector + pg_trgm、SQLite FTS5）查找候选记录，MemoryIndex 在进程内维护倒排索引，
+// 适合单实例的小规模部署。两者使用相同的规则打分和高亮，结果一致
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jtsang4/go-stater/config"
+	"gorm.io/gorm"
+)
+
+const (
+	BackendDB     = "db"
+	BackendMemory = "memory"
+)
+
+const (
+	// DefaultLimit 是未指定 Limit 时返回的结果数
+	DefaultLimit = 20
+	// MaxWindow 是 Offset + Limit 的上限，更深的分页没有意义，也会让候选集过大
+	MaxWindow = 500
+)
+
+// ErrWindowTooLarge 表示 Offset + Limit 超过了 MaxWindow
+var ErrWindowTooLarge = fmt.Errorf("search: offset + limit must not exceed %d", MaxWindow)
+
+// Field 是参与搜索的字段，Weight 越大，该字段匹配时得分越高
+type Field struct {
+	Name   string
+	Weight float64
+}
+
+// Document 是被索引的记录，Fields 的 key 为字段名
+type Document struct {
+	ID     uint
+	Fields map[string]string
+}
+
+// Query 是一次搜索，Text 按空白和标点拆分为多个词，每个词都必须匹配某个字段
+type Query struct {
+	Text   string
+	Limit  int
+	Offset int
+}
+
+// Hit 是一条搜索结果。Highlights 只包含匹配的字段，值已做 HTML 转义，匹配部分用 <em> 标记
+type Hit struct {
+	ID         uint              `json:"id"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Result 是按得分从高到低排列的一页结果，Total 为匹配的总数
+type Result struct {
+	Hits  []Hit `json:"hits"`
+	Total int   `json:"total"`
+}
+
+// IndexInterface 是搜索索引。DBIndex 的 Index 和 Delete 不做任何事，由数据库维护索引
+type IndexInterface interface {
+	Index(ctx context.Context, docs ...Document) error
+	Delete(ctx context.Context, ids ...uint) error
+	Search(ctx context.Context, q Query) (*Result, error)
+}
+
+// NewIndex 按 cfg.Backend 创建模型 T 的索引，fields 为 T 的列名
+func NewIndex[T any](cfg config.SearchConfig, db *gorm.DB, fields ...Field) (IndexInterface, error) {
+	switch cfg.Backend {
+	case "", BackendDB:
+		return NewDBIndex[T](db, fields...)
+	case BackendMemory:
+		return NewMemoryIndex(fields...), nil
+	default:
+		return nil, fmt.Errorf("unsupported search backend: %s", cfg.Backend)
+	}
+}
+
+// normalize 校验分页参数并设置默认值
+func (q Query) normalize() (Query, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	if q.Offset+q.Limit > MaxWindow {
+		return q, ErrWindowTooLarge
+	}
+	return q, nil
+}
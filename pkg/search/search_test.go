@@ -0,0 +1,165 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jtsang4/go-stater/pkg/database/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var testFields = []Field{{Name: "username", Weight: 2}, {Name: "email", Weight: 1}}
+
+type account struct {
+	ID        uint
+	Username  string
+	Email     string
+	DeletedAt gorm.DeletedAt
+}
+
+var testAccounts = []account{
+	{ID: 1, Username: "alice", Email: "alice@example.com"},
+	{ID: 2, Username: "alicia", Email: "alicia@corp.io"},
+	{ID: 3, Username: "bob", Email: "bob.smith@example.com"},
+	{ID: 4, Username: "carol", Email: "carol+<b>@example.com"},
+}
+
+func testDocs() []Document {
+	docs := make([]Document, len(testAccounts))
+	for i, a := range testAccounts {
+		docs[i] = Document{ID: a.ID, Fields: map[string]string{"username": a.Username, "email": a.Email}}
+	}
+	return docs
+}
+
+func ids(result *Result) []uint {
+	ids := make([]uint, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+// newTestDB 创建 accounts 表，FTS5 索引与迁移中的 users_fts 用同样的方式创建和同步
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := dbtest.New(t, &account{})
+	for _, stmt := range []string{
+		`CREATE VIRTUAL TABLE accounts_fts USING fts5(username, email, content='accounts', content_rowid='id', tokenize='trigram')`,
+		`CREATE TRIGGER accounts_fts_ai AFTER INSERT ON accounts BEGIN
+			INSERT INTO accounts_fts(rowid, username, email) VALUES (new.id, new.username, new.email); END`,
+		`CREATE TRIGGER accounts_fts_au AFTER UPDATE OF username, email ON accounts BEGIN
+			INSERT INTO accounts_fts(accounts_fts, rowid, username, email) VALUES ('delete', old.id, old.username, old.email);
+			INSERT INTO accounts_fts(rowid, username, email) VALUES (new.id, new.username, new.email); END`,
+	} {
+		require.NoError(t, db.Exec(stmt).Error)
+	}
+	accounts := append([]account(nil), testAccounts...)
+	require.NoError(t, db.Create(&accounts).Error)
+	return db
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryIndex(testFields...)
+	require.NoError(t, memory.Index(ctx, testDocs()...))
+	db, err := NewDBIndex[account](newTestDB(t), testFields...)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []uint
+	}{
+		{name: "exact username ranks first", query: "alice", want: []uint{1, 2}},
+		{name: "prefix", query: "ali", want: []uint{1, 2}},
+		{name: "substring", query: "lici", want: []uint{2}},
+		{name: "email token", query: "smith", want: []uint{3}},
+		{name: "typo", query: "alcie", want: []uint{1}},
+		{name: "typo in partial input", query: "smoth", want: []uint{3}},
+		{name: "all terms must match", query: "alice corp", want: []uint{2}},
+		{name: "short term", query: "b", want: []uint{3, 4}},
+		{name: "no match", query: "zed", want: []uint{}},
+		{name: "empty", query: " @. ", want: []uint{}},
+	}
+	for _, index := range []IndexInterface{memory, db} {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				result, err := index.Search(ctx, Query{Text: tt.query})
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, ids(result), "%T", index)
+				assert.Equal(t, len(tt.want), result.Total)
+			})
+		}
+	}
+}
+
+func TestSearchHighlightsAndPaging(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex(testFields...)
+	require.NoError(t, index.Index(ctx, testDocs()...))
+
+	result, err := index.Search(ctx, Query{Text: "Alice EXAMPLE"})
+	require.NoError(t, err)
+	require.Equal(t, []uint{1}, ids(result))
+	assert.Equal(t, map[string]string{"username": "<em>alice</em>", "email": "alice@<em>example</em>.com"}, result.Hits[0].Highlights)
+
+	result, err = index.Search(ctx, Query{Text: "smith example"})
+	require.NoError(t, err)
+	assert.Equal(t, "bob.<em>smith</em>@<em>example</em>.com", result.Hits[0].Highlights["email"])
+
+	// 高亮结果做 HTML 转义
+	result, err = index.Search(ctx, Query{Text: "carol b"})
+	require.NoError(t, err)
+	assert.Equal(t, "carol+&lt;<em>b</em>&gt;@example.com", result.Hits[0].Highlights["email"])
+
+	result, err = index.Search(ctx, Query{Text: "ali", Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{2}, ids(result))
+	assert.Equal(t, 2, result.Total)
+
+	_, err = index.Search(ctx, Query{Text: "ali", Limit: MaxWindow, Offset: 1})
+	assert.ErrorIs(t, err, ErrWindowTooLarge)
+}
+
+func TestMemoryIndexUpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemoryIndex(testFields...)
+	require.NoError(t, index.Index(ctx, testDocs()...))
+
+	require.NoError(t, index.Index(ctx, Document{ID: 1, Fields: map[string]string{"username": "zelda", "email": "zelda@example.com"}}))
+	require.NoError(t, index.Delete(ctx, 2))
+	assert.Equal(t, 3, index.Len())
+
+	result, err := index.Search(ctx, Query{Text: "alice"})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+	result, err = index.Search(ctx, Query{Text: "zelda"})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{1}, ids(result))
+
+	require.NoError(t, index.Reset(ctx, testDocs()[:1]...))
+	assert.Equal(t, 1, index.Len())
+}
+
+func TestDBIndexFollowsWrites(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	index, err := NewDBIndex[account](db, testFields...)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Model(&account{ID: 3}).Update("username", "robert").Error)
+	require.NoError(t, db.Delete(&account{ID: 1}).Error)
+
+	result, err := index.Search(ctx, Query{Text: "robert"})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{3}, ids(result))
+	result, err = index.Search(ctx, Query{Text: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{2}, ids(result), "soft-deleted records are excluded")
+
+	_, err = NewDBIndex[account](db, Field{Name: "nickname"})
+	assert.Error(t, err)
+}
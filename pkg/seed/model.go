@@ -0,0 +1,188 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jtsang4/go-stater/pkg/store"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Model 是可以在 YAML 种子中使用的模型，使用 NewModel 创建
+type Model interface {
+	seed(ctx context.Context, refs *Refs, alias string, fields map[string]interface{}) error
+}
+
+type model[T any] struct {
+	repo    store.RepositoryInterface[T]
+	keys    []string
+	prepare func(ctx context.Context, entity *T) error
+	schema  *schema.Schema
+}
+
+var schemaCache sync.Map
+
+// NewModel 创建 YAML 种子使用的模型。keys 是判断记录是否已存在的列，已存在时不会修改；
+// prepare 在创建前调用，例如对明文密码做哈希，可以为 nil
+func NewModel[T any](repo store.RepositoryInterface[T], keys []string, prepare func(ctx context.Context, entity *T) error) Model {
+	if len(keys) == 0 {
+		panic(fmt.Sprintf("seed: model %T needs at least one key column", *new(T)))
+	}
+	s, err := schema.Parse(new(T), &schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		panic(fmt.Sprintf("seed: parse %T: %v", *new(T), err))
+	}
+	return &model[T]{repo: repo, keys: keys, prepare: prepare, schema: s}
+}
+
+// Record 返回创建 entity 的步骤，用于 Go 定义的种子集。keys 列的值相同的记录已存在时使用已有记录；
+// alias 不为空时为记录设置别名
+func Record[T any](repo store.RepositoryInterface[T], keys []string, alias string, entity *T) Step {
+	m := NewModel(repo, keys, nil).(*model[T])
+	return func(ctx context.Context, refs *Refs) error {
+		// 事务重试时不能复用上次写入的主键
+		copied := *entity
+		_, err := m.createOrFind(ctx, refs, alias, &copied)
+		return err
+	}
+}
+
+func (m *model[T]) seed(ctx context.Context, refs *Refs, alias string, fields map[string]interface{}) error {
+	entity := new(T)
+	rv := reflect.ValueOf(entity).Elem()
+	for column, value := range fields {
+		field := m.schema.LookUpField(column)
+		if field == nil {
+			return fmt.Errorf("%s has no column %q", m.schema.Table, column)
+		}
+		value, err := resolveRef(refs, value)
+		if err != nil {
+			return err
+		}
+		if err := field.Set(ctx, rv, value); err != nil {
+			return fmt.Errorf("%s.%s: %w", m.schema.Table, column, err)
+		}
+	}
+	if m.prepare != nil {
+		if err := m.prepare(ctx, entity); err != nil {
+			return err
+		}
+	}
+	_, err := m.createOrFind(ctx, refs, alias, entity)
+	return err
+}
+
+// createOrFind 按 keys 查找已有记录（包括已软删除的，避免唯一索引冲突），不存在时创建 entity
+func (m *model[T]) createOrFind(ctx context.Context, refs *Refs, alias string, entity *T) (*T, error) {
+	rv := reflect.ValueOf(entity).Elem()
+	specs := []store.Spec{store.WithDeleted()}
+	for _, key := range m.keys {
+		field := m.schema.LookUpField(key)
+		if field == nil {
+			return nil, fmt.Errorf("%s has no column %q", m.schema.Table, key)
+		}
+		value, _ := field.ValueOf(ctx, rv)
+		specs = append(specs, store.Eq(field.DBName, value))
+	}
+
+	existing, err := m.repo.First(ctx, specs...)
+	switch {
+	case err == nil:
+		entity = existing
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := m.repo.Create(ctx, entity); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if alias != "" {
+		if err := refs.Set(alias, entity); err != nil {
+			return nil, err
+		}
+	}
+	return entity, nil
+}
+
+// resolveRef 将 "@alias" 替换为别名记录的主键，"@alias.column" 替换为该列的值，"@@" 开头表示字面量 "@"
+func resolveRef(refs *Refs, value interface{}) (interface{}, error) {
+	str, ok := value.(string)
+	if !ok || !strings.HasPrefix(str, "@") {
+		return value, nil
+	}
+	if strings.HasPrefix(str, "@@") {
+		return str[1:], nil
+	}
+
+	alias, column, _ := strings.Cut(str[1:], ".")
+	entity, ok := refs.Lookup(alias)
+	if !ok {
+		return nil, fmt.Errorf("unknown alias %q", alias)
+	}
+	s, err := schema.Parse(entity, &schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	field := s.PrioritizedPrimaryField
+	if column != "" {
+		field = s.LookUpField(column)
+	}
+	if field == nil {
+		return nil, fmt.Errorf("cannot resolve %q", str)
+	}
+	v, _ := field.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	return v, nil
+}
+
+// yamlSet 是 YAML 种子文件的结构
+type yamlSet struct {
+	Includes []string     `yaml:"includes"`
+	Records  []yamlRecord `yaml:"records"`
+}
+
+type yamlRecord struct {
+	Model  string                 `yaml:"model"`
+	Alias  string                 `yaml:"alias"`
+	Fields map[string]interface{} `yaml:"fields"`
+}
+
+// ParseYAML 解析 YAML 种子集：
+//
+//	includes: [dev]
+//	records:
+//	  - model: user
+//	    alias: admin
+//	    fields: {username: admin, email: admin@example.com}
+//	  - model: user_preference
+//	    fields: {user_id: "@admin", data: {locale: zh-CN}}
+//
+// fields 的 key 为列名，"@alias" 引用之前记录的主键
+func (s *Seeder) ParseYAML(name string, data []byte) (Set, error) {
+	var parsed yamlSet
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return Set{}, err
+	}
+
+	for i, record := range parsed.Records {
+		if _, ok := s.models[record.Model]; !ok {
+			return Set{}, fmt.Errorf("record %d: unknown model %q", i+1, record.Model)
+		}
+	}
+
+	step := func(ctx context.Context, refs *Refs) error {
+		for i, record := range parsed.Records {
+			if err := s.models[record.Model].seed(ctx, refs, record.Alias, record.Fields); err != nil {
+				return fmt.Errorf("record %d (%s): %w", i+1, record.Model, err)
+			}
+		}
+		return nil
+	}
+	return Set{Name: name, Includes: parsed.Includes, Steps: []Step{step}}, nil
+}
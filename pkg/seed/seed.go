@@ -0,0 +1,186 @@
+// Package seed 提供命名的种子数据集（例如 dev、demo、e2e）。
+// 种子集可以用 Go 或 YAML 定义，按 Includes 组合，重复运行不会产生重复数据
+package seed
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jtsang4/go-stater/pkg/database"
+)
+
+// Step 是种子集中的一步，必须是幂等的：数据已存在时使用已有数据而不是重复创建
+type Step func(ctx context.Context, refs *Refs) error
+
+// Set 是一组命名的种子数据，Includes 中的种子集先于 Steps 运行
+type Set struct {
+	Name     string
+	Includes []string
+	Steps    []Step
+}
+
+// Refs 保存种子数据的别名，后面的步骤可以通过别名引用之前创建或已存在的记录
+type Refs struct {
+	entities map[string]interface{}
+}
+
+func newRefs() *Refs {
+	return &Refs{entities: make(map[string]interface{})}
+}
+
+// Set 为 entity（模型指针）设置别名，别名已存在时返回错误
+func (r *Refs) Set(alias string, entity interface{}) error {
+	if _, ok := r.entities[alias]; ok {
+		return fmt.Errorf("seed: duplicate alias %q", alias)
+	}
+	r.entities[alias] = entity
+	return nil
+}
+
+// Lookup 返回别名对应的记录
+func (r *Refs) Lookup(alias string) (interface{}, bool) {
+	entity, ok := r.entities[alias]
+	return entity, ok
+}
+
+// Aliases 返回所有别名
+func (r *Refs) Aliases() []string {
+	aliases := make([]string, 0, len(r.entities))
+	for alias := range r.entities {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// Get 返回别名对应的 *T，别名不存在或类型不符时返回错误
+func Get[T any](r *Refs, alias string) (*T, error) {
+	entity, ok := r.Lookup(alias)
+	if !ok {
+		return nil, fmt.Errorf("seed: unknown alias %q", alias)
+	}
+	typed, ok := entity.(*T)
+	if !ok {
+		return nil, fmt.Errorf("seed: alias %q is %T, not %T", alias, entity, new(T))
+	}
+	return typed, nil
+}
+
+// Seeder 管理种子集和 YAML 中可用的模型
+type Seeder struct {
+	tx     database.TxManagerInterface
+	sets   map[string]Set
+	models map[string]Model
+}
+
+func NewSeeder(tx database.TxManagerInterface) *Seeder {
+	return &Seeder{tx: tx, sets: make(map[string]Set), models: make(map[string]Model)}
+}
+
+// RegisterModel 使 YAML 种子可以通过 name 创建该模型的记录
+func (s *Seeder) RegisterModel(name string, model Model) {
+	s.models[name] = model
+}
+
+// Register 注册种子集，同名的种子集会被覆盖
+func (s *Seeder) Register(sets ...Set) {
+	for _, set := range sets {
+		s.sets[set.Name] = set
+	}
+}
+
+// LoadFS 将 fsys 中的每个 *.yaml 文件注册为一个种子集，名称为去掉扩展名的文件名
+func (s *Seeder) LoadFS(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.yaml")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		set, err := s.ParseYAML(strings.TrimSuffix(path.Base(file), ".yaml"), data)
+		if err != nil {
+			return fmt.Errorf("seed: %s: %w", file, err)
+		}
+		s.Register(set)
+	}
+	return nil
+}
+
+// Sets 返回已注册的种子集名称
+func (s *Seeder) Sets() []string {
+	names := make([]string, 0, len(s.sets))
+	for name := range s.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run 在一个事务中依次运行 names 及其包含的种子集，每个种子集只运行一次。
+// 返回所有别名，测试中可以用它取得种子数据
+func (s *Seeder) Run(ctx context.Context, names ...string) (*Refs, error) {
+	order, err := s.resolve(names)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs *Refs
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// 事务可能重试，每次使用新的别名表
+		refs = newRefs()
+		for _, set := range order {
+			for i, step := range set.Steps {
+				if err := step(ctx, refs); err != nil {
+					return fmt.Errorf("seed %s step %d: %w", set.Name, i+1, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// resolve 按依赖顺序展开种子集，被包含的种子集排在前面
+func (s *Seeder) resolve(names []string) ([]Set, error) {
+	var order []Set
+	state := make(map[string]int) // 1: 展开中，2: 已完成
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("seed: include cycle %s", strings.Join(append(path, name), " -> "))
+		case 2:
+			return nil
+		}
+		set, ok := s.sets[name]
+		if !ok {
+			return fmt.Errorf("seed: unknown set %q", name)
+		}
+		state[name] = 1
+		for _, include := range set.Includes {
+			if err := visit(include, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		order = append(order, set)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
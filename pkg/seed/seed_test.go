@@ -0,0 +1,163 @@
+package seed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jtsang4/go-stater/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type team struct {
+	ID   uint
+	Name string `gorm:"uniqueIndex"`
+}
+
+type member struct {
+	ID     uint
+	TeamID uint
+	Email  string `gorm:"uniqueIndex"`
+	Note   string
+}
+
+// directTx 直接执行 fn
+type directTx struct{}
+
+func (directTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func newTestSeeder(t *testing.T) (*Seeder, *store.MemoryRepository[team], *store.MemoryRepository[member]) {
+	t.Helper()
+	teams := store.NewMemoryRepository[team]()
+	members := store.NewMemoryRepository[member]()
+	s := NewSeeder(directTx{})
+	s.RegisterModel("team", NewModel[team](teams, []string{"name"}, nil))
+	s.RegisterModel("member", NewModel[member](members, []string{"email"}, func(ctx context.Context, m *member) error {
+		if m.Note == "" {
+			m.Note = "default"
+		}
+		return nil
+	}))
+	return s, teams, members
+}
+
+func TestRunYAMLWithAliases(t *testing.T) {
+	ctx := context.Background()
+	s, teams, members := newTestSeeder(t)
+
+	base, err := s.ParseYAML("base", []byte(`
+records:
+  - model: team
+    alias: core
+    fields: {name: core}
+`))
+	require.NoError(t, err)
+	dev, err := s.ParseYAML("dev", []byte(`
+includes: [base]
+records:
+  - model: member
+    alias: alice
+    fields: {team_id: "@core", email: alice@example.com}
+  - model: member
+    fields: {team_id: "@alice.team_id", email: bob@example.com, note: "@@bob"}
+`))
+	require.NoError(t, err)
+	s.Register(base, dev)
+	assert.Equal(t, []string{"base", "dev"}, s.Sets())
+
+	for i := 0; i < 2; i++ {
+		refs, err := s.Run(ctx, "dev")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice", "core"}, refs.Aliases())
+
+		core, err := Get[team](refs, "core")
+		require.NoError(t, err)
+		alice, err := Get[member](refs, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, core.ID, alice.TeamID)
+		assert.Equal(t, "default", alice.Note)
+		_, err = Get[team](refs, "alice")
+		assert.Error(t, err)
+	}
+
+	// 第二次运行使用已有数据
+	n, err := teams.Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	all, err := members.Find(ctx, store.OrderBy("id"))
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, all[0].TeamID, all[1].TeamID)
+	assert.Equal(t, "@bob", all[1].Note)
+}
+
+func TestRunGoSet(t *testing.T) {
+	ctx := context.Background()
+	s, teams, _ := newTestSeeder(t)
+
+	var seen uint
+	s.Register(Set{Name: "teams", Steps: []Step{
+		Record(teams, []string{"name"}, "ops", &team{Name: "ops"}),
+		func(ctx context.Context, refs *Refs) error {
+			ops, err := Get[team](refs, "ops")
+			if err != nil {
+				return err
+			}
+			seen = ops.ID
+			return nil
+		},
+	}})
+
+	_, err := s.Run(ctx, "teams")
+	require.NoError(t, err)
+	first := seen
+	_, err = s.Run(ctx, "teams")
+	require.NoError(t, err)
+	assert.NotZero(t, first)
+	assert.Equal(t, first, seen)
+}
+
+func TestRunErrors(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newTestSeeder(t)
+	s.Register(
+		Set{Name: "a", Includes: []string{"b"}},
+		Set{Name: "b", Includes: []string{"a"}},
+		Set{Name: "c", Includes: []string{"missing"}},
+	)
+
+	_, err := s.Run(ctx, "a")
+	assert.ErrorContains(t, err, "include cycle a -> b -> a")
+	_, err = s.Run(ctx, "c")
+	assert.ErrorContains(t, err, `unknown set "missing"`)
+
+	dup, err := s.ParseYAML("dup", []byte(`
+records:
+  - {model: team, alias: x, fields: {name: one}}
+  - {model: team, alias: x, fields: {name: two}}
+`))
+	require.NoError(t, err)
+	unknownAlias, err := s.ParseYAML("unknown_alias", []byte(`
+records:
+  - {model: member, fields: {team_id: "@nobody", email: x@example.com}}
+`))
+	require.NoError(t, err)
+	unknownColumn, err := s.ParseYAML("unknown_column", []byte(`
+records:
+  - {model: team, fields: {title: x}}
+`))
+	require.NoError(t, err)
+	s.Register(dup, unknownAlias, unknownColumn)
+
+	_, err = s.Run(ctx, "dup")
+	assert.ErrorContains(t, err, `duplicate alias "x"`)
+	_, err = s.Run(ctx, "unknown_alias")
+	assert.ErrorContains(t, err, `unknown alias "nobody"`)
+	_, err = s.Run(ctx, "unknown_column")
+	assert.ErrorContains(t, err, `no column "title"`)
+
+	_, err = s.ParseYAML("bad", []byte("records:\n  - {model: project}\n"))
+	assert.ErrorContains(t, err, `unknown model "project"`)
+}
@@ -0,0 +1,685 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// MemoryRepository 是 RepositoryInterface 的内存实现，用于服务层测试，代替手写的 mock。
+// 支持主键自增、默认值、创建/更新时间、唯一索引、软删除、版本号和 Before* 钩子，行为与 Repository 一致；
+// 不支持事务回滚、预加载和 SpecFunc，加锁子句被忽略
+type MemoryRepository[T any] struct {
+	mu      sync.RWMutex
+	schema  *schema.Schema
+	version *schema.Field
+	unique  [][]*schema.Field
+	rows    []*T
+	nextID  uint64
+	now     func() time.Time
+}
+
+func NewMemoryRepository[T any]() *MemoryRepository[T] {
+	s := mustParse[T](nil)
+	m := &MemoryRepository[T]{schema: s, version: versionField(s), now: time.Now}
+	if len(s.PrimaryFields) > 0 {
+		m.unique = append(m.unique, s.PrimaryFields)
+	}
+	for _, idx := range s.ParseIndexes() {
+		if idx.Class != "UNIQUE" {
+			continue
+		}
+		fields := make([]*schema.Field, 0, len(idx.Fields))
+		for _, f := range idx.Fields {
+			fields = append(fields, f.Field)
+		}
+		m.unique = append(m.unique, fields)
+	}
+	return m
+}
+
+func (m *MemoryRepository[T]) Create(ctx context.Context, entity *T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.create(ctx, entity)
+}
+
+func (m *MemoryRepository[T]) CreateInBatches(ctx context.Context, entities []*T, batchSize int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, entity := range entities {
+		if err := m.create(ctx, entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryRepository[T]) Upsert(ctx context.Context, entities []*T, conflictColumns ...string) error {
+	if m.version != nil {
+		return fmt.Errorf("%s: %w", m.schema.Name, ErrUpsertVersioned)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conflict := m.schema.PrimaryFields
+	if len(conflictColumns) > 0 {
+		conflict = make([]*schema.Field, 0, len(conflictColumns))
+		for _, column := range conflictColumns {
+			field := m.schema.LookUpField(column)
+			if field == nil {
+				return fmt.Errorf("store: unknown column %q", column)
+			}
+			conflict = append(conflict, field)
+		}
+	}
+
+	for _, entity := range entities {
+		rv := reflect.ValueOf(entity).Elem()
+		i := m.indexWhere(ctx, func(row reflect.Value) bool { return equalFields(ctx, conflict, row, rv) })
+		if i < 0 {
+			if err := m.create(ctx, entity); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// 保留已有记录的主键和创建时间，其余字段整体覆盖
+		stored := reflect.ValueOf(m.rows[i]).Elem()
+		for _, field := range m.schema.Fields {
+			if field.PrimaryKey || field.AutoCreateTime > 0 {
+				value, _ := valueOf(ctx, field, stored)
+				if err := field.Set(ctx, rv, value); err != nil {
+					return err
+				}
+			}
+		}
+		m.touch(ctx, rv)
+		row := *entity
+		m.rows[i] = &row
+	}
+	return nil
+}
+
+func (m *MemoryRepository[T]) GetByID(ctx context.Context, id interface{}, specs ...Spec) (*T, error) {
+	return m.First(ctx, append(specs, Eq(clause.PrimaryKey, id))...)
+}
+
+func (m *MemoryRepository[T]) First(ctx context.Context, specs ...Spec) (*T, error) {
+	hasOrder := false
+	for _, spec := range specs {
+		if _, ok := spec.(order); ok {
+			hasOrder = true
+		}
+	}
+	if !hasOrder && m.schema.PrioritizedPrimaryField != nil {
+		specs = append(specs, OrderBy(clause.PrimaryKey))
+	}
+
+	rows, err := m.Find(ctx, append(specs, Limit(1, 0))...)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return rows[0], nil
+}
+
+func (m *MemoryRepository[T]) Find(ctx context.Context, specs ...Spec) ([]*T, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	q, err := m.parse(specs)
+	if err != nil {
+		return nil, err
+	}
+	matched := m.match(ctx, q)
+
+	if len(q.orders) > 0 {
+		sort.SliceStable(matched, func(i, j int) bool {
+			a, b := reflect.ValueOf(matched[i]).Elem(), reflect.ValueOf(matched[j]).Elem()
+			for _, o := range q.orders {
+				field := m.field(o.column)
+				av, _ := valueOf(ctx, field, a)
+				bv, _ := valueOf(ctx, field, b)
+				c, _ := compare(av, bv)
+				if c != 0 {
+					return (c < 0) != o.desc
+				}
+			}
+			return false
+		})
+	}
+
+	// 分页按出现顺序依次作用，最后一个 Limit 生效
+	for _, p := range q.pages {
+		if p.offset >= len(matched) {
+			matched = nil
+			continue
+		}
+		matched = matched[p.offset:]
+		if p.limit > 0 && p.limit < len(matched) {
+			matched = matched[:p.limit]
+		}
+	}
+
+	result := make([]*T, len(matched))
+	for i, row := range matched {
+		c := *row
+		result[i] = &c
+	}
+	return result, nil
+}
+
+func (m *MemoryRepository[T]) Count(ctx context.Context, specs ...Spec) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	q, err := m.parse(specs)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(m.match(ctx, q))), nil
+}
+
+func (m *MemoryRepository[T]) Update(ctx context.Context, entity *T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, err := m.locate(ctx, entity)
+	if err != nil {
+		return err
+	}
+	updated := *entity
+	if err := beforeHooks(&updated, false); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(&updated).Elem()
+	stored := reflect.ValueOf(m.rows[i]).Elem()
+	for _, field := range m.schema.Fields {
+		if field.AutoCreateTime > 0 {
+			value, _ := valueOf(ctx, field, stored)
+			if err := field.Set(ctx, rv, value); err != nil {
+				return err
+			}
+		}
+	}
+	if err := m.bumpVersion(ctx, rv); err != nil {
+		return err
+	}
+	m.touch(ctx, rv)
+	if err := m.checkUnique(ctx, rv, i); err != nil {
+		return err
+	}
+
+	row := updated
+	m.rows[i] = &row
+	*entity = updated
+	return nil
+}
+
+func (m *MemoryRepository[T]) UpdateColumns(ctx context.Context, entity *T, values map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, err := m.locate(ctx, entity)
+	if err != nil {
+		return err
+	}
+	row := *m.rows[i]
+	rv := reflect.ValueOf(&row).Elem()
+	for column, value := range values {
+		field := m.field(column)
+		if field == nil {
+			return fmt.Errorf("store: unknown column %q", column)
+		}
+		if err := field.Set(ctx, rv, value); err != nil {
+			return err
+		}
+	}
+	if err := m.bumpVersion(ctx, rv); err != nil {
+		return err
+	}
+	m.touch(ctx, rv)
+	if err := m.checkUnique(ctx, rv, i); err != nil {
+		return err
+	}
+	m.rows[i] = &row
+
+	// 与 Repository 一致，只同步更新的列、版本号和更新时间
+	updated := reflect.ValueOf(entity).Elem()
+	for _, field := range m.schema.Fields {
+		_, changed := values[field.DBName]
+		if changed || field == m.version || field.AutoUpdateTime > 0 {
+			value, _ := valueOf(ctx, field, rv)
+			if err := field.Set(ctx, updated, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *MemoryRepository[T]) Delete(ctx context.Context, entity *T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i, err := m.locate(ctx, entity)
+	if err != nil {
+		return err
+	}
+	m.remove(ctx, i)
+	return nil
+}
+
+func (m *MemoryRepository[T]) DeleteWhere(ctx context.Context, specs ...Spec) (int64, error) {
+	if len(specs) == 0 {
+		return 0, gorm.ErrMissingWhereClause
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, err := m.parse(specs)
+	if err != nil {
+		return 0, err
+	}
+	matched := m.match(ctx, q)
+	for _, row := range matched {
+		for i := range m.rows {
+			if m.rows[i] == row {
+				m.remove(ctx, i)
+				break
+			}
+		}
+	}
+	return int64(len(matched)), nil
+}
+
+func (m *MemoryRepository[T]) create(ctx context.Context, entity *T) error {
+	if err := beforeHooks(entity, true); err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(entity).Elem()
+	for _, field := range m.schema.Fields {
+		if _, zero := valueOf(ctx, field, rv); !zero {
+			continue
+		}
+		switch {
+		case field.AutoIncrement || (field == m.schema.PrioritizedPrimaryField && (field.DataType == schema.Int || field.DataType == schema.Uint)):
+			m.nextID++
+			if err := field.Set(ctx, rv, m.nextID); err != nil {
+				return err
+			}
+		case field.HasDefaultValue && field.DefaultValueInterface != nil:
+			if err := field.Set(ctx, rv, field.DefaultValueInterface); err != nil {
+				return err
+			}
+		case field.AutoCreateTime > 0 || field.AutoUpdateTime > 0:
+			if err := field.Set(ctx, rv, m.now()); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 显式指定的主键也要推进自增序列，避免之后分配到相同的值
+	if pk := m.schema.PrioritizedPrimaryField; pk != nil {
+		if id, _ := pk.ValueOf(ctx, rv); id != nil {
+			if n, ok := toUint(id); ok && n > m.nextID {
+				m.nextID = n
+			}
+		}
+	}
+
+	if err := m.checkUnique(ctx, rv, -1); err != nil {
+		return err
+	}
+	row := *entity
+	m.rows = append(m.rows, &row)
+	return nil
+}
+
+// locate 返回 entity 对应的未删除记录的位置，版本号不一致时返回 ErrVersionConflict
+func (m *MemoryRepository[T]) locate(ctx context.Context, entity *T) (int, error) {
+	rv := reflect.ValueOf(entity).Elem()
+	i := m.indexWhere(ctx, func(row reflect.Value) bool {
+		return !m.deleted(ctx, row) && equalFields(ctx, m.schema.PrimaryFields, row, rv)
+	})
+	if i >= 0 && m.version != nil && !equalFields(ctx, []*schema.Field{m.version}, reflect.ValueOf(m.rows[i]).Elem(), rv) {
+		i = -1
+	}
+	if i < 0 {
+		if m.version != nil {
+			return -1, ErrVersionConflict
+		}
+		return -1, gorm.ErrRecordNotFound
+	}
+	return i, nil
+}
+
+func (m *MemoryRepository[T]) remove(ctx context.Context, i int) {
+	if field := m.schema.LookUpField("DeletedAt"); field != nil {
+		row := *m.rows[i]
+		if err := field.Set(ctx, reflect.ValueOf(&row).Elem(), m.now()); err == nil {
+			m.rows[i] = &row
+			return
+		}
+	}
+	m.rows = append(m.rows[:i], m.rows[i+1:]...)
+}
+
+func (m *MemoryRepository[T]) bumpVersion(ctx context.Context, rv reflect.Value) error {
+	if m.version == nil {
+		return nil
+	}
+	current, _ := m.version.ValueOf(ctx, rv)
+	next, err := increment(current)
+	if err != nil {
+		return err
+	}
+	return m.version.Set(ctx, rv, next)
+}
+
+func (m *MemoryRepository[T]) touch(ctx context.Context, rv reflect.Value) {
+	for _, field := range m.schema.Fields {
+		if field.AutoUpdateTime > 0 {
+			_ = field.Set(ctx, rv, m.now())
+		}
+	}
+}
+
+// checkUnique 检查 rv 是否与 skip 之外的记录（包括已软删除的记录）违反唯一索引
+func (m *MemoryRepository[T]) checkUnique(ctx context.Context, rv reflect.Value, skip int) error {
+	for _, fields := range m.unique {
+		for i, row := range m.rows {
+			if i != skip && equalFields(ctx, fields, reflect.ValueOf(row).Elem(), rv) {
+				return gorm.ErrDuplicatedKey
+			}
+		}
+	}
+	return nil
+}
+
+func (m *MemoryRepository[T]) indexWhere(ctx context.Context, pred func(row reflect.Value) bool) int {
+	for i, row := range m.rows {
+		if pred(reflect.ValueOf(row).Elem()) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *MemoryRepository[T]) deleted(ctx context.Context, rv reflect.Value) bool {
+	field := m.schema.LookUpField("DeletedAt")
+	if field == nil {
+		return false
+	}
+	value, _ := valueOf(ctx, field, rv)
+	return normalize(value) != nil
+}
+
+func (m *MemoryRepository[T]) field(column string) *schema.Field {
+	if column == clause.PrimaryKey {
+		return m.schema.PrioritizedPrimaryField
+	}
+	return m.schema.LookUpField(column)
+}
+
+type memoryQuery struct {
+	filters     []filter
+	orders      []order
+	pages       []page
+	withDeleted bool
+}
+
+func (m *MemoryRepository[T]) parse(specs []Spec) (*memoryQuery, error) {
+	q := &memoryQuery{}
+	for _, spec := range specs {
+		switch s := spec.(type) {
+		case filter:
+			if m.field(s.column) == nil {
+				return nil, fmt.Errorf("store: unknown column %q", s.column)
+			}
+			q.filters = append(q.filters, s)
+		case order:
+			if m.field(s.column) == nil {
+				return nil, fmt.Errorf("store: unknown column %q", s.column)
+			}
+			q.orders = append(q.orders, s)
+		case page:
+			q.pages = append(q.pages, s)
+		case withDeleted:
+			q.withDeleted = true
+		case preload, locking:
+		default:
+			return nil, fmt.Errorf("store: %T is not supported by MemoryRepository", spec)
+		}
+	}
+	return q, nil
+}
+
+func (m *MemoryRepository[T]) match(ctx context.Context, q *memoryQuery) []*T {
+	var matched []*T
+	for _, row := range m.rows {
+		rv := reflect.ValueOf(row).Elem()
+		if !q.withDeleted && m.deleted(ctx, rv) {
+			continue
+		}
+		ok := true
+		for _, f := range q.filters {
+			value, _ := valueOf(ctx, m.field(f.column), rv)
+			if !f.match(value) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, row)
+		}
+	}
+	return matched
+}
+
+func (f filter) match(value interface{}) bool {
+	if f.op == opIn {
+		for _, v := range f.values {
+			if c, ok := compare(value, v); ok && c == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	// 与 SQL 一致：和 NULL 比较只有 IS NULL / IS NOT NULL 有意义
+	value, target := normalize(value), normalize(f.value)
+	if target == nil || value == nil {
+		switch f.op {
+		case opEq:
+			return value == nil && target == nil
+		case opNe:
+			return target == nil && value != nil
+		default:
+			return false
+		}
+	}
+
+	c, ok := compare(value, target)
+	if !ok {
+		return false
+	}
+	switch f.op {
+	case opEq:
+		return c == 0
+	case opNe:
+		return c != 0
+	case opGt:
+		return c > 0
+	case opGte:
+		return c >= 0
+	case opLt:
+		return c < 0
+	default:
+		return c <= 0
+	}
+}
+
+// beforeHooks 按 GORM 的顺序调用 BeforeSave 和 BeforeCreate（create 为 true）或 BeforeUpdate 钩子，tx 参数为 nil
+func beforeHooks(entity interface{}, create bool) error {
+	if hook, ok := entity.(interface{ BeforeSave(*gorm.DB) error }); ok {
+		if err := hook.BeforeSave(nil); err != nil {
+			return err
+		}
+	}
+	if hook, ok := entity.(interface{ BeforeCreate(*gorm.DB) error }); ok && create {
+		return hook.BeforeCreate(nil)
+	}
+	if hook, ok := entity.(interface{ BeforeUpdate(*gorm.DB) error }); ok && !create {
+		return hook.BeforeUpdate(nil)
+	}
+	return nil
+}
+
+// valueOf 返回字段的值。使用序列化器的字段（serializer 标签）ValueOf 返回的是序列化器的包装，
+// 内存存储不做序列化，这里取字段本身的值
+func valueOf(ctx context.Context, field *schema.Field, rv reflect.Value) (interface{}, bool) {
+	if field.Serializer != nil {
+		v := field.ReflectValueOf(ctx, rv)
+		return v.Interface(), v.IsZero()
+	}
+	return field.ValueOf(ctx, rv)
+}
+
+func equalFields(ctx context.Context, fields []*schema.Field, a, b reflect.Value) bool {
+	for _, field := range fields {
+		av, _ := valueOf(ctx, field, a)
+		bv, _ := valueOf(ctx, field, b)
+		if normalize(av) == nil || normalize(bv) == nil {
+			// NULL 不与任何值相等，唯一索引允许多个 NULL
+			return false
+		}
+		if c, ok := compare(av, bv); !ok || c != 0 {
+			return false
+		}
+	}
+	return len(fields) > 0
+}
+
+// normalize 将值转换为可比较的基本类型：解引用指针，driver.Valuer 取其数据库值
+func normalize(v interface{}) interface{} {
+	if valuer, ok := v.(driver.Valuer); ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		value, err := valuer.Value()
+		if err != nil {
+			return nil
+		}
+		v = value
+	}
+	rv := reflect.ValueOf(v)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// compare 比较两个值，类型不可比较时 ok 为 false。数字之间按数值比较
+func compare(a, b interface{}) (c int, ok bool) {
+	a, b = normalize(a), normalize(b)
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	if ai, aok := toInt(a); aok {
+		if bi, bok := toInt(b); bok {
+			return cmpOrdered(ai, bi), true
+		}
+	}
+	if af, aok := toFloat(a); aok {
+		if bf, bok := toFloat(b); bok {
+			return cmpOrdered(af, bf), true
+		}
+	}
+
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return cmpOrdered(av, bv), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			if av == bv {
+				return 0, true
+			}
+			if !av {
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv), true
+		}
+	}
+	return 0, false
+}
+
+func cmpOrdered[V int64 | float64 | string](a, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func toInt(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= 1<<63-1 {
+			return int64(u), true
+		}
+	}
+	return 0, false
+}
+
+func toUint(v interface{}) (uint64, bool) {
+	i, ok := toInt(v)
+	if !ok || i < 0 {
+		return 0, false
+	}
+	return uint64(i), true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	if i, ok := toInt(v); ok {
+		return float64(i), true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/jtsang4/go-stater/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrVersionConflict 表示记录在读取之后已被其他请求修改或删除
+var ErrVersionConflict = errors.New("record was modified concurrently")
+
+// ErrUpsertVersioned 表示对有版本号的模型调用了 Upsert。
+// ON CONFLICT 更新无法检查版本号，会覆盖并发修改，应先查询再 Create 或 Update
+var ErrUpsertVersioned = errors.New("upsert is not supported for versioned models")
+
+// RepositoryInterface 是模型 T 的通用增删改查。
+// 模型有 DeletedAt 字段时删除为软删除，查询默认排除已删除的记录（见 WithDeleted）；
+// 模型有整数类型的 Version 字段时，Update、UpdateColumns 和 Delete 检查版本号（乐观锁），
+// 版本不一致时返回 ErrVersionConflict，更新成功后版本号加 1
+type RepositoryInterface[T any] interface {
+	Create(ctx context.Context, entity *T) error
+	// CreateInBatches 每 batchSize 条执行一次 INSERT
+	CreateInBatches(ctx context.Context, entities []*T, batchSize int) error
+	// Upsert 插入记录，conflictColumns 上已有记录时更新除主键和创建时间外的所有字段，
+	// conflictColumns 为空时使用主键；有版本号的模型返回 ErrUpsertVersioned
+	Upsert(ctx context.Context, entities []*T, conflictColumns ...string) error
+	// GetByID 按主键查询，不存在时返回 gorm.ErrRecordNotFound
+	GetByID(ctx context.Context, id interface{}, specs ...Spec) (*T, error)
+	// First 返回第一条匹配的记录，没有指定排序时按主键排序
+	First(ctx context.Context, specs ...Spec) (*T, error)
+	Find(ctx context.Context, specs ...Spec) ([]*T, error)
+	// Count 返回匹配的记录数，specs 中不应包含分页
+	Count(ctx context.Context, specs ...Spec) (int64, error)
+	// Update 按主键保存所有字段（包括零值），记录不存在时返回 gorm.ErrRecordNotFound
+	Update(ctx context.Context, entity *T) error
+	// UpdateColumns 按主键更新 values 中的列（key 为列名），并同步到 entity
+	UpdateColumns(ctx context.Context, entity *T, values map[string]interface{}) error
+	// Delete 按主键删除，记录不存在时返回 gorm.ErrRecordNotFound
+	Delete(ctx context.Context, entity *T) error
+	// DeleteWhere 删除所有匹配的记录，返回删除的行数，specs 不能为空
+	DeleteWhere(ctx context.Context, specs ...Spec) (int64, error)
+}
+
+// Repository 是基于 GORM 的 RepositoryInterface 实现，ctx 中有事务时使用该事务。
+// 具体模型的仓储嵌入 Repository 并只实现模型特有的查询
+type Repository[T any] struct {
+	db      *gorm.DB
+	schema  *schema.Schema
+	version *schema.Field
+}
+
+// NewRepository 解析模型 T 的结构，T 不是合法的 GORM 模型时 panic
+func NewRepository[T any](db *gorm.DB) *Repository[T] {
+	s := mustParse[T](db.NamingStrategy)
+	return &Repository[T]{db: db, schema: s, version: versionField(s)}
+}
+
+// DB 返回绑定 ctx 的连接，ctx 中有事务时返回该事务，用于实现模型特有的查询
+func (r *Repository[T]) DB(ctx context.Context) *gorm.DB {
+	return database.FromContext(ctx, r.db)
+}
+
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return r.DB(ctx).Create(entity).Error
+}
+
+func (r *Repository[T]) CreateInBatches(ctx context.Context, entities []*T, batchSize int) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	return r.DB(ctx).CreateInBatches(entities, batchSize).Error
+}
+
+func (r *Repository[T]) Upsert(ctx context.Context, entities []*T, conflictColumns ...string) error {
+	if r.version != nil {
+		return fmt.Errorf("%s: %w", r.schema.Name, ErrUpsertVersioned)
+	}
+	if len(entities) == 0 {
+		return nil
+	}
+	onConflict := clause.OnConflict{UpdateAll: true}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	return r.DB(ctx).Clauses(onConflict).Create(entities).Error
+}
+
+func (r *Repository[T]) GetByID(ctx context.Context, id interface{}, specs ...Spec) (*T, error) {
+	return r.First(ctx, append(specs, Eq(clause.PrimaryKey, id))...)
+}
+
+func (r *Repository[T]) First(ctx context.Context, specs ...Spec) (*T, error) {
+	var entity T
+	if err := apply(r.DB(ctx), specs).First(&entity).Error; err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+func (r *Repository[T]) Find(ctx context.Context, specs ...Spec) ([]*T, error) {
+	var entities []*T
+	if err := apply(r.DB(ctx), specs).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+func (r *Repository[T]) Count(ctx context.Context, specs ...Spec) (int64, error) {
+	var count int64
+	err := apply(r.DB(ctx).Model(new(T)), specs).Count(&count).Error
+	return count, err
+}
+
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	updated := *entity
+	db, err := r.versioned(r.DB(ctx).Model(&updated), entity, &updated)
+	if err != nil {
+		return err
+	}
+
+	omit := make([]string, 0, len(r.schema.PrimaryFieldDBNames)+1)
+	omit = append(omit, r.schema.PrimaryFieldDBNames...)
+	for _, field := range r.schema.Fields {
+		if field.AutoCreateTime > 0 {
+			omit = append(omit, field.DBName)
+		}
+	}
+
+	result := db.Select("*").Omit(omit...).Updates(&updated)
+	if err := r.checkAffected(result); err != nil {
+		return err
+	}
+	*entity = updated
+	return nil
+}
+
+func (r *Repository[T]) UpdateColumns(ctx context.Context, entity *T, values map[string]interface{}) error {
+	updated := *entity
+	db, err := r.versioned(r.DB(ctx).Model(&updated), entity, &updated)
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]interface{}, len(values)+1)
+	for column, value := range values {
+		columns[column] = value
+	}
+	if r.version != nil {
+		version, _ := r.version.ValueOf(ctx, reflect.ValueOf(&updated).Elem())
+		columns[r.version.DBName] = version
+	}
+
+	result := db.Updates(columns)
+	if err := r.checkAffected(result); err != nil {
+		return err
+	}
+	// GORM 只回填部分字段，这里统一写入 entity
+	rv := reflect.ValueOf(&updated).Elem()
+	for column, value := range values {
+		if field := r.schema.LookUpField(column); field != nil {
+			if err := field.Set(ctx, rv, value); err != nil {
+				return err
+			}
+		}
+	}
+	*entity = updated
+	return nil
+}
+
+func (r *Repository[T]) Delete(ctx context.Context, entity *T) error {
+	db := r.DB(ctx)
+	if r.version != nil {
+		version, _ := r.version.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+		db = db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: r.version.DBName}, Value: version})
+	}
+	return r.checkAffected(db.Delete(entity))
+}
+
+func (r *Repository[T]) DeleteWhere(ctx context.Context, specs ...Spec) (int64, error) {
+	if len(specs) == 0 {
+		return 0, gorm.ErrMissingWhereClause
+	}
+	result := apply(r.DB(ctx), specs).Delete(new(T))
+	return result.RowsAffected, result.Error
+}
+
+// versioned 为有版本号的模型添加 version = 当前版本 的条件，并将 updated 的版本号加 1
+func (r *Repository[T]) versioned(db *gorm.DB, entity, updated *T) (*gorm.DB, error) {
+	if r.version == nil {
+		return db, nil
+	}
+	ctx := db.Statement.Context
+	current, _ := r.version.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	next, err := increment(current)
+	if err != nil {
+		return nil, fmt.Errorf("%s.%s: %w", r.schema.Name, r.version.Name, err)
+	}
+	if err := r.version.Set(ctx, reflect.ValueOf(updated).Elem(), next); err != nil {
+		return nil, err
+	}
+	return db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: r.version.DBName}, Value: current}), nil
+}
+
+// checkAffected 在没有匹配的行时返回 ErrVersionConflict（有版本号的模型）或 gorm.ErrRecordNotFound
+func (r *Repository[T]) checkAffected(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	if r.version != nil {
+		return ErrVersionConflict
+	}
+	return gorm.ErrRecordNotFound
+}
+
+var schemaCache sync.Map
+
+func mustParse[T any](namer schema.Namer) *schema.Schema {
+	if namer == nil {
+		namer = schema.NamingStrategy{}
+	}
+	s, err := schema.Parse(new(T), &schemaCache, namer)
+	if err != nil {
+		panic(fmt.Sprintf("store: parse %T: %v", *new(T), err))
+	}
+	return s
+}
+
+// versionField 返回整数类型的 Version 字段，没有时返回 nil
+func versionField(s *schema.Schema) *schema.Field {
+	field := s.LookUpField("Version")
+	if field == nil || (field.DataType != schema.Int && field.DataType != schema.Uint) {
+		return nil
+	}
+	return field
+}
+
+func increment(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() + 1, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() + 1, nil
+	default:
+		return nil, fmt.Errorf("unsupported version type %T", v)
+	}
+}
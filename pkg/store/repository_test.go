@@ -0,0 +1,241 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jtsang4/go-stater/pkg/database/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type widget struct {
+	ID        uint   `gorm:"primarykey"`
+	Name      string `gorm:"size:32;uniqueIndex;not null"`
+	Kind      string `gorm:"size:16;not null;default:basic"`
+	Weight    int
+	Note      *string
+	Version   uint `gorm:"not null;default:1"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (w *widget) BeforeCreate(tx *gorm.DB) error {
+	if w.Version == 0 {
+		w.Version = 1
+	}
+	return nil
+}
+
+// setting 没有自增主键、版本号和软删除
+type setting struct {
+	Key   string `gorm:"primarykey;size:32"`
+	Value string
+}
+
+// label 的 Slug 由 BeforeSave 钩子维护
+type label struct {
+	ID   uint   `gorm:"primarykey"`
+	Name string `gorm:"size:32"`
+	Slug string `gorm:"size:32;uniqueIndex"`
+}
+
+func (l *label) BeforeSave(tx *gorm.DB) error {
+	l.Slug = strings.ToLower(l.Name)
+	return nil
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	return dbtest.New(t, &widget{}, &setting{}, &label{})
+}
+
+// implementations 返回同一组测试所针对的 GORM 实现和内存实现，二者行为应当一致
+func implementations[T any](t *testing.T) map[string]RepositoryInterface[T] {
+	return map[string]RepositoryInterface[T]{
+		"gorm":   NewRepository[T](newTestDB(t)),
+		"memory": NewMemoryRepository[T](),
+	}
+}
+
+func TestRepositoryCRUD(t *testing.T) {
+	for name, repo := range implementations[widget](t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			w := &widget{Name: "a", Weight: 3}
+			require.NoError(t, repo.Create(ctx, w))
+			assert.NotZero(t, w.ID)
+			assert.Equal(t, uint(1), w.Version)
+			assert.False(t, w.CreatedAt.IsZero())
+			assert.ErrorIs(t, repo.Create(ctx, &widget{Name: "a"}), gorm.ErrDuplicatedKey)
+
+			got, err := repo.GetByID(ctx, w.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "a", got.Name)
+			assert.Equal(t, "basic", got.Kind)
+
+			_, err = repo.GetByID(ctx, w.ID+100)
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+			// 零值也会被保存
+			got.Weight = 0
+			require.NoError(t, repo.Update(ctx, got))
+			assert.Equal(t, uint(2), got.Version)
+			got, err = repo.GetByID(ctx, w.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 0, got.Weight)
+			assert.WithinDuration(t, w.CreatedAt, got.CreatedAt, time.Second)
+
+			require.NoError(t, repo.UpdateColumns(ctx, got, map[string]interface{}{"kind": "fancy"}))
+			assert.Equal(t, "fancy", got.Kind)
+			assert.Equal(t, uint(3), got.Version)
+
+			// 使用旧版本号的写入失败
+			w.Weight = 10
+			assert.ErrorIs(t, repo.Update(ctx, w), ErrVersionConflict)
+			assert.Equal(t, uint(1), w.Version)
+			assert.ErrorIs(t, repo.Delete(ctx, w), ErrVersionConflict)
+
+			require.NoError(t, repo.Delete(ctx, got))
+			_, err = repo.GetByID(ctx, w.ID)
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			deleted, err := repo.GetByID(ctx, w.ID, WithDeleted())
+			require.NoError(t, err)
+			assert.True(t, deleted.DeletedAt.Valid)
+		})
+	}
+}
+
+func TestRepositoryBeforeSaveHook(t *testing.T) {
+	for name, repo := range implementations[label](t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			l := &label{Name: "Go"}
+			require.NoError(t, repo.Create(ctx, l))
+			assert.Equal(t, "go", l.Slug)
+			assert.ErrorIs(t, repo.Create(ctx, &label{Name: "GO"}), gorm.ErrDuplicatedKey)
+
+			l.Name = "Rust"
+			require.NoError(t, repo.Update(ctx, l))
+			got, err := repo.First(ctx, Eq("slug", "rust"))
+			require.NoError(t, err)
+			assert.Equal(t, l.ID, got.ID)
+		})
+	}
+}
+
+func TestRepositorySpecs(t *testing.T) {
+	for name, repo := range implementations[widget](t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			note := "x"
+			widgets := []*widget{
+				{Name: "a", Kind: "basic", Weight: 5},
+				{Name: "b", Kind: "fancy", Weight: 1, Note: &note},
+				{Name: "c", Kind: "fancy", Weight: 3},
+				{Name: "d", Kind: "basic", Weight: 4},
+			}
+			require.NoError(t, repo.CreateInBatches(ctx, widgets, 2))
+
+			names := func(ws []*widget) []string {
+				var out []string
+				for _, w := range ws {
+					out = append(out, w.Name)
+				}
+				return out
+			}
+
+			found, err := repo.Find(ctx, Eq("kind", "fancy"), OrderByDesc("weight"))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"c", "b"}, names(found))
+
+			found, err = repo.Find(ctx, In("name", "a", "b", "z"), Gte("weight", 2))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a"}, names(found))
+
+			found, err = repo.Find(ctx, OrderBy("weight"), Paginate(2, 2))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"d", "a"}, names(found))
+
+			found, err = repo.Find(ctx, Ne("note", nil))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"b"}, names(found))
+
+			count, err := repo.Count(ctx, Lt("weight", 4), Ne("kind", "basic"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), count)
+
+			first, err := repo.First(ctx, Eq("kind", "basic"), ForUpdate())
+			require.NoError(t, err)
+			assert.Equal(t, "a", first.Name)
+
+			deleted, err := repo.DeleteWhere(ctx, Eq("kind", "fancy"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), deleted)
+			count, err = repo.Count(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), count)
+			count, err = repo.Count(ctx, WithDeleted())
+			require.NoError(t, err)
+			assert.Equal(t, int64(4), count)
+
+			_, err = repo.DeleteWhere(ctx)
+			assert.ErrorIs(t, err, gorm.ErrMissingWhereClause)
+		})
+	}
+}
+
+func TestRepositoryUpsert(t *testing.T) {
+	for name, repo := range implementations[setting](t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			require.NoError(t, repo.Upsert(ctx, []*setting{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}))
+			require.NoError(t, repo.Upsert(ctx, []*setting{{Key: "a", Value: "3"}}, "key"))
+
+			all, err := repo.Find(ctx, OrderBy("key"))
+			require.NoError(t, err)
+			require.Len(t, all, 2)
+			assert.Equal(t, "3", all[0].Value)
+			assert.Equal(t, "2", all[1].Value)
+
+			// 没有版本号的模型更新不存在的记录时返回 ErrRecordNotFound
+			assert.ErrorIs(t, repo.Update(ctx, &setting{Key: "missing"}), gorm.ErrRecordNotFound)
+			assert.ErrorIs(t, repo.Delete(ctx, &setting{Key: "missing"}), gorm.ErrRecordNotFound)
+			require.NoError(t, repo.Delete(ctx, all[1]))
+			count, err := repo.Count(ctx, WithDeleted())
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), count)
+		})
+	}
+}
+
+func TestRepositoryUpsertRejectsVersioned(t *testing.T) {
+	for name, repo := range implementations[widget](t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			w := &widget{Name: "a"}
+			require.NoError(t, repo.Create(ctx, w))
+
+			stale := *w
+			stale.Weight = 5
+			assert.ErrorIs(t, repo.Upsert(ctx, []*widget{&stale}), ErrUpsertVersioned)
+
+			got, err := repo.GetByID(ctx, w.ID)
+			require.NoError(t, err)
+			assert.Equal(t, 0, got.Weight)
+			assert.Equal(t, uint(1), got.Version)
+		})
+	}
+}
+
+func TestMemoryRepositoryRejectsSpecFunc(t *testing.T) {
+	repo := NewMemoryRepository[widget]()
+	_, err := repo.Find(context.Background(), SpecFunc(func(db *gorm.DB) *gorm.DB { return db }))
+	assert.Error(t, err)
+}
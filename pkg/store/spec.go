@@ -0,0 +1,197 @@
+package store
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Spec 是一个查询条件、排序、分页、预加载或加锁子句。
+// 本包提供的 Spec 同时可以被 MemoryRepository 在内存中求值，自定义 Spec 只能用于 Repository
+type Spec interface {
+	Apply(db *gorm.DB) *gorm.DB
+}
+
+// SpecFunc 将任意 GORM 查询包装为 Spec，例如复杂的 JOIN 或子查询
+type SpecFunc func(db *gorm.DB) *gorm.DB
+
+func (f SpecFunc) Apply(db *gorm.DB) *gorm.DB {
+	return f(db)
+}
+
+type operator int
+
+const (
+	opEq operator = iota
+	opNe
+	opIn
+	opGt
+	opGte
+	opLt
+	opLte
+)
+
+// filter 是字段与值的比较，column 为数据库列名
+type filter struct {
+	column string
+	op     operator
+	value  interface{}
+	values []interface{}
+}
+
+func (f filter) Apply(db *gorm.DB) *gorm.DB {
+	column := clause.Column{Table: clause.CurrentTable, Name: f.column}
+	switch f.op {
+	case opNe:
+		return db.Where(clause.Neq{Column: column, Value: f.value})
+	case opIn:
+		return db.Where(clause.IN{Column: column, Values: f.values})
+	case opGt:
+		return db.Where(clause.Gt{Column: column, Value: f.value})
+	case opGte:
+		return db.Where(clause.Gte{Column: column, Value: f.value})
+	case opLt:
+		return db.Where(clause.Lt{Column: column, Value: f.value})
+	case opLte:
+		return db.Where(clause.Lte{Column: column, Value: f.value})
+	default:
+		return db.Where(clause.Eq{Column: column, Value: f.value})
+	}
+}
+
+// Eq 匹配 column = value，value 为 nil 时匹配 IS NULL
+func Eq(column string, value interface{}) Spec {
+	return filter{column: column, op: opEq, value: value}
+}
+
+// Ne 匹配 column <> value，value 为 nil 时匹配 IS NOT NULL
+func Ne(column string, value interface{}) Spec {
+	return filter{column: column, op: opNe, value: value}
+}
+
+// In 匹配 column IN (values...)，values 为空时不匹配任何行
+func In[V any](column string, values ...V) Spec {
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = v
+	}
+	return filter{column: column, op: opIn, values: list}
+}
+
+func Gt(column string, value interface{}) Spec {
+	return filter{column: column, op: opGt, value: value}
+}
+
+func Gte(column string, value interface{}) Spec {
+	return filter{column: column, op: opGte, value: value}
+}
+
+func Lt(column string, value interface{}) Spec {
+	return filter{column: column, op: opLt, value: value}
+}
+
+func Lte(column string, value interface{}) Spec {
+	return filter{column: column, op: opLte, value: value}
+}
+
+type order struct {
+	column string
+	desc   bool
+}
+
+func (o order) Apply(db *gorm.DB) *gorm.DB {
+	return db.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: o.column}, Desc: o.desc})
+}
+
+// OrderBy 按 column 升序排序，多个 OrderBy 按出现顺序组合
+func OrderBy(column string) Spec {
+	return order{column: column}
+}
+
+// OrderByDesc 按 column 降序排序
+func OrderByDesc(column string) Spec {
+	return order{column: column, desc: true}
+}
+
+type page struct {
+	offset int
+	limit  int
+}
+
+func (p page) Apply(db *gorm.DB) *gorm.DB {
+	if p.offset > 0 {
+		db = db.Offset(p.offset)
+	}
+	if p.limit > 0 {
+		db = db.Limit(p.limit)
+	}
+	return db
+}
+
+// Paginate 返回第 page 页（从 1 开始），每页 size 条
+func Paginate(page, size int) Spec {
+	if page < 1 {
+		page = 1
+	}
+	return Limit(size, (page-1)*size)
+}
+
+// Limit 跳过 offset 条后最多返回 limit 条，limit <= 0 表示不限制
+func Limit(limit, offset int) Spec {
+	return page{offset: offset, limit: limit}
+}
+
+type preload struct {
+	association string
+	specs       []Spec
+}
+
+func (p preload) Apply(db *gorm.DB) *gorm.DB {
+	if len(p.specs) == 0 {
+		return db.Preload(p.association)
+	}
+	return db.Preload(p.association, func(db *gorm.DB) *gorm.DB {
+		return apply(db, p.specs)
+	})
+}
+
+// Preload 预加载关联，specs 作用于关联的查询。MemoryRepository 忽略预加载
+func Preload(association string, specs ...Spec) Spec {
+	return preload{association: association, specs: specs}
+}
+
+type locking struct {
+	strength string
+}
+
+func (l locking) Apply(db *gorm.DB) *gorm.DB {
+	return db.Clauses(clause.Locking{Strength: l.strength})
+}
+
+// ForUpdate 读取时加排他行锁（SELECT ... FOR UPDATE），需要在事务中使用，
+// 配置了从库时加锁读始终使用主库
+func ForUpdate() Spec {
+	return locking{strength: clause.LockingStrengthUpdate}
+}
+
+// ForShare 读取时加共享行锁，需要在事务中使用
+func ForShare() Spec {
+	return locking{strength: clause.LockingStrengthShare}
+}
+
+type withDeleted struct{}
+
+func (withDeleted) Apply(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// WithDeleted 查询结果包含已软删除的记录
+func WithDeleted() Spec {
+	return withDeleted{}
+}
+
+func apply(db *gorm.DB, specs []Spec) *gorm.DB {
+	for _, spec := range specs {
+		db = spec.Apply(db)
+	}
+	return db
+}
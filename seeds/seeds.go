@@ -0,0 +1,74 @@
+// Package seeds 包含应用的种子数据集，使用 go run ./cmd/seed <set>... 写入数据库。
+// YAML 种子集放在本目录下，文件名即种子集名称；需要计算生成的数据用 Go 定义
+package seeds
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"strings"
+
+	"github.com/jtsang4/go-stater/internal/factory"
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/pkg/database"
+	"github.com/jtsang4/go-stater/pkg/seed"
+	"github.com/jtsang4/go-stater/pkg/store"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+//go:embed *.yaml
+var files embed.FS
+
+// InsecureSets 是创建了公开密码账号的种子集，server.mode 为 release 时 cmd/seed 默认拒绝运行
+var InsecureSets = []string{"dev", "demo", "e2e"}
+
+// demoUsers 是 demo 种子集生成的普通用户数量
+const demoUsers = 20
+
+// NewSeeder 创建包含全部种子集的 Seeder：dev、demo 和 e2e
+func NewSeeder(db *gorm.DB) (*seed.Seeder, error) {
+	return NewSeederFrom(
+		store.NewRepository[model.User](db),
+		store.NewRepository[model.UserPreference](db),
+		database.NewTxManager(db),
+	)
+}
+
+// NewSeederFrom 使用指定的存储创建 Seeder，例如测试中使用 store.MemoryRepository
+func NewSeederFrom(users store.RepositoryInterface[model.User], preferences store.RepositoryInterface[model.UserPreference], tx database.TxManagerInterface) (*seed.Seeder, error) {
+	s := seed.NewSeeder(tx)
+	s.RegisterModel("user", seed.NewModel(users, []string{"username"}, hashPassword))
+	s.RegisterModel("user_preference", seed.NewModel(preferences, []string{"user_id"}, nil))
+	if err := s.LoadFS(files); err != nil {
+		return nil, err
+	}
+
+	f := factory.NewFrom(users, preferences)
+	steps := make([]seed.Step, 0, demoUsers)
+	for i := 1; i <= demoUsers; i++ {
+		user := f.User().
+			WithUsername(fmt.Sprintf("demo%02d", i)).
+			WithEmail(fmt.Sprintf("demo%02d@example.com", i)).
+			Build()
+		steps = append(steps, seed.Record(users, []string{"username"}, "", user))
+	}
+	s.Register(seed.Set{Name: "demo", Includes: []string{"dev"}, Steps: steps})
+	return s, nil
+}
+
+// hashPassword 对 YAML 中的明文密码做哈希，已经是 bcrypt 哈希的密码保持不变
+func hashPassword(ctx context.Context, user *model.User) error {
+	if user.Password == "" {
+		user.Password = factory.DefaultPassword
+	}
+	if strings.HasPrefix(user.Password, "$2") {
+		return nil
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.Password = string(hashed)
+	return nil
+}
@@ -0,0 +1,53 @@
+package seeds
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jtsang4/go-stater/internal/model"
+	"github.com/jtsang4/go-stater/migrations"
+	"github.com/jtsang4/go-stater/pkg/database/dbtest"
+	"github.com/jtsang4/go-stater/pkg/encryption"
+	"github.com/jtsang4/go-stater/pkg/seed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	// model.User 的邮箱加密存储
+	encryption.SetDefault(encryption.NewTestKeyring())
+	os.Exit(m.Run())
+}
+
+func TestSeedSets(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.NewMigrated(t, migrations.Run)
+	seeder, err := NewSeeder(db)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"demo", "dev", "e2e"}, seeder.Sets())
+
+	// 重复运行不会产生重复数据
+	for i := 0; i < 2; i++ {
+		refs, err := seeder.Run(ctx, "demo", "e2e")
+		require.NoError(t, err)
+		admin, err := seed.Get[model.User](refs, "admin")
+		require.NoError(t, err)
+		assert.Equal(t, model.RoleAdmin, admin.Role)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte("admin123")))
+	}
+
+	var users, preferences int64
+	require.NoError(t, db.Model(&model.User{}).Count(&users).Error)
+	require.NoError(t, db.Model(&model.UserPreference{}).Count(&preferences).Error)
+	assert.Equal(t, int64(3+demoUsers+2), users)
+	assert.Equal(t, int64(2), preferences)
+
+	var alice model.User
+	require.NoError(t, db.Where("username = ?", "alice").First(&alice).Error)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(alice.Password), []byte("password123")))
+	var pref model.UserPreference
+	require.NoError(t, db.Where("user_id = ?", alice.ID).First(&pref).Error)
+	assert.Equal(t, "zh-CN", pref.Data["locale"])
+}